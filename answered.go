@@ -0,0 +1,50 @@
+package dnsspoofer
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Onyz107/dnsspoofer/internal/dns"
+)
+
+// answered remembers the transactions whose request was answered, so their genuine response can be dropped.
+type answered struct {
+	mu    sync.Mutex
+	txns  map[dns.Transaction]time.Time
+	sweep time.Time
+}
+
+func newAnswered() *answered {
+	return &answered{txns: make(map[dns.Transaction]time.Time)}
+}
+
+// add records t as answered at now, forgetting the transactions older than answeredTTL.
+func (a *answered) add(t dns.Transaction, now time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if now.Sub(a.sweep) > answeredTTL {
+		for txn, at := range a.txns {
+			if now.Sub(at) > answeredTTL {
+				delete(a.txns, txn)
+			}
+		}
+		a.sweep = now
+	}
+	a.txns[t] = now
+}
+
+// has reports whether t was answered less than answeredTTL before now.
+// The transaction is kept since every server the client asked may respond.
+func (a *answered) has(t dns.Transaction, now time.Time) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	at, ok := a.txns[t]
+	return ok && now.Sub(at) <= answeredTTL
+}
+
+// remove forgets t, whose forged answer could not be sent.
+func (a *answered) remove(t dns.Transaction) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.txns, t)
+}
@@ -0,0 +1,57 @@
+package dnsspoofer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Onyz107/dnsspoofer/internal/dns"
+)
+
+func TestAnswered(t *testing.T) {
+	txn := dns.Transaction{Client: "10.0.0.2", Port: 40000, ID: 1}
+	otherID := dns.Transaction{Client: "10.0.0.2", Port: 40000, ID: 2}
+	otherPort := dns.Transaction{Client: "10.0.0.2", Port: 40001, ID: 1}
+	start := time.Now()
+
+	tests := []struct {
+		name  string
+		setup func(a *answered)
+		query dns.Transaction
+		after time.Duration
+		want  bool
+	}{
+		{"unknown", func(a *answered) {}, txn, 0, false},
+		{"answered", func(a *answered) { a.add(txn, start) }, txn, 0, true},
+		{"other ID", func(a *answered) { a.add(txn, start) }, otherID, 0, false},
+		{"other port", func(a *answered) { a.add(txn, start) }, otherPort, 0, false},
+		{"at expiry", func(a *answered) { a.add(txn, start) }, txn, answeredTTL, true},
+		{"expired", func(a *answered) { a.add(txn, start) }, txn, answeredTTL + time.Millisecond, false},
+		{"removed", func(a *answered) { a.add(txn, start); a.remove(txn) }, txn, 0, false},
+		{"removed other", func(a *answered) { a.add(txn, start); a.remove(otherID) }, txn, 0, true},
+		// Every server the client asked may respond, the transaction stays after the first one.
+		{"asked twice", func(a *answered) { a.add(txn, start); a.has(txn, start) }, txn, time.Second, true},
+		{"answered again", func(a *answered) { a.add(txn, start); a.add(txn, start.Add(answeredTTL)) }, txn, answeredTTL + time.Second, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newAnswered()
+			tt.setup(a)
+			if got := a.has(tt.query, start.Add(tt.after)); got != tt.want {
+				t.Errorf("has() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnsweredSweep(t *testing.T) {
+	a := newAnswered()
+	start := time.Now()
+	a.add(dns.Transaction{ID: 1}, start)
+	a.add(dns.Transaction{ID: 2}, start.Add(3*time.Second))
+	a.add(dns.Transaction{ID: 3}, start.Add(answeredTTL+2*time.Second))
+
+	// Only the transaction expired at the last sweep is forgotten.
+	if len(a.txns) != 2 {
+		t.Errorf("transactions kept = %v, want IDs 2 and 3", a.txns)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Onyz107/dnsspoofer/internal/iptables"
+	"github.com/Onyz107/dnsspoofer/internal/logger"
+	"github.com/Onyz107/dnsspoofer/internal/nftables"
+	"github.com/urfave/cli/v2"
+)
+
+// cleanupCommand removes the nftables tables and rules, and the iptables chains, left behind by killed instances.
+func cleanupCommand() *cli.Command {
+	var all bool
+
+	return &cli.Command{
+		Name:  "cleanup",
+		Usage: "Remove the nftables tables and rules, and the iptables chains, left behind by dnsspoofer instances that are no longer running",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:        "all",
+				Usage:       "Also remove the tables, rules and chains of running instances",
+				Destination: &all,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			ctx := logger.WithLogger(c.Context, logger.Log)
+
+			// Both firewalls are cleaned even if one fails, a kernel may lack nftables or iptables.
+			deleted, nftErr := nftables.CleanupTables(ctx, all)
+			chains, iptErr := iptables.CleanupChains(ctx, all)
+
+			for _, t := range deleted {
+				if t.Attached {
+					fmt.Printf("removed dnsspoofer rules from %s table %s (pid %d)\n", t.Family, t.Name, t.PID)
+					continue
+				}
+				fmt.Printf("removed %s table %s (pid %d)\n", t.Family, t.Name, t.PID)
+			}
+			for _, ch := range chains {
+				fmt.Printf("removed %s chain %s from table %s (pid %d)\n", ch.Binary, ch.Name, ch.Table, ch.PID)
+			}
+			if err := errors.Join(nftErr, iptErr); err != nil {
+				return errors.Join(ErrCleanup, err)
+			}
+			if len(deleted) == 0 && len(chains) == 0 {
+				fmt.Println("no dnsspoofer tables or chains to remove")
+			}
+			return nil
+		},
+	}
+}
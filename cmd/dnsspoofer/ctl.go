@@ -0,0 +1,229 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Onyz107/dnsspoofer"
+	"github.com/Onyz107/dnsspoofer/internal/control"
+	"github.com/urfave/cli/v2"
+)
+
+type ctlOptions struct {
+	Addr string
+	Name string
+}
+
+// ctlCommand drives a running instance through its control API (--control-listen, the default socket unless disabled).
+func ctlCommand() *cli.Command {
+	opts := &ctlOptions{}
+	client := func() *control.Client {
+		return control.NewClient(opts.Addr)
+	}
+
+	printStatus := func(status control.Status) {
+		fmt.Printf("paused: %t\nnftables rules installed: %t\nrules: %d\n",
+			status.Paused, status.RulesInstalled, status.Rules)
+	}
+
+	return &cli.Command{
+		Name:  "ctl",
+		Usage: "Control a running instance through its control API (--control-listen)",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "addr",
+				Aliases:     []string{"a"},
+				Usage:       "Control API address of the running instance: unix:/path/to.sock or a loopback host:port",
+				Value:       control.DefaultAddr,
+				Destination: &opts.Addr,
+			},
+		},
+		Subcommands: []*cli.Command{
+			{
+				Name:  "rules",
+				Usage: "List, add or delete spoofing rules",
+				Subcommands: []*cli.Command{
+					{
+						Name:  "list",
+						Usage: "List the active rules",
+						Action: func(c *cli.Context) error {
+							rules, err := client().Rules(c.Context)
+							if err != nil {
+								return controlError(opts.Addr, err)
+							}
+							w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+							defer w.Flush()
+							fmt.Fprintln(w, "NAME\tPATTERN\tIPS")
+							for _, r := range rules {
+								ips := make([]string, 0, len(r.IPs))
+								for _, ip := range r.IPs {
+									ips = append(ips, ip.String())
+								}
+								fmt.Fprintf(w, "%s\t%s\t%s\n", r.Name, r.Pattern, strings.Join(ips, ","))
+							}
+							return nil
+						},
+					},
+					{
+						Name:      "add",
+						Usage:     "Add a rule answering names matching PATTERN with the IPs",
+						ArgsUsage: "PATTERN IP [IP...]",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:        "name",
+								Aliases:     []string{"n"},
+								Usage:       "Rule name, defaults to ctl:PATTERN",
+								Destination: &opts.Name,
+							},
+						},
+						Action: func(c *cli.Context) error {
+							if c.NArg() < 2 {
+								return ErrCtlUsage
+							}
+							rule := dnsspoofer.Rule{Name: opts.Name, Pattern: c.Args().First()}
+							if rule.Name == "" {
+								rule.Name = "ctl:" + rule.Pattern
+							}
+							for _, arg := range c.Args().Tail() {
+								ip := net.ParseIP(arg)
+								if ip == nil {
+									return fmt.Errorf("%w: %s", ErrInvalidIP, arg)
+								}
+								rule.IPs = append(rule.IPs, ip)
+							}
+
+							if err := client().AddRule(c.Context, rule); err != nil {
+								return controlError(opts.Addr, err)
+							}
+							fmt.Println("added", rule.Name)
+							return nil
+						},
+					},
+					{
+						Name:      "del",
+						Usage:     "Delete every rule named NAME",
+						ArgsUsage: "NAME",
+						Action: func(c *cli.Context) error {
+							if c.NArg() != 1 {
+								return ErrCtlUsage
+							}
+							n, err := client().RemoveRule(c.Context, c.Args().First())
+							if err != nil {
+								return controlError(opts.Addr, err)
+							}
+							fmt.Println("deleted", n, "rule(s)")
+							return nil
+						},
+					},
+				},
+			},
+			{
+				Name:  "pause",
+				Usage: "Pass every DNS packet untouched until resumed",
+				Action: func(c *cli.Context) error {
+					status, err := client().Pause(c.Context)
+					if err != nil {
+						return controlError(opts.Addr, err)
+					}
+					printStatus(status)
+					return nil
+				},
+			},
+			{
+				Name:  "resume",
+				Usage: "Resume spoofing",
+				Action: func(c *cli.Context) error {
+					status, err := client().Resume(c.Context)
+					if err != nil {
+						return controlError(opts.Addr, err)
+					}
+					printStatus(status)
+					return nil
+				},
+			},
+			{
+				Name:  "status",
+				Usage: "Show whether spoofing is paused and the nftables rules are installed",
+				Action: func(c *cli.Context) error {
+					status, err := client().Status(c.Context)
+					if err != nil {
+						return controlError(opts.Addr, err)
+					}
+					printStatus(status)
+					return nil
+				},
+			},
+			{
+				Name:  "stats",
+				Usage: "Show the engine statistics",
+				Action: func(c *cli.Context) error {
+					st, err := client().Stats(c.Context)
+					if err != nil {
+						return controlError(opts.Addr, err)
+					}
+					printStats(st)
+					return nil
+				},
+			},
+		},
+	}
+}
+
+// printStats prints the counters of st, then each breakdown sorted by count.
+func printStats(st control.Stats) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintf(w, "packets\t%d\n", st.Packets)
+	fmt.Fprintf(w, "matched\t%d\n", st.Matched)
+	fmt.Fprintf(w, "spoofed\t%d\n", st.Spoofed)
+	fmt.Fprintf(w, "skipped\t%d\n", st.Skipped)
+	fmt.Fprintf(w, "dropped\t%d\n", st.Dropped)
+	fmt.Fprintf(w, "failed\t%d\n", st.Failed)
+	fmt.Fprintf(w, "queue full\t%d\n", st.QueueFull)
+	fmt.Fprintf(w, "kernel queued\t%d (%d bytes)\n", st.KernelPackets, st.KernelBytes)
+	fmt.Fprintf(w, "backlog\t%d\n", st.Backlog)
+	fmt.Fprintf(w, "nftables rules installed\t%t\n", st.RulesInstalled)
+	if st.SpoofLatency.Count > 0 {
+		fmt.Fprintf(w, "mean spoof latency\t%s\n", st.SpoofLatency.Sum/time.Duration(st.SpoofLatency.Count))
+	}
+
+	breakdowns := []struct {
+		title  string
+		counts map[string]uint64
+	}{
+		{"records", st.Records},
+		{"rules", st.Rules},
+		{"clients", st.Clients},
+		{"errors", st.Errors},
+		{"parse errors", st.ParseErrors},
+	}
+	for _, b := range breakdowns {
+		if len(b.counts) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\n%s\t\n", strings.ToUpper(b.title))
+		keys := slices.SortedFunc(maps.Keys(b.counts), func(x, y string) int {
+			return cmp.Or(cmp.Compare(b.counts[y], b.counts[x]), strings.Compare(x, y))
+		})
+		for _, k := range keys {
+			fmt.Fprintf(w, "%s\t%d\n", k, b.counts[k])
+		}
+	}
+}
+
+// controlError wraps an error of the control API client, pointing at --control-listen when nothing answered on addr.
+func controlError(addr string, err error) error {
+	if opErr := (*net.OpError)(nil); errors.As(err, &opErr) && opErr.Op == "dial" {
+		return errors.Join(ErrControl, fmt.Errorf("%w on %s, check the --control-listen of the instance", ErrNotListening, addr), err)
+	}
+	return errors.Join(ErrControl, err)
+}
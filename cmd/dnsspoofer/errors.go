@@ -3,12 +3,34 @@ package main
 import "errors"
 
 var (
+	ErrMissingInterface = errors.New("missing required flag --interface")
+	ErrMissingHosts     = errors.New("missing required flag --hosts")
 	ErrOpenInterface    = errors.New("failed to open network interface")
 	ErrInvalidIPMode    = errors.New("invalid IP mode")
 	ErrInvalidSpoofMode = errors.New("invalid spoof mode")
 	ErrInvalidScope     = errors.New("invalid scope")
+	ErrInvalidQueue     = errors.New("invalid NFQUEUE range")
 	ErrRedirectDNS      = errors.New("failed to redirect DNS to NFQUEUE")
 	ErrLoadHostsFile    = errors.New("failed to load hosts file")
 	ErrSpoofDNS         = errors.New("failed to spoof DNS")
 	ErrRunEngine        = errors.New("failed to run DNS spoofer engine")
+	ErrServeMetrics     = errors.New("failed to serve metrics")
+	ErrServeControl     = errors.New("failed to serve control API")
+	ErrServeGRPC        = errors.New("failed to serve gRPC API")
+	ErrCleanup          = errors.New("failed to remove stale dnsspoofer tables and chains")
+	ErrInvalidBackend   = errors.New("invalid firewall backend, expected nftables or iptables")
+	ErrInvalidHook      = errors.New("invalid hook, expected auto, prerouting or postrouting")
+	ErrInvalidPriority  = errors.New("invalid chain priority")
+	ErrInvalidNFTTarget = errors.New("--nft-table and --nft-chain must be set together")
+	ErrInvalidProxyPort = errors.New("invalid proxy port")
+	ErrMissingUpstream  = errors.New("--upstream is required with --spoof-mode proxy")
+	ErrControl          = errors.New("failed to control the running instance")
+	ErrNotListening     = errors.New("no running instance serves the control API")
+	ErrCtlUsage         = errors.New("wrong number of arguments, see --help")
+	ErrInvalidIP        = errors.New("invalid IP")
+	ErrOpenQueryLog     = errors.New("failed to open query log")
+	ErrWriteQueryLog    = errors.New("failed to write query log")
+	ErrOpenHistory      = errors.New("failed to open history database")
+	ErrCreatePcap       = errors.New("failed to create pcapng file")
+	ErrWritePcap        = errors.New("failed to write pcapng file")
 )
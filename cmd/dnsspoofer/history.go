@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Onyz107/dnsspoofer/internal/history"
+	"github.com/Onyz107/dnsspoofer/internal/logger"
+	"github.com/urfave/cli/v2"
+)
+
+type historyOptions struct {
+	DB     cli.Path
+	Client string
+	Limit  int
+}
+
+// historyCommand queries the database written with --history-db.
+func historyCommand() *cli.Command {
+	opts := &historyOptions{}
+
+	withStore := func(fn func(ctx context.Context, store *history.Store, w *tabwriter.Writer) error) cli.ActionFunc {
+		return func(c *cli.Context) error {
+			ctx := logger.WithLogger(c.Context, logger.Log)
+			store, err := history.Open(ctx, opts.DB)
+			if err != nil {
+				return errors.Join(ErrOpenHistory, err)
+			}
+			defer store.Close()
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			defer w.Flush()
+			return fn(ctx, store, w)
+		}
+	}
+
+	return &cli.Command{
+		Name:  "history",
+		Usage: "Query the history of intercepted DNS traffic recorded with --history-db",
+		Flags: []cli.Flag{
+			&cli.PathFlag{
+				Name:        "db",
+				Usage:       "Path to the history database",
+				Value:       "dnsspoofer.db",
+				Destination: &opts.DB,
+			},
+		},
+		Subcommands: []*cli.Command{
+			{
+				Name:  "domains",
+				Usage: "Top queried domains per client",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:        "client",
+						Aliases:     []string{"c"},
+						Usage:       "Only show this client address",
+						Destination: &opts.Client,
+					},
+					&cli.IntFlag{
+						Name:        "limit",
+						Aliases:     []string{"n"},
+						Usage:       "Domains shown per client",
+						Value:       10,
+						Destination: &opts.Limit,
+					},
+				},
+				Action: withStore(func(ctx context.Context, store *history.Store, w *tabwriter.Writer) error {
+					domains, err := store.TopDomains(ctx, opts.Client, opts.Limit)
+					if err != nil {
+						return err
+					}
+					fmt.Fprintln(w, "CLIENT\tDOMAIN\tQUERIES\tSPOOFED")
+					for _, d := range domains {
+						fmt.Fprintf(w, "%s\t%s\t%d\t%d\n", d.Client, d.Name, d.Count, d.Spoofed)
+					}
+					return nil
+				}),
+			},
+			{
+				Name:  "clients",
+				Usage: "Queries per client with first and last seen times",
+				Action: withStore(func(ctx context.Context, store *history.Store, w *tabwriter.Writer) error {
+					clients, err := store.Clients(ctx)
+					if err != nil {
+						return err
+					}
+					fmt.Fprintln(w, "CLIENT\tQUERIES\tSPOOFED\tFIRST SEEN\tLAST SEEN")
+					for _, c := range clients {
+						fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\n", c.Client, c.Queries, c.Spoofed,
+							c.FirstSeen.Format(time.DateTime), c.LastSeen.Format(time.DateTime))
+					}
+					return nil
+				}),
+			},
+			{
+				Name:  "rules",
+				Usage: "Which rules fired, how often and for how many clients",
+				Action: withStore(func(ctx context.Context, store *history.Store, w *tabwriter.Writer) error {
+					rules, err := store.Rules(ctx)
+					if err != nil {
+						return err
+					}
+					fmt.Fprintln(w, "RULE\tHITS\tCLIENTS\tFIRST SEEN\tLAST SEEN")
+					for _, r := range rules {
+						fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\n", r.Rule, r.Hits, r.Clients,
+							r.FirstSeen.Format(time.DateTime), r.LastSeen.Format(time.DateTime))
+					}
+					return nil
+				}),
+			},
+		},
+	}
+}
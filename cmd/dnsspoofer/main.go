@@ -3,6 +3,9 @@ package main
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
+	"math"
 	"net"
 	"os"
 	"os/signal"
@@ -10,7 +13,14 @@ import (
 
 	"github.com/Onyz107/dnsspoofer"
 	"github.com/Onyz107/dnsspoofer/internal/banner"
+	"github.com/Onyz107/dnsspoofer/internal/control"
+	"github.com/Onyz107/dnsspoofer/internal/grpcapi"
+	"github.com/Onyz107/dnsspoofer/internal/history"
 	"github.com/Onyz107/dnsspoofer/internal/logger"
+	"github.com/Onyz107/dnsspoofer/internal/metrics"
+	"github.com/Onyz107/dnsspoofer/internal/pcapng"
+	"github.com/Onyz107/dnsspoofer/internal/querylog"
+	"github.com/Onyz107/dnsspoofer/internal/tui"
 	"github.com/Onyz107/dnsspoofer/internal/wildhosts"
 	"github.com/charmbracelet/log"
 	"github.com/urfave/cli/v2"
@@ -25,6 +35,36 @@ type options struct {
 	ScopeStr     string
 	Hosts        cli.Path
 	QueueInt     int
+	QueueCount   int
+	Workers      int
+	Backlog      int
+	MaxQueueLen  uint
+	MaxPacketLen uint
+	FailClosed   bool
+	BackendStr   string
+	HookStr      string
+	Priority     int
+	NFTTable     string
+	NFTChain     string
+	Upstream     string
+	ProxyPort    int
+	CacheSize    int
+	StripDNSSEC  bool
+	GSO          bool
+	MetricsAddr  string
+	ControlAddr  string
+	GRPCAddr     string
+	GRPCCert     cli.Path
+	GRPCKey      cli.Path
+	GRPCClientCA cli.Path
+	GRPCToken    string
+	QueryLog     cli.Path
+	QueryLogSize int
+	QueryLogKeep int
+	HistoryDB    cli.Path
+	PcapOut      cli.Path
+	TUI          bool
+	DryRun       bool
 	Debug        bool
 }
 
@@ -41,8 +81,7 @@ func main() {
 			&cli.StringFlag{
 				Name:        "interface",
 				Aliases:     []string{"i"},
-				Usage:       "Network interface to use",
-				Required:    true,
+				Usage:       "Network interface to use (required)",
 				Destination: &opts.Interface,
 			},
 			&cli.StringFlag{
@@ -55,14 +94,13 @@ func main() {
 			&cli.StringFlag{
 				Name:        "spoof-mode",
 				Aliases:     []string{"sm"},
-				Usage:       "Spoofing behavior: aggressive (reply to requests) or passive (modify responses)",
+				Usage:       "Spoofing behavior: aggressive (reply to requests), passive (modify responses), hybrid (both) or proxy (redirect to a local resolver)",
 				Value:       "passive",
 				Destination: &opts.SpoofModeStr,
 			},
 			&cli.PathFlag{
 				Name:        "hosts",
-				Usage:       "Path to a hosts(5) formatted file, one hostname per line, wildcards allowed.",
-				Required:    true,
+				Usage:       "Path to a hosts(5) formatted file, one hostname per line, wildcards allowed (required)",
 				Destination: &opts.Hosts,
 			},
 			&cli.StringFlag{
@@ -79,6 +117,176 @@ func main() {
 				Value:       0,
 				Destination: &opts.QueueInt,
 			},
+			&cli.IntFlag{
+				Name:        "queue-count",
+				Aliases:     []string{"qc"},
+				Usage:       "Number of consecutive NFQUEUEs starting at --queue to fan packets out to",
+				Value:       1,
+				Destination: &opts.QueueCount,
+			},
+			&cli.IntFlag{
+				Name:        "workers",
+				Aliases:     []string{"w"},
+				Usage:       "Number of packet processing workers per queue (0 = number of CPUs)",
+				Value:       0,
+				Destination: &opts.Workers,
+			},
+			&cli.IntFlag{
+				Name:        "backlog",
+				Usage:       "Number of packets buffered for the workers, overflow is accepted unmodified",
+				Value:       dnsspoofer.DefaultBacklog,
+				Destination: &opts.Backlog,
+			},
+			&cli.UintFlag{
+				Name:        "max-queue-len",
+				Usage:       "Number of packets the kernel holds in each NFQUEUE",
+				Value:       dnsspoofer.DefaultMaxQueueLen,
+				Destination: &opts.MaxQueueLen,
+			},
+			&cli.UintFlag{
+				Name:        "max-packet-len",
+				Usage:       "Copy range: bytes copied from each queued packet, keep above the largest expected DNS answer",
+				Value:       dnsspoofer.DefaultMaxPacketLen,
+				Destination: &opts.MaxPacketLen,
+			},
+			&cli.BoolFlag{
+				Name:        "fail-closed",
+				Usage:       "Drop DNS packets that cannot be spoofed (queue down or full, errors) instead of letting them through",
+				Value:       false,
+				Destination: &opts.FailClosed,
+			},
+			&cli.StringFlag{
+				Name:        "firewall-backend",
+				Usage:       "Firewall the queueing rules are installed with: nftables, iptables (iptables-legacy kernels)",
+				Value:       "nftables",
+				Destination: &opts.BackendStr,
+			},
+			&cli.StringFlag{
+				Name:        "hook",
+				Usage:       "Netfilter hook: auto (from spoof mode and scope), prerouting (packets entering the interface), postrouting (packets leaving it)",
+				Value:       "auto",
+				Destination: &opts.HookStr,
+			},
+			&cli.IntFlag{
+				Name:        "chain-priority",
+				Usage:       "Priority of the nftables chain, lower runs first (firewalld and Docker filter at 0, raw is -300)",
+				Value:       0,
+				Destination: &opts.Priority,
+			},
+			&cli.StringFlag{
+				Name:        "nft-table",
+				Usage:       `Insert the rule into this existing table ("inet filter") instead of creating one, needs --nft-chain`,
+				Destination: &opts.NFTTable,
+			},
+			&cli.StringFlag{
+				Name:        "nft-chain",
+				Usage:       "Existing base chain of --nft-table to insert the rule at the top of",
+				Destination: &opts.NFTChain,
+			},
+			&cli.StringFlag{
+				Name:        "upstream",
+				Usage:       "DNS server the proxy spoof mode forwards unmatched requests to, as IP or IP:port",
+				Destination: &opts.Upstream,
+			},
+			&cli.IntFlag{
+				Name:        "proxy-port",
+				Usage:       "Local port DNS requests are redirected to in the proxy spoof mode",
+				Value:       dnsspoofer.DefaultProxyPort,
+				Destination: &opts.ProxyPort,
+			},
+			&cli.IntFlag{
+				Name:        "cache-size",
+				Usage:       "Number of upstream answers the proxy spoof mode caches, 0 disables the cache",
+				Value:       dnsspoofer.DefaultCacheSize,
+				Destination: &opts.CacheSize,
+			},
+			&cli.BoolFlag{
+				Name:        "strip-dnssec",
+				Usage:       "Remove the RRSIG, NSEC and NSEC3 records from the upstream answers in the proxy spoof mode",
+				Value:       false,
+				Destination: &opts.StripDNSSEC,
+			},
+			&cli.BoolFlag{
+				Name:        "gso",
+				Usage:       "Let the kernel queue GSO packets without segmenting them",
+				Value:       false,
+				Destination: &opts.GSO,
+			},
+			&cli.StringFlag{
+				Name:        "metrics-listen",
+				Usage:       "Serve Prometheus metrics on this address (e.g. :9153), disabled if empty",
+				Destination: &opts.MetricsAddr,
+			},
+			&cli.StringFlag{
+				Name:        "control-listen",
+				Usage:       "Serve the HTTP/JSON control API used by the ctl command on a loopback address (127.0.0.1:9154) or unix:/path/to.sock, disabled if empty",
+				Value:       control.DefaultAddr,
+				Destination: &opts.ControlAddr,
+			},
+			&cli.StringFlag{
+				Name:        "grpc-listen",
+				Usage:       "Serve the gRPC API (WatchEvents, ApplyRules, GetStats) on host:port or unix:/path/to.sock, non-loopback hosts need --grpc-tls-cert, disabled if empty",
+				Destination: &opts.GRPCAddr,
+			},
+			&cli.PathFlag{
+				Name:        "grpc-tls-cert",
+				Usage:       "PEM certificate serving the gRPC API over TLS, needs --grpc-tls-key",
+				Destination: &opts.GRPCCert,
+			},
+			&cli.PathFlag{
+				Name:        "grpc-tls-key",
+				Usage:       "PEM private key of --grpc-tls-cert",
+				Destination: &opts.GRPCKey,
+			},
+			&cli.PathFlag{
+				Name:        "grpc-client-ca",
+				Usage:       "PEM bundle of the CAs gRPC client certificates must be signed by (mTLS), needs --grpc-tls-cert",
+				Destination: &opts.GRPCClientCA,
+			},
+			&cli.StringFlag{
+				Name:        "grpc-token",
+				Usage:       "Bearer token gRPC clients must send in the authorization metadata",
+				EnvVars:     []string{"DNSSPOOFER_GRPC_TOKEN"},
+				Destination: &opts.GRPCToken,
+			},
+			&cli.PathFlag{
+				Name:        "query-log",
+				Usage:       "Write every DNS transaction as a JSON line to this file, disabled if empty",
+				Destination: &opts.QueryLog,
+			},
+			&cli.IntFlag{
+				Name:        "query-log-max-size",
+				Usage:       "Size in MiB the query log grows to before being rotated",
+				Value:       querylog.DefaultMaxSize >> 20,
+				Destination: &opts.QueryLogSize,
+			},
+			&cli.IntFlag{
+				Name:        "query-log-max-backups",
+				Usage:       "Number of rotated query logs to keep",
+				Value:       querylog.DefaultMaxBackups,
+				Destination: &opts.QueryLogKeep,
+			},
+			&cli.PathFlag{
+				Name:        "history-db",
+				Usage:       "Record every DNS transaction into this SQLite database, see the history command",
+				Destination: &opts.HistoryDB,
+			},
+			&cli.PathFlag{
+				Name:        "pcap-out",
+				Usage:       "Write original and forged packets into this pcapng file, commented with their verdict",
+				Destination: &opts.PcapOut,
+			},
+			&cli.BoolFlag{
+				Name:        "tui",
+				Usage:       "Show a live terminal dashboard instead of logs",
+				Value:       false,
+				Destination: &opts.TUI,
+			},
+			&cli.BoolFlag{
+				Name:        "dry-run",
+				Usage:       "Print the nftables ruleset that would be installed and exit, --hosts is not needed",
+				Destination: &opts.DryRun,
+			},
 			&cli.BoolFlag{
 				Name:        "debug",
 				Aliases:     []string{"d"},
@@ -87,51 +295,36 @@ func main() {
 				Destination: &opts.Debug,
 			},
 		},
-		Action: func(c *cli.Context) error {
+		Commands: []*cli.Command{
+			historyCommand(),
+			ctlCommand(),
+			cleanupCommand(),
+			rulesCommand(opts),
+		},
+		Before: func(c *cli.Context) error {
 			if opts.Debug {
 				logger.Log.SetLevel(log.DebugLevel)
 				logger.Log.Debug("debugging on")
 			}
-
-			ifaceHandle, err := net.InterfaceByName(opts.Interface)
+			return nil
+		},
+		Action: func(c *cli.Context) error {
+			engineOpts, err := netOptions(opts)
 			if err != nil {
-				return errors.Join(ErrOpenInterface, err)
+				return err
 			}
-
-			var ipMode dnsspoofer.IPMode
-			switch opts.IPModeStr {
-			case "ipv4":
-				ipMode = dnsspoofer.IPv4Only
-			case "ipv6":
-				ipMode = dnsspoofer.IPv6Only
-			case "ipv4+ipv6":
-				ipMode = dnsspoofer.IPv4AndIPv6
-			default:
-				return ErrInvalidIPMode
+			if opts.DryRun {
+				return printRuleset(engineOpts)
 			}
 
-			var spoofMode dnsspoofer.SpoofMode
-			switch opts.SpoofModeStr {
-			case "aggressive":
-				spoofMode = dnsspoofer.Aggressive
-			case "passive":
-				spoofMode = dnsspoofer.Passive
-			default:
-				return ErrInvalidSpoofMode
+			// --hosts is checked here rather than marked Required, so that subcommands can run without it.
+			if opts.Hosts == "" {
+				return ErrMissingHosts
 			}
-
-			var scope dnsspoofer.Scope
-			switch opts.ScopeStr {
-			case "local":
-				scope = dnsspoofer.Local
-			case "remote":
-				scope = dnsspoofer.Remote
-			default:
-				return ErrInvalidScope
+			if engineOpts.SpoofMode == dnsspoofer.Proxy && opts.Upstream == "" {
+				return ErrMissingUpstream
 			}
 
-			queue := uint16(opts.QueueInt)
-
 			sigCtx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 			defer cancel()
 
@@ -142,15 +335,121 @@ func main() {
 			hostsMap := hosts.Map()
 			logger.Log.Debug("loaded hosts file", "map", hostsMap)
 
-			spoof := dnsspoofer.New(&dnsspoofer.EngineOptions{
-				Iface:     ifaceHandle,
-				IPMode:    ipMode,
-				SpoofMode: spoofMode,
-				Scope:     scope,
-				Hosts:     hostsMap,
-				Queue:     queue,
-				Log:       logger.Log,
-			})
+			rules, err := dnsspoofer.NewRuleSet(hosts.Rules()...)
+			if err != nil {
+				return errors.Join(ErrLoadHostsFile, err)
+			}
+
+			var onEvent []func(dnsspoofer.Event)
+
+			if opts.QueryLog != "" {
+				queryLog, err := querylog.Open(opts.QueryLog, int64(opts.QueryLogSize)<<20, opts.QueryLogKeep)
+				if err != nil {
+					return errors.Join(ErrOpenQueryLog, err)
+				}
+				defer queryLog.Close()
+
+				onEvent = append(onEvent, func(ev dnsspoofer.Event) {
+					if err := queryLog.WriteEvent(ev); err != nil {
+						logger.Log.Error(ErrWriteQueryLog.Error(), "err", err)
+					}
+				})
+			}
+
+			if opts.HistoryDB != "" {
+				store, err := history.Open(logger.WithLogger(sigCtx, logger.Log), opts.HistoryDB)
+				if err != nil {
+					return errors.Join(ErrOpenHistory, err)
+				}
+				defer store.Close()
+
+				onEvent = append(onEvent, store.Record)
+			}
+
+			if opts.PcapOut != "" {
+				pcap, err := pcapng.Create(opts.PcapOut)
+				if err != nil {
+					return errors.Join(ErrCreatePcap, err)
+				}
+				defer pcap.Close()
+
+				onEvent = append(onEvent, func(ev dnsspoofer.Event) {
+					if err := pcap.WriteEvent(ev); err != nil {
+						logger.Log.Error(ErrWritePcap.Error(), "err", err)
+					}
+				})
+			}
+
+			engineOpts.Hosts = hostsMap
+			engineOpts.Decider = rules
+			engineOpts.Workers = opts.Workers
+			engineOpts.Backlog = opts.Backlog
+			engineOpts.MaxQueueLen = uint32(opts.MaxQueueLen)
+			engineOpts.MaxPacketLen = uint32(opts.MaxPacketLen)
+			engineOpts.GSO = opts.GSO
+			engineOpts.Log = logger.Log
+			engineOpts.OnEvent = func(ev dnsspoofer.Event) {
+				for _, f := range onEvent {
+					f(ev)
+				}
+			}
+			spoof := dnsspoofer.New(engineOpts)
+
+			if opts.MetricsAddr != "" {
+				if err := metrics.Serve(logger.WithLogger(sigCtx, logger.Log), opts.MetricsAddr, spoof); err != nil {
+					return errors.Join(ErrServeMetrics, err)
+				}
+			}
+
+			if opts.ControlAddr != "" {
+				err := control.Serve(logger.WithLogger(sigCtx, logger.Log), opts.ControlAddr, spoof, rules)
+				// Another instance already serves the default socket, this one runs without the API.
+				if opts.ControlAddr == control.DefaultAddr && errors.Is(err, control.ErrSocketInUse) {
+					logger.Log.Warn(ErrServeControl.Error(), "err", err)
+				} else if err != nil {
+					return errors.Join(ErrServeControl, err)
+				}
+			}
+
+			if opts.GRPCAddr != "" {
+				api := grpcapi.NewServer(spoof, rules)
+				onEvent = append(onEvent, api.Publish)
+				if err := grpcapi.Serve(logger.WithLogger(sigCtx, logger.Log), opts.GRPCAddr, api, grpcapi.Options{
+					CertFile:     opts.GRPCCert,
+					KeyFile:      opts.GRPCKey,
+					ClientCAFile: opts.GRPCClientCA,
+					Token:        opts.GRPCToken,
+				}); err != nil {
+					return errors.Join(ErrServeGRPC, err)
+				}
+			}
+
+			if opts.TUI {
+				dashboard := tui.New(spoof)
+				onEvent = append(onEvent, dashboard.Event)
+
+				// Logs would scribble over the dashboard, they are back once it exits so that errors are reported.
+				logger.Log.SetOutput(io.Discard)
+
+				tuiCtx, stop := context.WithCancel(sigCtx)
+				defer stop()
+
+				errCh := make(chan error, 1)
+				go func() {
+					errCh <- spoof.Run(tuiCtx)
+					stop()
+				}()
+
+				// The engine is stopped, and its rules removed, whichever of the two ends first.
+				tuiErr := dashboard.Run(tuiCtx)
+				stop()
+				runErr := <-errCh
+				logger.Log.SetOutput(os.Stdout)
+				if runErr != nil {
+					return errors.Join(ErrRunEngine, runErr)
+				}
+				return tuiErr
+			}
 
 			logger.Log.Info("starting dnsspoofer")
 			if err := spoof.Run(sigCtx); err != nil {
@@ -169,3 +468,122 @@ func main() {
 		logger.Log.Fatal(err)
 	}
 }
+
+// netOptions parses the flags selecting what the nftables rules match and where packets are queued.
+//
+// --interface is checked here rather than marked Required, so that subcommands can run without it.
+func netOptions(opts *options) (*dnsspoofer.EngineOptions, error) {
+	if opts.Interface == "" {
+		return nil, ErrMissingInterface
+	}
+	ifaceHandle, err := net.InterfaceByName(opts.Interface)
+	if err != nil {
+		return nil, errors.Join(ErrOpenInterface, err)
+	}
+
+	var ipMode dnsspoofer.IPMode
+	switch opts.IPModeStr {
+	case "ipv4":
+		ipMode = dnsspoofer.IPv4Only
+	case "ipv6":
+		ipMode = dnsspoofer.IPv6Only
+	case "ipv4+ipv6":
+		ipMode = dnsspoofer.IPv4AndIPv6
+	default:
+		return nil, ErrInvalidIPMode
+	}
+
+	var spoofMode dnsspoofer.SpoofMode
+	switch opts.SpoofModeStr {
+	case "aggressive":
+		spoofMode = dnsspoofer.Aggressive
+	case "passive":
+		spoofMode = dnsspoofer.Passive
+	case "hybrid":
+		spoofMode = dnsspoofer.Hybrid
+	case "proxy":
+		spoofMode = dnsspoofer.Proxy
+	default:
+		return nil, ErrInvalidSpoofMode
+	}
+
+	var scope dnsspoofer.Scope
+	switch opts.ScopeStr {
+	case "local":
+		scope = dnsspoofer.Local
+	case "remote":
+		scope = dnsspoofer.Remote
+	default:
+		return nil, ErrInvalidScope
+	}
+
+	if opts.QueueInt < 0 || opts.QueueCount < 1 || opts.QueueInt+opts.QueueCount-1 > math.MaxUint16 {
+		return nil, ErrInvalidQueue
+	}
+
+	var backend dnsspoofer.Backend
+	switch opts.BackendStr {
+	case "nftables":
+		backend = dnsspoofer.NFTables
+	case "iptables":
+		backend = dnsspoofer.IPTables
+	default:
+		return nil, ErrInvalidBackend
+	}
+
+	var hook dnsspoofer.Hook
+	switch opts.HookStr {
+	case "auto":
+		hook = dnsspoofer.HookAuto
+	case "prerouting":
+		hook = dnsspoofer.HookPrerouting
+	case "postrouting":
+		hook = dnsspoofer.HookPostrouting
+	default:
+		return nil, ErrInvalidHook
+	}
+
+	if opts.Priority < math.MinInt32 || opts.Priority > math.MaxInt32 {
+		return nil, ErrInvalidPriority
+	}
+	if (opts.NFTTable == "") != (opts.NFTChain == "") {
+		return nil, ErrInvalidNFTTarget
+	}
+
+	if opts.ProxyPort < 1 || opts.ProxyPort > math.MaxUint16 {
+		return nil, ErrInvalidProxyPort
+	}
+	cacheSize := opts.CacheSize
+	if cacheSize == 0 {
+		cacheSize = -1
+	}
+
+	return &dnsspoofer.EngineOptions{
+		Iface:         ifaceHandle,
+		IPMode:        ipMode,
+		SpoofMode:     spoofMode,
+		Scope:         scope,
+		Queue:         uint16(opts.QueueInt),
+		QueueCount:    uint16(opts.QueueCount),
+		FailClosed:    opts.FailClosed,
+		Backend:       backend,
+		Hook:          hook,
+		ChainPriority: int32(opts.Priority),
+		Table:         opts.NFTTable,
+		Chain:         opts.NFTChain,
+		ProxyPort:     uint16(opts.ProxyPort),
+		Upstream:      opts.Upstream,
+		CacheSize:     cacheSize,
+		StripDNSSEC:   opts.StripDNSSEC,
+	}, nil
+}
+
+// printRuleset prints the nftables ruleset an engine with engineOpts would install.
+func printRuleset(engineOpts *dnsspoofer.EngineOptions) error {
+	ruleset, err := dnsspoofer.New(engineOpts).Ruleset()
+	if err != nil {
+		return err
+	}
+	fmt.Print(ruleset)
+	return nil
+}
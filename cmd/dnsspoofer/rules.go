@@ -0,0 +1,25 @@
+package main
+
+import "github.com/urfave/cli/v2"
+
+// rulesCommand inspects the nftables rules the root flags in opts select.
+func rulesCommand(opts *options) *cli.Command {
+	return &cli.Command{
+		Name:  "rules",
+		Usage: "Inspect the nftables rules selected by the global flags",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "show",
+				Usage:     "Print the nftables ruleset, or iptables commands, that would be installed without touching the kernel",
+				UsageText: "dnsspoofer --interface eth0 [--spoof-mode ...] [--scope ...] rules show",
+				Action: func(c *cli.Context) error {
+					engineOpts, err := netOptions(opts)
+					if err != nil {
+						return err
+					}
+					return printRuleset(engineOpts)
+				},
+			},
+		},
+	}
+}
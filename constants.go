@@ -4,9 +4,14 @@ import (
 	"context"
 	"net"
 	"regexp"
+	"sync/atomic"
+	"time"
 
+	"github.com/Onyz107/dnsspoofer/internal/dns"
 	"github.com/Onyz107/dnsspoofer/internal/logger"
 	"github.com/Onyz107/dnsspoofer/internal/nftables"
+	"github.com/Onyz107/dnsspoofer/internal/proxy"
+	gonfqueue "github.com/florianl/go-nfqueue/v2"
 )
 
 // IPMode determines the IP spoofing mode.
@@ -18,6 +23,15 @@ type SpoofMode = nftables.SpoofMode
 // Scope determines the scope for DNS spoofing.
 type Scope = nftables.Scope
 
+// Hook overrides the netfilter hook the nftables rule attaches to.
+type Hook = nftables.Hook
+
+// Backend selects the firewall the queueing rules are installed with.
+type Backend uint32
+
+// Record is a decoded DNS resource record.
+type Record = dns.Record
+
 // Logger is the logging interface used by the DNS spoofer engine
 type Logger interface {
 	logger.Logger
@@ -33,10 +47,16 @@ const (
 )
 
 const (
-	// Aggressive SpoofMode intercepts DNS requests and responds to them, then eventually drops the request.
+	// Aggressive SpoofMode intercepts DNS requests, injects a forged reply toward the client and drops the request.
 	Aggressive SpoofMode = nftables.Aggressive
 	// Passive SpoofMode intercepts DNS responses and modifies them.
 	Passive SpoofMode = nftables.Passive
+	// Hybrid SpoofMode answers DNS requests and drops the genuine responses of the answered transactions,
+	// the responses of other transactions are modified as in Passive SpoofMode.
+	Hybrid SpoofMode = nftables.Hybrid
+	// Proxy SpoofMode redirects DNS requests, over UDP and TCP, to a local resolver answering from the rules
+	// and forwarding the other requests to EngineOptions.Upstream.
+	Proxy SpoofMode = nftables.Proxy
 )
 
 const (
@@ -46,6 +66,92 @@ const (
 	Remote Scope = nftables.Remote
 )
 
+const (
+	// HookAuto uses OUTPUT or INPUT depending on the SpoofMode, or FORWARD for the Remote Scope
+	HookAuto Hook = nftables.HookAuto
+	// HookPrerouting matches packets entering Iface, before routing, whether local or forwarded
+	HookPrerouting Hook = nftables.HookPrerouting
+	// HookPostrouting matches packets leaving Iface, after routing, whether local or forwarded
+	HookPostrouting Hook = nftables.HookPostrouting
+)
+
+const (
+	// NFTables installs the rules with nftables
+	NFTables Backend = iota
+	// IPTables installs the rules with the iptables and ip6tables commands, for kernels without nftables
+	IPTables
+)
+
+const (
+	// DefaultBacklog is the default number of packets buffered between NFQUEUE and the workers.
+	DefaultBacklog = 1024
+	// DefaultMaxQueueLen is the default number of packets the kernel holds in each NFQUEUE.
+	DefaultMaxQueueLen = 1024
+	// DefaultMaxPacketLen is the default number of bytes copied from each queued packet,
+	// large enough that big EDNS answers are never truncated.
+	DefaultMaxPacketLen = 0xffff
+	// DefaultProxyPort is the default local port of the Proxy SpoofMode resolver.
+	DefaultProxyPort = 10053
+	// DefaultCacheSize is the default number of upstream answers cached in Proxy SpoofMode.
+	DefaultCacheSize = 4096
+)
+
+// answeredTTL is how long Hybrid SpoofMode remembers an answered transaction, well above DNS client timeouts.
+const answeredTTL = 30 * time.Second
+
+// EventType is the kind of decision reported by an Event.
+type EventType uint32
+
+const (
+	// EventQuery reports a parsed DNS request or response.
+	EventQuery EventType = iota
+	// EventMatch reports a packet the Decider chose to answer or drop.
+	EventMatch
+	// EventSpoof reports a packet accepted with forged answers.
+	EventSpoof
+	// EventAccept reports a packet accepted untouched because the Decider passed it.
+	EventAccept
+	// EventError reports a packet given the fail verdict because of an error.
+	EventError
+	// EventDrop reports a packet dropped because the Decider said so.
+	EventDrop
+)
+
+// Event describes one step of the engine handling a DNS packet, see EngineOptions.OnEvent.
+type Event struct {
+	// Type is the kind of event
+	Type EventType
+	// Time is when the event happened
+	Time time.Time
+	// Client is the address of the DNS client, nil if the packet could not be parsed
+	Client net.IP
+	// Server is the address of the DNS server, nil if the packet could not be parsed
+	Server net.IP
+	// Name is the queried name, lowercased and without the trailing dot
+	Name string
+	// QType is the record type of the first question (A, AAAA, ...)
+	QType string
+	// IsRequest reports whether the packet was a request (aggressive mode) or a response (passive mode)
+	IsRequest bool
+	// Rules are the rules behind the decision, set from EventMatch on
+	Rules []string
+	// Original are the answers of the packet as queued
+	Original []Record
+	// Forged are the answers sent instead, only set on EventSpoof
+	Forged []Record
+	// Latency is the time since the packet was dequeued, set on EventSpoof, EventAccept, EventDrop and EventError
+	Latency time.Duration
+	// Err is the failure, only set on EventError
+	Err error
+	// Verdict is the NFQUEUE verdict given to the packet, "accept" or "drop",
+	// set on EventSpoof, EventAccept, EventDrop and EventError
+	Verdict string
+	// Packet is the queued packet starting at the IP header, nil in Proxy SpoofMode
+	Packet []byte
+	// ForgedPacket is the packet sent instead of Packet, only set on EventSpoof outside Proxy SpoofMode
+	ForgedPacket []byte
+}
+
 // Engine is the main DNS spoofer engine
 type Engine struct {
 	// ctx is the context for the engine
@@ -54,25 +160,233 @@ type Engine struct {
 	cancel context.CancelFunc
 	// opts holds the configuration options
 	opts *EngineOptions
+	// decider is opts.Decider wrapped with the middlewares
+	decider Decider
+	// stats holds the packet counters
+	stats *stats
+	// paused passes every packet untouched while set
+	paused atomic.Bool
+	// answered holds the transactions answered in Hybrid SpoofMode
+	answered *answered
+	// injector sends the replies forged from requests, nil in Passive SpoofMode
+	injector sender
+	// forwarder resolves the passed requests in Proxy SpoofMode
+	forwarder *proxy.Forwarder
+	// upstream is the address of the forwarder upstream, nil if it is a hostname
+	upstream net.IP
+}
+
+// verdicter issues the verdicts of queued packets, implemented by *gonfqueue.Nfqueue.
+type verdicter interface {
+	SetVerdict(id uint32, verdict int) error
+	SetVerdictWithOption(id uint32, verdict int, options ...gonfqueue.VerdictOption) error
 }
 
-// Hosts represents a mapping of hostnames to IP addresses
+// sender sends forged replies toward their client, implemented by *inject.Injector.
+type sender interface {
+	Send(pkt []byte) error
+}
+
+// Stats is a snapshot of the engine counters, see Engine.Stats.
+type Stats struct {
+	// Packets is the number of packets handed to the workers
+	Packets uint64
+	// Matched is the number of packets the Decider chose to answer or drop
+	Matched uint64
+	// Spoofed is the number of packets accepted with a spoofed payload
+	Spoofed uint64
+	// Skipped is the number of packets accepted unmodified because the Decider passed them
+	Skipped uint64
+	// Dropped is the number of packets dropped because the Decider said so
+	Dropped uint64
+	// Failed is the number of packets given the fail verdict because of an error
+	Failed uint64
+	// QueueFull is the number of packets given the fail verdict because a backlog was full
+	QueueFull uint64
+
+	// KernelPackets is the number of packets the kernel queued, from the counter of the installed rules.
+	// It restarts whenever Run installs the rules and keeps its last value once they are removed.
+	// Queued packets missing from Packets were given the fail verdict by the kernel, e.g. because
+	// the NFQUEUE was full.
+	KernelPackets uint64
+	// KernelBytes is the number of bytes the kernel queued, see KernelPackets
+	KernelBytes uint64
+
+	// Records counts parsed packets per question record type (A, AAAA, ...)
+	Records map[string]uint64
+	// Rules counts decisions per rule (hosts pattern for the default Decider)
+	Rules map[string]uint64
+	// Clients counts parsed packets per client address (source of requests, destination of responses)
+	Clients map[string]uint64
+	// Errors counts failures per sentinel error (ErrParsePacket, ErrSpoofPacket, ...)
+	Errors map[error]uint64
+	// ParseErrors counts ErrParsePacket failures per underlying cause (invalid IP, UDP or DNS layer, ...)
+	ParseErrors map[error]uint64
+
+	// SpoofLatency is the time from dequeuing to issuing the verdict of spoofed packets
+	SpoofLatency Histogram
+	// Backlog is the number of packets currently waiting for a worker, summed over all queues
+	Backlog int
+	// RulesInstalled reports whether the nftables rules are currently installed
+	RulesInstalled bool
+}
+
+// Histogram is a snapshot of a latency distribution.
+type Histogram struct {
+	// Count is the number of observations
+	Count uint64
+	// Sum is the total of all observations
+	Sum time.Duration
+	// Buckets maps each bound in LatencyBuckets to the number of observations less than or equal to it
+	Buckets map[time.Duration]uint64
+}
+
+// LatencyBuckets are the upper bounds of the Stats.SpoofLatency buckets.
+var LatencyBuckets = []time.Duration{
+	50 * time.Microsecond,
+	100 * time.Microsecond,
+	250 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	2500 * time.Microsecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+}
+
+// Hosts represents a mapping of hostnames to IP addresses.
+//
+// Hosts is the default Decider: names matching one or more patterns are answered with all their IPs.
 type Hosts map[*regexp.Regexp][]net.IP
 
+// Action is what the engine does with a packet, see Decision.
+type Action uint32
+
+const (
+	// Pass accepts the packet untouched.
+	Pass Action = iota
+	// Drop drops the packet.
+	Drop
+	// Answer forges an answer from Decision.IPs, or an empty response with Decision.RCode.
+	Answer
+)
+
+// RCode is a DNS response code.
+type RCode uint8
+
+const (
+	// RCodeNoError answers normally (NOERROR).
+	RCodeNoError RCode = 0
+	// RCodeServFail answers with a server failure (SERVFAIL).
+	RCodeServFail RCode = 2
+	// RCodeNXDomain answers that the name does not exist (NXDOMAIN).
+	RCodeNXDomain RCode = 3
+	// RCodeRefused answers that the query was refused (REFUSED).
+	RCodeRefused RCode = 5
+)
+
+// Query is a parsed DNS request or response handed to a Decider.
+type Query struct {
+	// Client is the address of the DNS client
+	Client net.IP
+	// Server is the address of the DNS server
+	Server net.IP
+	// Name is the queried name, lowercased and without the trailing dot
+	Name string
+	// QType is the record type of the first question (A, AAAA, ...)
+	QType string
+	// IsRequest reports whether the packet is a request (aggressive mode) or a response (passive mode)
+	IsRequest bool
+	// Answers are the answers of a response, empty for requests
+	Answers []Record
+}
+
+// Decision is the outcome of a Decider for a Query.
+type Decision struct {
+	// Action is what to do with the packet
+	Action Action
+	// IPs are the addresses to answer with, A records get the IPv4 ones and AAAA records the IPv6 ones
+	IPs []net.IP
+	// RCode, if not RCodeNoError, answers with an empty response carrying this code instead of IPs
+	RCode RCode
+	// TTL is the TTL of the forged answers, if 0 the default of 60 seconds is used
+	TTL uint32
+	// Rules names the rules behind the decision, reported in Stats and Events
+	Rules []string
+}
+
+// Decider decides what the engine does with each parsed DNS packet.
+//
+// Decide is called concurrently from the workers.
+type Decider interface {
+	Decide(ctx context.Context, q *Query) Decision
+}
+
+// DeciderFunc adapts a function to a Decider.
+type DeciderFunc func(ctx context.Context, q *Query) Decision
+
 // EngineOptions holds the configuration options for the DNS spoofer engine
 type EngineOptions struct {
 	// Iface is the network interface to use
 	Iface *net.Interface
 	// IPMode is the IP mode to use (IPv4, IPv6, or both)
 	IPMode IPMode
-	// SpoofMode is the spoofing mode to use (aggressive or passive)
+	// SpoofMode is the spoofing mode to use (aggressive, passive, hybrid or proxy)
 	SpoofMode SpoofMode
 	// Scope is the packet scope to use (local or remote)
 	Scope Scope
-	// Hosts is the mapping of hostnames to IP addresses
+	// Backend is the firewall the rules are installed with, nftables by default.
+	// ChainPriority, Table and Chain are not supported by IPTables.
+	Backend Backend
+	// Hook overrides the hook chosen from SpoofMode and Scope, e.g. to catch packets before another
+	// firewall accepts or drops them
+	Hook Hook
+	// ChainPriority is the priority of the nftables chain, 0 being the filter priority and lower values running first
+	ChainPriority int32
+	// Table and Chain name an existing table, as "family name" (e.g. "inet filter"), and one of its base chains.
+	// When set, the rule is inserted at the top of that chain instead of in a new dnsspoofer table,
+	// and Hook and ChainPriority are those of the existing chain.
+	Table string
+	Chain string
+	// ProxyPort is the local port DNS requests are redirected to in Proxy SpoofMode, DefaultProxyPort if 0
+	ProxyPort uint16
+	// Upstream is the DNS server passed requests are forwarded to in Proxy SpoofMode, as host or host:port
+	Upstream string
+	// CacheSize is the number of upstream answers cached in Proxy SpoofMode, DefaultCacheSize if 0 and none if negative
+	CacheSize int
+	// StripDNSSEC removes the RRSIG, NSEC and NSEC3 records from the upstream answers in Proxy SpoofMode
+	StripDNSSEC bool
+	// Hosts is the mapping of hostnames to IP addresses, used when Decider is nil
 	Hosts Hosts
-	// Queue is the NFQUEUE number to use
+	// Decider decides what to do with each packet, if nil Hosts is used
+	Decider Decider
+	// Middleware wraps Decider, the first middleware being the outermost one.
+	// Logging with Log is always installed around the whole chain.
+	Middleware []Middleware
+	// Queue is the NFQUEUE number to use, or the first one of the range when QueueCount is greater than 1
 	Queue uint16
+	// QueueCount is the number of consecutive NFQUEUE numbers starting at Queue, if 0 only Queue is used.
+	// Packets are fanned out across the queues by CPU and each queue gets its own workers.
+	QueueCount uint16
+	// Workers is the number of goroutines processing packets per queue, if 0 runtime.NumCPU() is used
+	Workers int
+	// Backlog is the number of packets buffered for the workers, if 0 DefaultBacklog is used.
+	// Packets arriving while the backlog is full are accepted unmodified, or dropped if FailClosed is set.
+	Backlog int
+	// MaxQueueLen is the number of packets the kernel holds in each NFQUEUE, if 0 DefaultMaxQueueLen is used
+	MaxQueueLen uint32
+	// MaxPacketLen is the copy range, the number of bytes copied from each packet, if 0 DefaultMaxPacketLen is used
+	MaxPacketLen uint32
+	// FailClosed drops packets instead of accepting them unmodified when they cannot be handled:
+	// no program bound to the queue, queue or backlog full, or a failure while spoofing.
+	FailClosed bool
+	// GSO lets the kernel queue GSO packets without segmenting them first
+	GSO bool
 	// Log is the logger to use, if nil a dev/null logger is used
 	Log Logger
+	// OnEvent, if not nil, is called for every step of handling a packet.
+	// It is called synchronously from the workers, so it must be safe for concurrent use and return quickly.
+	OnEvent func(Event)
 }
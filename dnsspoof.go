@@ -3,14 +3,20 @@ package dnsspoofer
 import (
 	"context"
 	"errors"
-	"net"
+	"fmt"
+	"runtime"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/Onyz107/dnsspoofer/internal/dns"
+	"github.com/Onyz107/dnsspoofer/internal/inject"
+	"github.com/Onyz107/dnsspoofer/internal/iptables"
 	"github.com/Onyz107/dnsspoofer/internal/logger"
 	"github.com/Onyz107/dnsspoofer/internal/nfqueue"
 	"github.com/Onyz107/dnsspoofer/internal/nftables"
 	gonfqueue "github.com/florianl/go-nfqueue/v2"
+	"github.com/google/gopacket/layers"
 	"golang.org/x/sys/unix"
 )
 
@@ -19,117 +25,460 @@ func New(opts *EngineOptions) *Engine {
 	if opts.Log == nil {
 		opts.Log = new(logger.NopLogger)
 	}
+	if opts.Decider == nil {
+		opts.Decider = opts.Hosts
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = runtime.NumCPU()
+	}
+	if opts.QueueCount == 0 {
+		opts.QueueCount = 1
+	}
+	if opts.Backlog <= 0 {
+		opts.Backlog = DefaultBacklog
+	}
+	if opts.MaxQueueLen == 0 {
+		opts.MaxQueueLen = DefaultMaxQueueLen
+	}
+	if opts.MaxPacketLen == 0 {
+		opts.MaxPacketLen = DefaultMaxPacketLen
+	}
+	if opts.ProxyPort == 0 {
+		opts.ProxyPort = DefaultProxyPort
+	}
+	if opts.CacheSize == 0 {
+		opts.CacheSize = DefaultCacheSize
+	}
 	engine := &Engine{
-		opts: opts,
+		opts:     opts,
+		decider:  Chain(opts.Decider, append([]Middleware{Logging(opts.Log)}, opts.Middleware...)...),
+		stats:    newStats(),
+		answered: newAnswered(),
 	}
 	return engine
 }
 
+// nftOptions returns the parameters of the rules Run installs, whatever the backend.
+func (e *Engine) nftOptions() *nftables.Options {
+	return &nftables.Options{
+		IPMode:     e.opts.IPMode,
+		Iface:      e.opts.Iface,
+		SpoofMode:  e.opts.SpoofMode,
+		Scope:      e.opts.Scope,
+		Queue:      e.opts.Queue,
+		QueueTotal: e.opts.QueueCount,
+		FailClosed: e.opts.FailClosed,
+		Hook:       e.opts.Hook,
+		Priority:   e.opts.ChainPriority,
+		Table:      e.opts.Table,
+		Chain:      e.opts.Chain,
+		ProxyPort:  e.opts.ProxyPort,
+	}
+}
+
+// Ruleset returns the rules Run would install, without touching the kernel: the ruleset in nft syntax,
+// or the command lines for the IPTables backend.
+//
+// Returns an error if the engine options are invalid.
+func (e *Engine) Ruleset() (string, error) {
+	var render func(context.Context, *nftables.Options) (string, error)
+	switch e.opts.Backend {
+	case NFTables:
+		render = nftables.Render
+	case IPTables:
+		render = iptables.Render
+	default:
+		return "", ErrInvalidBackend
+	}
+
+	rs, err := render(logger.WithLogger(context.Background(), e.opts.Log), e.nftOptions())
+	if err != nil {
+		return "", errors.Join(ErrAddDNSQueue, err)
+	}
+	return rs, nil
+}
+
 // Run starts the DNS spoofing engine.
+//
+// One NFQUEUE socket is opened per queue in the configured range, each served by its own workers.
+// Run blocks until ctx is cancelled or Stop is called, and only returns once every worker has exited.
 func (e *Engine) Run(ctx context.Context) error {
 	inCtx, cancel := context.WithCancel(ctx)
 	e.ctx = logger.WithLogger(inCtx, e.opts.Log)
 	e.cancel = cancel
 
-	clean, err := nftables.AddDNSQueue(e.ctx, e.opts.IPMode, e.opts.Iface, e.opts.SpoofMode, e.opts.Scope, e.opts.Queue)
-	if err != nil {
+	addDNSQueue := nftables.AddDNSQueue
+	switch e.opts.Backend {
+	case NFTables:
+	case IPTables:
+		addDNSQueue = iptables.AddDNSQueue
+	default:
 		e.cancel()
-		return errors.Join(ErrAddDNSQueue, err)
+		return ErrInvalidBackend
 	}
-	e.cancel = func() {
-		cancel()
-		clean()
-	}
-
-	nfq, err := gonfqueue.Open(&gonfqueue.Config{
-		NfQueue:      e.opts.Queue,
-		MaxQueueLen:  1024,
-		MaxPacketLen: 2048,
-		Copymode:     gonfqueue.NfQnlCopyPacket,
-		Flags:        gonfqueue.NfQaCfgFlagFailOpen,
-		AfFamily:     unix.AF_UNSPEC,
-		Logger:       e.opts.Log,
-	})
+
+	if e.opts.SpoofMode == Proxy {
+		srv, err := e.listenProxy()
+		if err != nil {
+			e.cancel()
+			return err
+		}
+		defer srv.Close()
+	}
+
+	// Replies forged from requests are injected toward the client, the requests are dropped.
+	if e.opts.SpoofMode == Aggressive || e.opts.SpoofMode == Hybrid {
+		injector, err := inject.Open(e.opts.IPMode != IPv6Only, e.opts.IPMode != IPv4Only)
+		if err != nil {
+			e.cancel()
+			return errors.Join(ErrOpenInjector, err)
+		}
+		e.injector = injector
+		defer injector.Close()
+	}
+
+	rules, err := addDNSQueue(e.ctx, e.nftOptions())
 	if err != nil {
 		e.cancel()
-		return errors.Join(ErrOpenNFQueue, err)
+		return errors.Join(ErrAddDNSQueue, err)
+	}
+	e.stats.installed.Store(true)
+	e.stats.setQueueRules(rules)
+
+	var flags uint32
+	if !e.opts.FailClosed {
+		flags |= gonfqueue.NfQaCfgFlagFailOpen
+	}
+	if e.opts.GSO {
+		flags |= gonfqueue.NfQaCfgFlagGSO
 	}
-	e.cancel = func() {
+
+	var wg sync.WaitGroup
+	var nfqs []*gonfqueue.Nfqueue
+	var backlogs []<-chan nfqueue.Packet
+	defer func() {
 		cancel()
-		nfq.Close()
-		clean()
+		wg.Wait()
+		e.stats.setBacklogs(nil)
+		for _, nfq := range nfqs {
+			nfq.Close()
+		}
+		// The last kernel counters outlive the rules.
+		if err := e.stats.readKernel(); err != nil {
+			e.opts.Log.Debug(ErrReadCounter.Error(), "err", err)
+		}
+		e.stats.setQueueRules(nil)
+		if err := rules.Remove(); err != nil {
+			e.opts.Log.Error(ErrRemoveDNSQueue.Error(), "err", err)
+			return
+		}
+		e.stats.installed.Store(false)
+	}()
+
+	// The proxy answers the redirected requests, nothing is queued.
+	if e.opts.SpoofMode == Proxy {
+		<-e.ctx.Done()
+		return nil
 	}
-	defer e.cancel()
 
-	pkts, err := nfqueue.GetPacketChan(e.ctx, nfq)
-	if err != nil {
-		return errors.Join(ErrGetPacketChan, err)
+	for i := range e.opts.QueueCount {
+		queue := e.opts.Queue + i
+
+		nfq, err := gonfqueue.Open(&gonfqueue.Config{
+			NfQueue:      queue,
+			MaxQueueLen:  e.opts.MaxQueueLen,
+			MaxPacketLen: e.opts.MaxPacketLen,
+			Copymode:     gonfqueue.NfQnlCopyPacket,
+			Flags:        flags,
+			AfFamily:     unix.AF_UNSPEC,
+			Logger:       e.opts.Log,
+		})
+		if err != nil {
+			return errors.Join(ErrOpenNFQueue, err)
+		}
+		nfqs = append(nfqs, nfq)
+
+		pkts, err := nfqueue.GetPacketChan(e.ctx, nfq, e.opts.Backlog, e.failVerdict(), func() {
+			e.stats.queueFull.Add(1)
+		})
+		if err != nil {
+			return errors.Join(ErrGetPacketChan, err)
+		}
+		backlogs = append(backlogs, pkts)
+		e.stats.setBacklogs(backlogs)
+
+		for range e.opts.Workers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				e.worker(nfq, pkts)
+			}()
+		}
+		e.opts.Log.Debug("started packet workers", "queue", queue, "workers", e.opts.Workers, "backlog", e.opts.Backlog)
+	}
+
+	<-e.ctx.Done()
+	return nil
+}
+
+// Stats returns a snapshot of the engine counters.
+//
+// It is safe to call concurrently with Run, counters keep accumulating across runs.
+// The kernel counters are read from the installed rules on every call.
+func (e *Engine) Stats() Stats {
+	if err := e.stats.readKernel(); err != nil {
+		e.opts.Log.Debug(ErrReadCounter.Error(), "err", err)
+	}
+	return e.stats.snapshot()
+}
+
+// Backend returns the firewall Run installs the queueing rules with.
+func (e *Engine) Backend() Backend {
+	return e.opts.Backend
+}
+
+// InstalledRules returns the queueing rules Run installed, in nft syntax or as iptables command lines,
+// "" while none are installed.
+func (e *Engine) InstalledRules() string {
+	return e.stats.queueRulesString()
+}
+
+// Pause stops spoofing: packets keep flowing through the queue but are all passed untouched.
+func (e *Engine) Pause() {
+	e.paused.Store(true)
+}
+
+// Resume resumes spoofing after Pause.
+func (e *Engine) Resume() {
+	e.paused.Store(false)
+}
+
+// Paused reports whether spoofing is paused.
+func (e *Engine) Paused() bool {
+	return e.paused.Load()
+}
+
+// Stop stops the DNS spoofing engine.
+func (e *Engine) Stop() {
+	if e.cancel != nil {
+		e.cancel()
 	}
+}
 
+// worker handles packets from pkts until the engine context is done.
+//
+// The netlink connection behind nfq is safe for concurrent use, so workers issue their verdicts directly.
+func (e *Engine) worker(nfq *gonfqueue.Nfqueue, pkts <-chan nfqueue.Packet) {
 	for {
 		select {
 		case <-e.ctx.Done():
-			return nil
+			return
 		case pkt := <-pkts:
-			parsed, err := dns.ParsePacket(e.ctx, pkt)
-			if err != nil {
-				e.opts.Log.Error(ErrParsePacket.Error(), "err", err)
-				nfq.SetVerdict(pkt.PacketID, gonfqueue.NfAccept)
-				continue
-			}
-			e.opts.Log.Info("parsed packet", parsed.LogFields()...)
-
-			var name string
-			if len(parsed.DNS.Answers) > 0 {
-				name = string(parsed.DNS.Answers[0].Name)
-			} else if len(parsed.DNS.Questions) > 0 {
-				name = string(parsed.DNS.Questions[0].Name)
-			}
-			name = strings.ToLower(strings.TrimSuffix(name, "."))
-
-			var ips []net.IP
-			for re, ipaddrs := range e.opts.Hosts {
-				if re.MatchString(name) {
-					ips = append(ips, ipaddrs...)
-				}
-			}
-			if len(ips) == 0 {
-				e.opts.Log.Info("parsed packet not in hosts list, skipping")
-				nfq.SetVerdict(pkt.PacketID, gonfqueue.NfAccept)
-				continue
-			}
-
-			var spoofed *dns.ParsedPacket
-			if parsed.IsRequest {
-				spoofed, err = dns.SpoofRequest(parsed, ips...)
-			} else {
-				spoofed, err = dns.SpoofResponse(parsed, ips...)
-			}
-			if err != nil {
-				e.opts.Log.Error(ErrSpoofPacket.Error(), "err", err)
-				nfq.SetVerdict(pkt.PacketID, gonfqueue.NfAccept)
-				continue
-			}
-			e.opts.Log.Info("spoofed packet", spoofed.LogFields()...)
-
-			newBytes, err := spoofed.Serialize()
-			if err != nil {
-				e.opts.Log.Error(ErrSerializePkt.Error(), "err", err)
-				nfq.SetVerdict(pkt.PacketID, gonfqueue.NfAccept)
-				continue
-			}
-
-			if err := nfq.SetVerdictWithOption(pkt.PacketID, gonfqueue.NfAccept, gonfqueue.WithAlteredPacket(newBytes)); err != nil {
-				e.opts.Log.Error(ErrSetVerdict.Error(), "err", err)
-				nfq.SetVerdict(pkt.PacketID, gonfqueue.NfAccept)
-			}
+			e.handlePacket(nfq, pkt)
 		}
 	}
 }
 
-// Stop stops the DNS spoofing engine.
-func (e *Engine) Stop() {
-	if e.cancel != nil {
-		e.cancel()
+// failVerdict returns the verdict for packets that could not be handled.
+func (e *Engine) failVerdict() int {
+	if e.opts.FailClosed {
+		return gonfqueue.NfDrop
+	}
+	return gonfqueue.NfAccept
+}
+
+// verdictName returns the name of an NFQUEUE verdict as reported in Events.
+func verdictName(verdict int) string {
+	if verdict == gonfqueue.NfDrop {
+		return "drop"
+	}
+	return "accept"
+}
+
+// emit calls OnEvent with ev, if set.
+func (e *Engine) emit(ev Event) {
+	if e.opts.OnEvent == nil {
+		return
+	}
+	ev.Time = time.Now()
+	e.opts.OnEvent(ev)
+}
+
+// fail gives pkt the failVerdict after a failure with the sentinel error kind.
+func (e *Engine) fail(nfq verdicter, pkt nfqueue.Packet, ev Event, start time.Time, kind, err error) {
+	e.stats.fail(kind)
+	e.opts.Log.Error(kind.Error(), "err", err)
+	nfq.SetVerdict(pkt.PacketID, e.failVerdict())
+
+	ev.Type = EventError
+	ev.Latency = time.Since(start)
+	ev.Err = errors.Join(kind, err)
+	ev.Verdict = verdictName(e.failVerdict())
+	e.emit(ev)
+}
+
+// handlePacket parses a single packet, asks the Decider what to do with it, then issues its verdict.
+//
+// Every failure gives the original packet the failVerdict.
+func (e *Engine) handlePacket(nfq verdicter, pkt nfqueue.Packet) {
+	start := time.Now()
+	e.stats.packets.Add(1)
+
+	parsed, err := dns.ParsePacket(e.ctx, pkt)
+	if err != nil {
+		e.stats.parseFail(dns.ParseErrorKind(err))
+		e.opts.Log.Error(ErrParsePacket.Error(), "err", err)
+		nfq.SetVerdict(pkt.PacketID, e.failVerdict())
+		e.emit(Event{
+			Type:    EventError,
+			Latency: time.Since(start),
+			Err:     errors.Join(ErrParsePacket, err),
+			Verdict: verdictName(e.failVerdict()),
+			Packet:  pkt.Payload,
+		})
+		return
+	}
+	e.opts.Log.Info("parsed packet", parsed.LogFields()...)
+	e.stats.parsed(parsed.Record, parsed.Client().String())
+
+	var name string
+	if len(parsed.DNS.Answers) > 0 {
+		name = string(parsed.DNS.Answers[0].Name)
+	} else if len(parsed.DNS.Questions) > 0 {
+		name = string(parsed.DNS.Questions[0].Name)
+	}
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+
+	q := &Query{
+		Client:    parsed.Client(),
+		Server:    parsed.Server(),
+		Name:      name,
+		QType:     parsed.Record,
+		IsRequest: parsed.IsRequest,
+		Answers:   parsed.Answers(),
 	}
+	ev := Event{
+		Type:      EventQuery,
+		Client:    q.Client,
+		Server:    q.Server,
+		Name:      q.Name,
+		QType:     q.QType,
+		IsRequest: q.IsRequest,
+		Original:  q.Answers,
+		Packet:    pkt.Payload,
+	}
+	e.emit(ev)
+
+	// The client already got the forged answer, the genuine one must not reach it.
+	if e.opts.SpoofMode == Hybrid && !parsed.IsRequest && e.answered.has(parsed.Transaction(), start) {
+		e.stats.dropped.Add(1)
+		nfq.SetVerdict(pkt.PacketID, gonfqueue.NfDrop)
+
+		ev.Type = EventDrop
+		ev.Latency = time.Since(start)
+		ev.Verdict = verdictName(gonfqueue.NfDrop)
+		e.emit(ev)
+		return
+	}
+
+	// Requests can only be answered through the injector, opened in Aggressive and Hybrid SpoofMode.
+	// Others, e.g. queries sent from port 53 in Passive SpoofMode, are passed untouched.
+	var d Decision
+	if !e.paused.Load() && (!parsed.IsRequest || e.injector != nil) {
+		d = e.decider.Decide(e.ctx, q)
+	}
+	for _, rule := range d.Rules {
+		e.stats.rule(rule)
+	}
+	ev.Rules = d.Rules
+
+	switch d.Action {
+	case Pass:
+		e.stats.skipped.Add(1)
+		nfq.SetVerdict(pkt.PacketID, gonfqueue.NfAccept)
+
+		ev.Type = EventAccept
+		ev.Latency = time.Since(start)
+		ev.Verdict = verdictName(gonfqueue.NfAccept)
+		e.emit(ev)
+		return
+
+	case Drop:
+		e.stats.matched.Add(1)
+		e.stats.dropped.Add(1)
+		ev.Type = EventMatch
+		e.emit(ev)
+
+		nfq.SetVerdict(pkt.PacketID, gonfqueue.NfDrop)
+
+		ev.Type = EventDrop
+		ev.Latency = time.Since(start)
+		ev.Verdict = verdictName(gonfqueue.NfDrop)
+		e.emit(ev)
+		return
+
+	case Answer:
+		e.stats.matched.Add(1)
+		ev.Type = EventMatch
+		e.emit(ev)
+
+	default:
+		e.fail(nfq, pkt, ev, start, ErrInvalidAction, fmt.Errorf("action %d", d.Action))
+		return
+	}
+
+	// SpoofRequest turns parsed into the reply in place, what it was is needed once spoofed.
+	isRequest, txn := parsed.IsRequest, parsed.Transaction()
+
+	rcode := layers.DNSResponseCode(d.RCode)
+	var spoofed *dns.ParsedPacket
+	if isRequest {
+		spoofed, err = dns.SpoofRequest(parsed, rcode, d.TTL, d.IPs...)
+	} else {
+		spoofed, err = dns.SpoofResponse(parsed, rcode, d.TTL, d.IPs...)
+	}
+	if err != nil {
+		e.fail(nfq, pkt, ev, start, ErrSpoofPacket, err)
+		return
+	}
+	e.opts.Log.Info("spoofed packet", spoofed.LogFields()...)
+
+	newBytes, err := spoofed.Serialize()
+	if err != nil {
+		e.fail(nfq, pkt, ev, start, ErrSerializePkt, err)
+		return
+	}
+
+	verdict := gonfqueue.NfAccept
+	if isRequest {
+		// Remembered before the injection so a genuine response can never overtake it,
+		// and forgotten if it fails since the request may then still reach the server.
+		if e.opts.SpoofMode == Hybrid {
+			e.answered.add(txn, start)
+		}
+		// Accepting the rewritten request would send the reply on along the request path,
+		// the kernel routes the injected one toward the client instead.
+		if err := e.injector.Send(newBytes); err != nil {
+			e.answered.remove(txn)
+			e.fail(nfq, pkt, ev, start, ErrInjectPacket, err)
+			return
+		}
+		verdict = gonfqueue.NfDrop
+		if err := nfq.SetVerdict(pkt.PacketID, verdict); err != nil {
+			e.fail(nfq, pkt, ev, start, ErrSetVerdict, err)
+			return
+		}
+	} else if err := nfq.SetVerdictWithOption(pkt.PacketID, verdict, gonfqueue.WithAlteredPacket(newBytes)); err != nil {
+		e.fail(nfq, pkt, ev, start, ErrSetVerdict, err)
+		return
+	}
+	latency := time.Since(start)
+	e.stats.spoof(latency)
+
+	ev.Type = EventSpoof
+	ev.Forged = spoofed.Answers()
+	ev.Latency = latency
+	ev.Verdict = verdictName(verdict)
+	ev.ForgedPacket = newBytes
+	e.emit(ev)
 }
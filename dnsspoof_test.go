@@ -0,0 +1,153 @@
+package dnsspoofer
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/Onyz107/dnsspoofer/internal/inject"
+	"github.com/Onyz107/dnsspoofer/internal/nfqueue"
+	gonfqueue "github.com/florianl/go-nfqueue/v2"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+var (
+	testClient = net.IPv4(10, 0, 0, 2).To4()
+	testServer = net.IPv4(10, 0, 0, 53).To4()
+)
+
+// verdicts records the verdicts issued by handlePacket.
+type verdicts struct {
+	verdict map[uint32]int
+	altered map[uint32]bool
+}
+
+func newVerdicts() *verdicts {
+	return &verdicts{verdict: make(map[uint32]int), altered: make(map[uint32]bool)}
+}
+
+func (v *verdicts) SetVerdict(id uint32, verdict int) error {
+	v.verdict[id] = verdict
+	return nil
+}
+
+func (v *verdicts) SetVerdictWithOption(id uint32, verdict int, options ...gonfqueue.VerdictOption) error {
+	v.verdict[id] = verdict
+	v.altered[id] = len(options) > 0
+	return nil
+}
+
+// stubSender records the injected replies, or fails with err.
+type stubSender struct {
+	sent [][]byte
+	err  error
+}
+
+func (s *stubSender) Send(pkt []byte) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.sent = append(s.sent, pkt)
+	return nil
+}
+
+// packet serializes an IPv4 UDP packet carrying an A query of name with the transaction ID id,
+// or its response from the server.
+func packet(t *testing.T, id uint16, name string, response bool) nfqueue.Packet {
+	t.Helper()
+	msg := &layers.DNS{
+		ID:        id,
+		RD:        true,
+		Questions: []layers.DNSQuestion{{Name: []byte(name), Type: layers.DNSTypeA, Class: layers.DNSClassIN}},
+	}
+	ip := &layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolUDP, SrcIP: testClient, DstIP: testServer}
+	udp := &layers.UDP{SrcPort: 40000, DstPort: 53}
+	if response {
+		msg.QR, msg.RA = true, true
+		msg.Answers = []layers.DNSResourceRecord{{
+			Name: []byte(name), Type: layers.DNSTypeA, Class: layers.DNSClassIN, TTL: 300, IP: net.IPv4(93, 184, 216, 34),
+		}}
+		ip.SrcIP, ip.DstIP = ip.DstIP, ip.SrcIP
+		udp.SrcPort, udp.DstPort = udp.DstPort, udp.SrcPort
+	}
+	udp.SetNetworkLayerForChecksum(ip)
+
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, ip, udp, msg); err != nil {
+		t.Fatal(err)
+	}
+	return nfqueue.Packet{Payload: buf.Bytes(), IPVersion: 4}
+}
+
+// hybridEngine returns a Hybrid SpoofMode engine answering example.com, injecting through injector
+// and recording the types of its events.
+func hybridEngine(t *testing.T, injector *stubSender, events *[]EventType) *Engine {
+	t.Helper()
+	rules, err := NewRuleSet(Rule{Name: "example", Pattern: "example.com", IPs: []net.IP{net.IPv4(10, 6, 6, 6)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := New(&EngineOptions{
+		SpoofMode: Hybrid,
+		Decider:   rules,
+		OnEvent:   func(ev Event) { *events = append(*events, ev.Type) },
+	})
+	e.ctx = context.Background()
+	e.injector = injector
+	return e
+}
+
+func TestHandlePacketHybrid(t *testing.T) {
+	tests := []struct {
+		name       string
+		injectErr  error
+		responseID uint16
+		// wantDrop is whether the genuine response is dropped rather than spoofed
+		wantDrop bool
+	}{
+		{"genuine response dropped", nil, 1, true},
+		{"other transaction spoofed", nil, 2, false},
+		{"response spoofed after a failed injection", errors.New("network unreachable"), 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			injector := &stubSender{err: tt.injectErr}
+			var events []EventType
+			e := hybridEngine(t, injector, &events)
+			v := newVerdicts()
+
+			request := packet(t, 1, "example.com", false)
+			request.PacketID = 1
+			e.handlePacket(v, request)
+			if tt.injectErr == nil {
+				if v.verdict[1] != gonfqueue.NfDrop || len(injector.sent) != 1 {
+					t.Fatalf("request verdict = %d with %d replies injected, want it dropped once answered", v.verdict[1], len(injector.sent))
+				}
+				if dst, err := inject.Destination(injector.sent[0]); err != nil || !dst.Equal(testClient) {
+					t.Errorf("reply sent to %v (%v), want %v", dst, err, testClient)
+				}
+			} else if v.verdict[1] != e.failVerdict() {
+				t.Fatalf("request verdict = %d, want the fail verdict", v.verdict[1])
+			}
+
+			events = nil
+			response := packet(t, tt.responseID, "example.com", true)
+			response.PacketID = 2
+			e.handlePacket(v, response)
+			if tt.wantDrop {
+				if v.verdict[2] != gonfqueue.NfDrop || v.altered[2] {
+					t.Errorf("response verdict = %d altered %v, want dropped", v.verdict[2], v.altered[2])
+				}
+				// Dropped before the Decider, so without a match.
+				if len(events) != 2 || events[1] != EventDrop {
+					t.Errorf("response events = %v, want a query then a drop", events)
+				}
+			} else if v.verdict[2] != gonfqueue.NfAccept || !v.altered[2] {
+				t.Errorf("response verdict = %d altered %v, want accepted with a spoofed payload", v.verdict[2], v.altered[2])
+			}
+		})
+	}
+}
@@ -3,11 +3,26 @@ package dnsspoofer
 import "errors"
 
 var (
-	ErrAddDNSQueue   = errors.New("failed to add DNS NFQueue rules")
-	ErrOpenNFQueue   = errors.New("failed to open NFQueue")
-	ErrGetPacketChan = errors.New("failed to get NFQueue packet channel")
-	ErrParsePacket   = errors.New("failed to parse DNS packet")
-	ErrSpoofPacket   = errors.New("failed to spoof DNS packet")
-	ErrSerializePkt  = errors.New("failed to serialize spoofed DNS packet")
-	ErrSetVerdict    = errors.New("failed to set NFQueue packet verdict")
+	ErrAddDNSQueue     = errors.New("failed to add DNS NFQueue rules")
+	ErrRemoveDNSQueue  = errors.New("failed to remove DNS NFQueue rules")
+	ErrReadCounter     = errors.New("failed to read the kernel counter of the DNS NFQueue rules")
+	ErrInvalidBackend  = errors.New("invalid firewall backend")
+	ErrOpenNFQueue     = errors.New("failed to open NFQueue")
+	ErrGetPacketChan   = errors.New("failed to get NFQueue packet channel")
+	ErrParsePacket     = errors.New("failed to parse DNS packet")
+	ErrSpoofPacket     = errors.New("failed to spoof DNS packet")
+	ErrSerializePkt    = errors.New("failed to serialize spoofed DNS packet")
+	ErrSetVerdict      = errors.New("failed to set NFQueue packet verdict")
+	ErrOpenInjector    = errors.New("failed to open the raw sockets injecting forged replies")
+	ErrInjectPacket    = errors.New("failed to inject spoofed DNS packet")
+	ErrMissingUpstream = errors.New("proxy mode needs an upstream DNS server")
+	ErrListenProxy     = errors.New("failed to start the DNS proxy")
+	ErrNoProxyAddr     = errors.New("no address of the IP mode to redirect DNS requests to")
+	ErrForward         = errors.New("failed to forward DNS request upstream")
+	ErrInvalidAction   = errors.New("invalid decision action")
+
+	ErrMissingRuleName    = errors.New("rule has no name")
+	ErrMissingRulePattern = errors.New("rule has no pattern")
+	ErrMissingRuleIPs     = errors.New("rule has no IPs")
+	ErrInvalidRulePattern = errors.New("invalid rule pattern")
 )
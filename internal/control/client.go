@@ -0,0 +1,136 @@
+package control
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Onyz107/dnsspoofer"
+	"github.com/Onyz107/dnsspoofer/internal/nftables"
+)
+
+// Client talks to the control API of a running instance.
+type Client struct {
+	http    *http.Client
+	baseURL string
+}
+
+// NewClient creates a client for the control API listening on addr, either "unix:/path/to.sock" or "host:port".
+func NewClient(addr string) *Client {
+	if path, ok := strings.CutPrefix(addr, unixPrefix); ok {
+		return &Client{
+			http: &http.Client{
+				Transport: &http.Transport{
+					DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+						var d net.Dialer
+						return d.DialContext(ctx, "unix", path)
+					},
+				},
+			},
+			baseURL: "http://localhost",
+		}
+	}
+	return &Client{http: http.DefaultClient, baseURL: "http://" + addr}
+}
+
+// do sends a request with in as JSON body, if not nil, and decodes the response into out, if not nil.
+func (c *Client) do(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return errors.Join(ErrRequest, err)
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return errors.Join(ErrRequest, err)
+	}
+	if method != http.MethodGet {
+		req.Header.Set("Content-Type", jsonType)
+	}
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return errors.Join(ErrRequest, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		var apiErr apiError
+		if err := json.NewDecoder(res.Body).Decode(&apiErr); err != nil || apiErr.Error == "" {
+			apiErr.Error = res.Status
+		}
+		return fmt.Errorf("%w: %s", ErrResponse, apiErr.Error)
+	}
+
+	if out != nil {
+		if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+			return errors.Join(ErrResponse, err)
+		}
+	}
+	return nil
+}
+
+// Rules lists the active rules.
+func (c *Client) Rules(ctx context.Context) ([]dnsspoofer.Rule, error) {
+	var rules []dnsspoofer.Rule
+	err := c.do(ctx, http.MethodGet, "/v1/rules", nil, &rules)
+	return rules, err
+}
+
+// AddRule adds rule.
+func (c *Client) AddRule(ctx context.Context, rule dnsspoofer.Rule) error {
+	return c.do(ctx, http.MethodPost, "/v1/rules", rule, nil)
+}
+
+// RemoveRule removes every rule named name and returns how many were removed.
+func (c *Client) RemoveRule(ctx context.Context, name string) (int, error) {
+	var removed Removed
+	err := c.do(ctx, http.MethodDelete, "/v1/rules?name="+url.QueryEscape(name), nil, &removed)
+	return removed.Removed, err
+}
+
+// Status returns whether spoofing is paused and the rules are installed.
+func (c *Client) Status(ctx context.Context) (Status, error) {
+	var status Status
+	err := c.do(ctx, http.MethodGet, "/v1/status", nil, &status)
+	return status, err
+}
+
+// Pause pauses spoofing.
+func (c *Client) Pause(ctx context.Context) (Status, error) {
+	var status Status
+	err := c.do(ctx, http.MethodPost, "/v1/pause", nil, &status)
+	return status, err
+}
+
+// Resume resumes spoofing.
+func (c *Client) Resume(ctx context.Context) (Status, error) {
+	var status Status
+	err := c.do(ctx, http.MethodPost, "/v1/resume", nil, &status)
+	return status, err
+}
+
+// Stats returns the engine statistics.
+func (c *Client) Stats(ctx context.Context) (Stats, error) {
+	var stats Stats
+	err := c.do(ctx, http.MethodGet, "/v1/stats", nil, &stats)
+	return stats, err
+}
+
+// NFTables returns the installed dnsspoofer nftables tables.
+func (c *Client) NFTables(ctx context.Context) ([]nftables.TableInfo, error) {
+	var tables []nftables.TableInfo
+	err := c.do(ctx, http.MethodGet, "/v1/nftables", nil, &tables)
+	return tables, err
+}
@@ -0,0 +1,79 @@
+package control
+
+import (
+	"net"
+	"time"
+
+	"github.com/Onyz107/dnsspoofer"
+)
+
+const (
+	// unixPrefix marks a listen address as a Unix socket path.
+	unixPrefix = "unix:"
+	// DefaultAddr is the default address of the control API for the ctl commands.
+	DefaultAddr = unixPrefix + "/run/dnsspoofer.sock"
+	// jsonType is the content type of every request body and response.
+	jsonType = "application/json"
+	// dialTimeout bounds the check for a running instance on an existing socket.
+	dialTimeout = time.Second
+)
+
+// Server serves the control API of an engine.
+type Server struct {
+	engine *dnsspoofer.Engine
+	rules  *dnsspoofer.RuleSet
+}
+
+// socketListener is a unix socket listener removing its socket, which was moved after listening, once closed.
+type socketListener struct {
+	*net.UnixListener
+	path string
+}
+
+// Status is the response of the status, pause and resume endpoints.
+type Status struct {
+	Paused         bool `json:"paused"`
+	RulesInstalled bool `json:"rules_installed"`
+	Rules          int  `json:"rules"`
+}
+
+// Removed is the response of the rule removal endpoint.
+type Removed struct {
+	Removed int `json:"removed"`
+}
+
+// Stats is the JSON form of dnsspoofer.Stats.
+type Stats struct {
+	Packets   uint64 `json:"packets"`
+	Matched   uint64 `json:"matched"`
+	Spoofed   uint64 `json:"spoofed"`
+	Skipped   uint64 `json:"skipped"`
+	Dropped   uint64 `json:"dropped"`
+	Failed    uint64 `json:"failed"`
+	QueueFull uint64 `json:"queue_full"`
+
+	KernelPackets uint64 `json:"kernel_packets"`
+	KernelBytes   uint64 `json:"kernel_bytes"`
+
+	Records     map[string]uint64 `json:"records"`
+	Rules       map[string]uint64 `json:"rules"`
+	Clients     map[string]uint64 `json:"clients"`
+	Errors      map[string]uint64 `json:"errors"`
+	ParseErrors map[string]uint64 `json:"parse_errors"`
+
+	SpoofLatency   Histogram `json:"spoof_latency"`
+	Backlog        int       `json:"backlog"`
+	RulesInstalled bool      `json:"rules_installed"`
+}
+
+// Histogram is the JSON form of dnsspoofer.Histogram, with bucket bounds as duration strings.
+type Histogram struct {
+	Count   uint64            `json:"count"`
+	Sum     time.Duration     `json:"sum_ns"`
+	Buckets map[string]uint64 `json:"buckets"`
+}
+
+// apiError is the body of every error response.
+type apiError struct {
+	Error string `json:"error"`
+}
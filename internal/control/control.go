@@ -0,0 +1,271 @@
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Onyz107/dnsspoofer"
+	"github.com/Onyz107/dnsspoofer/internal/logger"
+	"github.com/Onyz107/dnsspoofer/internal/nftables"
+)
+
+// Listen listens on addr, either "unix:/path/to.sock" or a loopback "host:port".
+func Listen(addr string) (net.Listener, error) {
+	if path, ok := strings.CutPrefix(addr, unixPrefix); ok {
+		return ListenUnix(path)
+	}
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, errors.Join(ErrListen, err)
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return nil, ErrNotLoopback
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, errors.Join(ErrListen, err)
+	}
+	return ln, nil
+}
+
+// ListenUnix listens on the unix socket path, only accessible by its owner.
+//
+// A stale socket left by a dead instance is replaced, one still answered by a running instance is an error.
+func ListenUnix(path string) (net.Listener, error) {
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%w: %s", ErrNotSocket, path)
+		}
+		if conn, err := net.DialTimeout("unix", path, dialTimeout); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("%w: %s", ErrSocketInUse, path)
+		}
+		os.Remove(path)
+	}
+
+	// The socket is made private inside a directory only the owner can enter, then moved into place, so that
+	// nobody can connect to it before the chmod.
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".dnsspoofer-")
+	if err != nil {
+		return nil, errors.Join(ErrListen, err)
+	}
+	defer os.RemoveAll(dir)
+
+	tmp := filepath.Join(dir, filepath.Base(path))
+	ln, err := net.ListenUnix("unix", &net.UnixAddr{Name: tmp, Net: "unix"})
+	if err != nil {
+		return nil, errors.Join(ErrListen, err)
+	}
+	if err := os.Chmod(tmp, 0o600); err != nil {
+		ln.Close()
+		return nil, errors.Join(ErrListen, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		ln.Close()
+		return nil, errors.Join(ErrListen, err)
+	}
+	ln.SetUnlinkOnClose(false)
+	return &socketListener{UnixListener: ln, path: path}, nil
+}
+
+// Close closes the listener and removes its socket.
+func (l *socketListener) Close() error {
+	err := l.UnixListener.Close()
+	os.Remove(l.path)
+	return err
+}
+
+// Serve serves the control API of engine and rules on addr until ctx is done.
+//
+// Returns an error if addr cannot be listened on, serving itself happens in the background.
+func Serve(ctx context.Context, addr string, engine *dnsspoofer.Engine, rules *dnsspoofer.RuleSet) error {
+	log := logger.LoggerFrom(ctx)
+
+	ln, err := Listen(addr)
+	if err != nil {
+		return err
+	}
+
+	s := &Server{engine: engine, rules: rules}
+	srv := &http.Server{Handler: s.Handler()}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+	go func() {
+		log.Info("serving control API", "addr", addr)
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error(ErrListen.Error(), "err", err)
+		}
+	}()
+
+	return nil
+}
+
+// Handler returns the HTTP handler of the control API.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/rules", s.listRules)
+	mux.HandleFunc("POST /v1/rules", s.addRule)
+	mux.HandleFunc("DELETE /v1/rules", s.removeRule)
+	mux.HandleFunc("GET /v1/status", s.status)
+	mux.HandleFunc("POST /v1/pause", s.pause)
+	mux.HandleFunc("POST /v1/resume", s.resume)
+	mux.HandleFunc("GET /v1/stats", s.stats)
+	mux.HandleFunc("GET /v1/nftables", s.nftables)
+	return guard(mux)
+}
+
+// guard rejects requests a browser could be tricked into sending: those naming the API by a hostname other
+// than localhost, as after DNS rebinding, and state changes without a JSON content type, as cross-site forms.
+func guard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		if host != "localhost" && net.ParseIP(strings.Trim(host, "[]")) == nil {
+			writeError(w, http.StatusForbidden, fmt.Errorf("%w: %s", ErrForbiddenHost, r.Host))
+			return
+		}
+
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			if mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mt != jsonType {
+				writeError(w, http.StatusUnsupportedMediaType, ErrContentType)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", jsonType)
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, apiError{Error: err.Error()})
+}
+
+func (s *Server) listRules(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.rules.List())
+}
+
+func (s *Server) addRule(w http.ResponseWriter, r *http.Request) {
+	var rule dnsspoofer.Rule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		writeError(w, http.StatusBadRequest, errors.Join(ErrDecodeBody, err))
+		return
+	}
+	if err := s.rules.Add(rule); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, rule)
+}
+
+// removeRule removes the rules named by the name query parameter, names like "hosts.txt:3" may contain slashes.
+func (s *Server) removeRule(w http.ResponseWriter, r *http.Request) {
+	n := s.rules.Remove(r.URL.Query().Get("name"))
+	if n == 0 {
+		writeError(w, http.StatusNotFound, ErrRuleMissing)
+		return
+	}
+	writeJSON(w, http.StatusOK, Removed{Removed: n})
+}
+
+func (s *Server) currentStatus() Status {
+	return Status{
+		Paused:         s.engine.Paused(),
+		RulesInstalled: s.engine.Stats().RulesInstalled,
+		Rules:          len(s.rules.List()),
+	}
+}
+
+func (s *Server) status(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.currentStatus())
+}
+
+func (s *Server) pause(w http.ResponseWriter, r *http.Request) {
+	s.engine.Pause()
+	writeJSON(w, http.StatusOK, s.currentStatus())
+}
+
+func (s *Server) resume(w http.ResponseWriter, r *http.Request) {
+	s.engine.Resume()
+	writeJSON(w, http.StatusOK, s.currentStatus())
+}
+
+func (s *Server) stats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, NewStats(s.engine.Stats()))
+}
+
+// nftables lists the nftables tables holding dnsspoofer state, it is not implemented for the other backends.
+func (s *Server) nftables(w http.ResponseWriter, r *http.Request) {
+	if backend := s.engine.Backend(); backend != dnsspoofer.NFTables {
+		writeError(w, http.StatusNotImplemented, fmt.Errorf("%w: %s", ErrNotNFTables, backend))
+		return
+	}
+
+	tables, err := nftables.ListTables()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, tables)
+}
+
+// NewStats converts engine statistics into their JSON form.
+func NewStats(st dnsspoofer.Stats) Stats {
+	errorCounts := func(m map[error]uint64) map[string]uint64 {
+		out := make(map[string]uint64, len(m))
+		for err, n := range m {
+			out[err.Error()] = n
+		}
+		return out
+	}
+
+	buckets := make(map[string]uint64, len(st.SpoofLatency.Buckets))
+	for bound, n := range st.SpoofLatency.Buckets {
+		buckets[bound.String()] = n
+	}
+
+	return Stats{
+		Packets:   st.Packets,
+		Matched:   st.Matched,
+		Spoofed:   st.Spoofed,
+		Skipped:   st.Skipped,
+		Dropped:   st.Dropped,
+		Failed:    st.Failed,
+		QueueFull: st.QueueFull,
+
+		KernelPackets: st.KernelPackets,
+		KernelBytes:   st.KernelBytes,
+
+		Records:     st.Records,
+		Rules:       st.Rules,
+		Clients:     st.Clients,
+		Errors:      errorCounts(st.Errors),
+		ParseErrors: errorCounts(st.ParseErrors),
+
+		SpoofLatency: Histogram{
+			Count:   st.SpoofLatency.Count,
+			Sum:     st.SpoofLatency.Sum,
+			Buckets: buckets,
+		},
+		Backlog:        st.Backlog,
+		RulesInstalled: st.RulesInstalled,
+	}
+}
@@ -0,0 +1,151 @@
+package control
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Onyz107/dnsspoofer"
+)
+
+func TestGuard(t *testing.T) {
+	rules, err := dnsspoofer.NewRuleSet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{engine: dnsspoofer.New(&dnsspoofer.EngineOptions{Decider: rules}), rules: rules}
+	h := s.Handler()
+
+	tests := []struct {
+		name        string
+		method      string
+		host        string
+		contentType string
+		want        int
+	}{
+		{"localhost", http.MethodGet, "localhost", "", http.StatusOK},
+		{"loopback with port", http.MethodGet, "127.0.0.1:9154", "", http.StatusOK},
+		{"ipv6 literal", http.MethodGet, "[::1]:9154", "", http.StatusOK},
+		{"rebound hostname", http.MethodGet, "attacker.example:9154", "", http.StatusForbidden},
+		{"post without content type", http.MethodPost, "localhost", "", http.StatusUnsupportedMediaType},
+		{"cross-site form", http.MethodPost, "127.0.0.1:9154", "application/x-www-form-urlencoded", http.StatusUnsupportedMediaType},
+		{"text plain", http.MethodPost, "127.0.0.1:9154", "text/plain", http.StatusUnsupportedMediaType},
+		{"json", http.MethodPost, "127.0.0.1:9154", "application/json", http.StatusOK},
+		{"json with charset", http.MethodPost, "localhost", "application/json; charset=utf-8", http.StatusOK},
+		{"delete without content type", http.MethodDelete, "localhost", "", http.StatusUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := "/v1/status"
+			switch tt.method {
+			case http.MethodPost:
+				path = "/v1/pause"
+			case http.MethodDelete:
+				path = "/v1/rules?name=missing"
+			}
+			req := httptest.NewRequest(tt.method, path, strings.NewReader(""))
+			req.Host = tt.host
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("%s %s with host %q: status = %d, want %d", tt.method, path, tt.host, rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestListenUnix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dnsspoofer.sock")
+
+	ln, err := ListenUnix(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := fi.Mode().Perm(); mode != 0o600 {
+		t.Errorf("socket mode = %o, want 600", mode)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("ListenUnix() left %d entries next to the socket, want only the socket", len(entries)-1)
+	}
+
+	if _, err := ListenUnix(path); !errors.Is(err, ErrSocketInUse) {
+		t.Errorf("ListenUnix() on a running instance error = %v, want %v", err, ErrSocketInUse)
+	}
+
+	// Leave the socket file behind, as a killed instance would.
+	ln.(*socketListener).UnixListener.Close()
+	ln, err = ListenUnix(path)
+	if err != nil {
+		t.Fatalf("ListenUnix() on a stale socket error = %v", err)
+	}
+	ln.Close()
+	if _, err := os.Lstat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("socket left behind by Close(), Lstat() error = %v", err)
+	}
+
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ListenUnix(file); !errors.Is(err, ErrNotSocket) {
+		t.Errorf("ListenUnix() on a regular file error = %v, want %v", err, ErrNotSocket)
+	}
+}
+
+func TestClientSendsGuardedHeaders(t *testing.T) {
+	rules, err := dnsspoofer.NewRuleSet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "dnsspoofer.sock")
+	ln, err := ListenUnix(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{engine: dnsspoofer.New(&dnsspoofer.EngineOptions{Decider: rules}), rules: rules}
+	srv := &http.Server{Handler: s.Handler()}
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	c := NewClient(unixPrefix + path)
+	status, err := c.Pause(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.Paused {
+		t.Error("Pause() did not pause the engine")
+	}
+	if _, err := c.Status(t.Context()); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNFTablesBackend(t *testing.T) {
+	rules, err := dnsspoofer.NewRuleSet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{engine: dnsspoofer.New(&dnsspoofer.EngineOptions{Decider: rules, Backend: dnsspoofer.IPTables}), rules: rules}
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/nftables", nil)
+	req.Host = "localhost"
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotImplemented {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusNotImplemented)
+	}
+	if !strings.Contains(rec.Body.String(), ErrNotNFTables.Error()) {
+		t.Errorf("body = %q, want %q", rec.Body.String(), ErrNotNFTables)
+	}
+}
@@ -0,0 +1,17 @@
+package control
+
+import "errors"
+
+var (
+	ErrNotLoopback   = errors.New("control API must listen on a loopback address or a unix socket")
+	ErrListen        = errors.New("failed to listen for control requests")
+	ErrSocketInUse   = errors.New("socket is in use by a running instance")
+	ErrNotSocket     = errors.New("path exists and is not a socket")
+	ErrForbiddenHost = errors.New("control API is only served to localhost or IP literal hosts")
+	ErrContentType   = errors.New("request content type must be application/json")
+	ErrDecodeBody    = errors.New("failed to decode request body")
+	ErrRuleMissing   = errors.New("no rule with this name")
+	ErrNotNFTables   = errors.New("engine does not install its rules with nftables")
+	ErrRequest       = errors.New("failed to send control request")
+	ErrResponse      = errors.New("control request failed")
+)
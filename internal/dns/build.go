@@ -8,8 +8,10 @@ import (
 
 // spoofAnswers modifies the IP addresses in the provided DNS answer records.
 //
+// Records of a type without a matching IP version are left untouched.
+//
 // Works only on passive mode.
-func spoofAnswers(answers []layers.DNSResourceRecord, ips ...net.IP) []layers.DNSResourceRecord {
+func spoofAnswers(answers []layers.DNSResourceRecord, ttl uint32, ips ...net.IP) []layers.DNSResourceRecord {
 	var ipv4, ipv6 []net.IP
 	for _, ip := range ips {
 		if ip.To4() != nil {
@@ -24,40 +26,46 @@ func spoofAnswers(answers []layers.DNSResourceRecord, ips ...net.IP) []layers.DN
 
 		switch a.Type {
 		case layers.DNSTypeA:
+			if len(ipv4) == 0 {
+				continue
+			}
 			if len(ipv4) <= i {
 				i = len(ipv4) - 1
 			}
 			a.IP = ipv4[i]
-			a.TTL = TTL
+			a.TTL = ttl
 
 		case layers.DNSTypeAAAA:
+			if len(ipv6) == 0 {
+				continue
+			}
 			if len(ipv6) <= i {
 				i = len(ipv6) - 1
 			}
 			a.IP = ipv6[i]
-			a.TTL = TTL
+			a.TTL = ttl
 		}
 	}
 
 	return answers
 }
 
-func newARecord(q layers.DNSQuestion, ip net.IP) layers.DNSResourceRecord {
+func newARecord(q layers.DNSQuestion, ttl uint32, ip net.IP) layers.DNSResourceRecord {
 	return layers.DNSResourceRecord{
 		Name:  q.Name,
 		Type:  layers.DNSTypeA,
 		Class: q.Class,
-		TTL:   TTL,
+		TTL:   ttl,
 		IP:    ip,
 	}
 }
 
-func newAAAARecord(q layers.DNSQuestion, ip net.IP) layers.DNSResourceRecord {
+func newAAAARecord(q layers.DNSQuestion, ttl uint32, ip net.IP) layers.DNSResourceRecord {
 	return layers.DNSResourceRecord{
 		Name:  q.Name,
 		Type:  layers.DNSTypeAAAA,
 		Class: q.Class,
-		TTL:   TTL,
+		TTL:   ttl,
 		IP:    ip,
 	}
 }
@@ -65,7 +73,7 @@ func newAAAARecord(q layers.DNSQuestion, ip net.IP) layers.DNSResourceRecord {
 // answerDNSQuestions creates DNS answer records based on the provided questions and IPs.
 //
 // Works only on aggressive mode.
-func answerDNSQuestions(questions []layers.DNSQuestion, ips ...net.IP) []layers.DNSResourceRecord {
+func answerDNSQuestions(questions []layers.DNSQuestion, ttl uint32, ips ...net.IP) []layers.DNSResourceRecord {
 	var answers []layers.DNSResourceRecord
 	var ipv4, ipv6 []net.IP
 	for _, ip := range ips {
@@ -80,12 +88,12 @@ func answerDNSQuestions(questions []layers.DNSQuestion, ips ...net.IP) []layers.
 		switch q.Type {
 		case layers.DNSTypeA:
 			for _, ip := range ipv4 {
-				answers = append(answers, newARecord(q, ip))
+				answers = append(answers, newARecord(q, ttl, ip))
 			}
 
 		case layers.DNSTypeAAAA:
 			for _, ip := range ipv6 {
-				answers = append(answers, newAAAARecord(q, ip))
+				answers = append(answers, newAAAARecord(q, ttl, ip))
 			}
 		}
 
@@ -94,28 +102,44 @@ func answerDNSQuestions(questions []layers.DNSQuestion, ips ...net.IP) []layers.
 	return answers
 }
 
-func buildDNSResponse(dnsLayer *layers.DNS, ips ...net.IP) (*layers.DNS, error) {
+// newResponse creates an authoritative response to dnsLayer with the provided response code and answers.
+func newResponse(dnsLayer *layers.DNS, rcode layers.DNSResponseCode, answers []layers.DNSResourceRecord) *layers.DNS {
+	return &layers.DNS{
+		ID:           dnsLayer.ID,
+		QR:           true,
+		OpCode:       dnsLayer.OpCode,
+		AA:           true,
+		RD:           dnsLayer.RD,
+		RA:           true,
+		TC:           dnsLayer.TC,
+		Z:            dnsLayer.Z,
+		ResponseCode: rcode,
+
+		QDCount: uint16(len(dnsLayer.Questions)),
+		ANCount: uint16(len(answers)),
+
+		Questions: dnsLayer.Questions,
+		Answers:   answers,
+	}
+}
+
+// buildDNSResponse answers dnsLayer with ips, or with an empty rcode response if rcode is not NOERROR.
+//
+// A ttl of 0 is replaced with TTL.
+func buildDNSResponse(dnsLayer *layers.DNS, rcode layers.DNSResponseCode, ttl uint32, ips ...net.IP) (*layers.DNS, error) {
+	if ttl == 0 {
+		ttl = TTL
+	}
+
+	if rcode != layers.DNSResponseCodeNoErr {
+		return newResponse(dnsLayer, rcode, nil), nil
+	}
+
 	if len(dnsLayer.Answers) > 0 {
-		dnsLayer.Answers = spoofAnswers(dnsLayer.Answers, ips...)
+		dnsLayer.Answers = spoofAnswers(dnsLayer.Answers, ttl, ips...)
 		return dnsLayer, nil
 	} else {
-		answers := answerDNSQuestions(dnsLayer.Questions, ips...)
-		return &layers.DNS{
-			ID:           dnsLayer.ID,
-			QR:           true,
-			OpCode:       dnsLayer.OpCode,
-			AA:           true,
-			RD:           dnsLayer.RD,
-			RA:           true,
-			TC:           dnsLayer.TC,
-			Z:            dnsLayer.Z,
-			ResponseCode: layers.DNSResponseCodeNoErr,
-
-			QDCount: uint16(len(dnsLayer.Questions)),
-			ANCount: uint16(len(answers)),
-
-			Questions: dnsLayer.Questions,
-			Answers:   answers,
-		}, nil
+		answers := answerDNSQuestions(dnsLayer.Questions, ttl, ips...)
+		return newResponse(dnsLayer, layers.DNSResponseCodeNoErr, answers), nil
 	}
 }
@@ -17,3 +17,57 @@ type ParsedPacket struct {
 	IPVersion uint32
 	IsRequest bool
 }
+
+// Record is a decoded DNS resource record.
+type Record struct {
+	// Name is the owner name of the record
+	Name string `json:"name"`
+	// Type is the record type (A, AAAA, CNAME, ...)
+	Type string `json:"type"`
+	// TTL is the time to live in seconds
+	TTL uint32 `json:"ttl"`
+	// Data is the record data: the address of A/AAAA records, the target name of CNAME/NS/PTR records,
+	// or the gopacket text representation otherwise
+	Data string `json:"data"`
+}
+
+// Transaction identifies a DNS exchange, a request and its response share it.
+type Transaction struct {
+	// Client is the address of the DNS client
+	Client string
+	// Port is the UDP port of the DNS client
+	Port uint16
+	// ID is the DNS transaction ID
+	ID uint16
+}
+
+const (
+	// headerLen is the length of the DNS message header.
+	headerLen = 12
+	// minUDPSize is the largest UDP response every client accepts (RFC 1035).
+	minUDPSize = 512
+	// maxPointers bounds the compression pointers followed while reading a name, breaking loops.
+	maxPointers = 64
+	// flagTC is the truncation bit of the third header byte.
+	flagTC = 0x02
+	// flagAD is the authenticated data bit of the fourth header byte.
+	flagAD = 0x20
+)
+
+const (
+	sectionAnswer = iota
+	sectionAuthority
+	sectionAdditional
+)
+
+// dnssecTypes are the record types StripDNSSEC removes: RRSIG, NSEC and NSEC3.
+var dnssecTypes = []layers.DNSType{46, 47, 50}
+
+// wireRecord locates a resource record in a DNS message.
+type wireRecord struct {
+	section int
+	start   int
+	nameEnd int
+	end     int
+	typ     layers.DNSType
+}
@@ -14,3 +14,21 @@ var (
 	ErrInvalidDNSResponse = errors.New("invalid DNS response")
 	ErrSerializeLayers    = errors.New("failed to serialize layers")
 )
+
+// parseErrors are the errors ParsePacket can fail with.
+var parseErrors = []error{
+	ErrInvalidIPVersion,
+	ErrInvalidIPLayer,
+	ErrInvalidUDPLayer,
+	ErrInvalidDNSLayer,
+}
+
+// ParseErrorKind returns the sentinel error behind an error returned by ParsePacket, or err itself if there is none.
+func ParseErrorKind(err error) error {
+	for _, kind := range parseErrors {
+		if errors.Is(err, kind) {
+			return kind
+		}
+	}
+	return err
+}
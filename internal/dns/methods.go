@@ -2,9 +2,87 @@ package dns
 
 import (
 	"fmt"
+	"net"
 	"strings"
+
+	"github.com/google/gopacket/layers"
 )
 
+// Answers returns the answer records of the packet.
+func (pp *ParsedPacket) Answers() []Record {
+	if pp.DNS == nil {
+		return nil
+	}
+	return NewRecords(pp.DNS.Answers)
+}
+
+// NewRecords decodes resource records into Records.
+func NewRecords(rrs []layers.DNSResourceRecord) []Record {
+	if len(rrs) == 0 {
+		return nil
+	}
+
+	records := make([]Record, 0, len(rrs))
+	for _, rr := range rrs {
+		var data string
+		switch rr.Type {
+		case layers.DNSTypeA, layers.DNSTypeAAAA:
+			data = rr.IP.String()
+		case layers.DNSTypeCNAME:
+			data = string(rr.CNAME)
+		case layers.DNSTypeNS:
+			data = string(rr.NS)
+		case layers.DNSTypePTR:
+			data = string(rr.PTR)
+		default:
+			data = rr.String()
+		}
+		records = append(records, Record{
+			Name: string(rr.Name),
+			Type: rr.Type.String(),
+			TTL:  rr.TTL,
+			Data: data,
+		})
+	}
+	return records
+}
+
+// Client returns the address of the DNS client: the source of a request or the destination of a response.
+func (pp *ParsedPacket) Client() net.IP {
+	src, dst := pp.addrs()
+	if pp.IsRequest {
+		return src
+	}
+	return dst
+}
+
+// Server returns the address of the DNS server: the destination of a request or the source of a response.
+func (pp *ParsedPacket) Server() net.IP {
+	src, dst := pp.addrs()
+	if pp.IsRequest {
+		return dst
+	}
+	return src
+}
+
+// Transaction returns the DNS exchange pp belongs to.
+func (pp *ParsedPacket) Transaction() Transaction {
+	port := pp.UDP.SrcPort
+	if !pp.IsRequest {
+		port = pp.UDP.DstPort
+	}
+	return Transaction{Client: pp.Client().String(), Port: uint16(port), ID: pp.DNS.ID}
+}
+
+func (pp *ParsedPacket) addrs() (src, dst net.IP) {
+	if pp.IPv4 != nil {
+		return pp.IPv4.SrcIP, pp.IPv4.DstIP
+	} else if pp.IPv6 != nil {
+		return pp.IPv6.SrcIP, pp.IPv6.DstIP
+	}
+	return nil, nil
+}
+
 func (pp *ParsedPacket) String() string {
 	var b strings.Builder
 
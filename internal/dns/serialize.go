@@ -4,6 +4,7 @@ import (
 	"errors"
 
 	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
 )
 
 func (pp *ParsedPacket) Serialize() ([]byte, error) {
@@ -33,3 +34,13 @@ func (pp *ParsedPacket) Serialize() ([]byte, error) {
 
 	return buffer.Bytes(), nil
 }
+
+// SerializeMessage serializes d without IP and UDP headers, as written by a DNS server.
+func SerializeMessage(d *layers.DNS) ([]byte, error) {
+	buffer := gopacket.NewSerializeBuffer()
+	options := gopacket.SerializeOptions{FixLengths: true}
+	if err := d.SerializeTo(buffer, options); err != nil {
+		return nil, errors.Join(ErrSerializeLayers, err)
+	}
+	return buffer.Bytes(), nil
+}
@@ -3,9 +3,14 @@ package dns
 import (
 	"errors"
 	"net"
+
+	"github.com/google/gopacket/layers"
 )
 
-func SpoofRequest(pp *ParsedPacket, ips ...net.IP) (*ParsedPacket, error) {
+// SpoofRequest turns the request pp into a response answering it with ips, or with rcode if it is not NOERROR.
+//
+// Answers get ttl, or TTL if ttl is 0.
+func SpoofRequest(pp *ParsedPacket, rcode layers.DNSResponseCode, ttl uint32, ips ...net.IP) (*ParsedPacket, error) {
 	if pp.DNS == nil || !pp.IsRequest {
 		return nil, ErrInvalidDNSRequest
 	}
@@ -14,7 +19,7 @@ func SpoofRequest(pp *ParsedPacket, ips ...net.IP) (*ParsedPacket, error) {
 		return nil, ErrNoQuestions
 	}
 
-	dnsRes, err := buildDNSResponse(pp.DNS, ips...)
+	dnsRes, err := buildDNSResponse(pp.DNS, rcode, ttl, ips...)
 	if err != nil {
 		return nil, errors.Join(ErrBuildDNSResponse, err)
 	}
@@ -43,12 +48,15 @@ func SpoofRequest(pp *ParsedPacket, ips ...net.IP) (*ParsedPacket, error) {
 	}
 }
 
-func SpoofResponse(pp *ParsedPacket, ips ...net.IP) (*ParsedPacket, error) {
+// SpoofResponse rewrites the answers of the response pp with ips, or replaces it with an rcode response if rcode is not NOERROR.
+//
+// Answers get ttl, or TTL if ttl is 0.
+func SpoofResponse(pp *ParsedPacket, rcode layers.DNSResponseCode, ttl uint32, ips ...net.IP) (*ParsedPacket, error) {
 	if pp.DNS == nil || pp.IsRequest {
 		return nil, ErrInvalidDNSResponse
 	}
 
-	dnsRes, err := buildDNSResponse(pp.DNS, ips...)
+	dnsRes, err := buildDNSResponse(pp.DNS, rcode, ttl, ips...)
 	if err != nil {
 		return nil, errors.Join(ErrBuildDNSResponse, err)
 	}
@@ -57,3 +65,21 @@ func SpoofResponse(pp *ParsedPacket, ips ...net.IP) (*ParsedPacket, error) {
 
 	return pp, nil
 }
+
+// Answer returns the response to the request req answering it with ips, or with rcode if it is not NOERROR.
+//
+// Answers get ttl, or TTL if ttl is 0.
+func Answer(req *layers.DNS, rcode layers.DNSResponseCode, ttl uint32, ips ...net.IP) (*layers.DNS, error) {
+	if req.QR {
+		return nil, ErrInvalidDNSRequest
+	}
+	if len(req.Questions) == 0 {
+		return nil, ErrNoQuestions
+	}
+
+	res, err := buildDNSResponse(req, rcode, ttl, ips...)
+	if err != nil {
+		return nil, errors.Join(ErrBuildDNSResponse, err)
+	}
+	return res, nil
+}
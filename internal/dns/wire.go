@@ -0,0 +1,263 @@
+package dns
+
+import (
+	"encoding/binary"
+	"slices"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// ParseMessage decodes a DNS message without IP and UDP headers, as read by a DNS server.
+func ParseMessage(msg []byte) (*layers.DNS, error) {
+	d := new(layers.DNS)
+	if err := d.DecodeFromBytes(msg, gopacket.NilDecodeFeedback); err != nil {
+		return nil, ErrInvalidDNSLayer
+	}
+	if len(d.Questions) == 0 {
+		return nil, ErrInvalidDNSLayer
+	}
+	return d, nil
+}
+
+// Truncated reports whether the TC bit of the DNS message msg is set.
+func Truncated(msg []byte) bool {
+	return len(msg) >= headerLen && msg[2]&flagTC != 0
+}
+
+// UDPSize returns the largest UDP response the sender of the request msg accepts, from its EDNS record.
+func UDPSize(msg []byte) int {
+	_, rrs, err := walk(msg)
+	if err != nil {
+		return minUDPSize
+	}
+	for _, rr := range rrs {
+		if rr.section == sectionAdditional && rr.typ == layers.DNSTypeOPT {
+			return max(minUDPSize, int(binary.BigEndian.Uint16(msg[rr.nameEnd+2:])))
+		}
+	}
+	return minUDPSize
+}
+
+// Truncate returns the header and questions of the DNS message msg with the TC bit set,
+// telling the client to retry over TCP.
+func Truncate(msg []byte) ([]byte, error) {
+	questionsEnd, _, err := walk(msg)
+	if err != nil {
+		return nil, err
+	}
+	out := slices.Clone(msg[:questionsEnd])
+	out[2] |= flagTC
+	clear(out[6:headerLen])
+	return out, nil
+}
+
+// MinTTL returns the lowest TTL of the records of the DNS message msg, false if it has none.
+func MinTTL(msg []byte) (uint32, bool) {
+	_, rrs, err := walk(msg)
+	if err != nil {
+		return 0, false
+	}
+	var ttl uint32
+	found := false
+	for _, rr := range rrs {
+		if rr.typ == layers.DNSTypeOPT {
+			continue
+		}
+		if t := binary.BigEndian.Uint32(msg[rr.nameEnd+4:]); !found || t < ttl {
+			ttl, found = t, true
+		}
+	}
+	return ttl, found
+}
+
+// AgeTTLs lowers in place the TTL of every record of the DNS message msg by elapsed seconds, down to 0.
+func AgeTTLs(msg []byte, elapsed uint32) error {
+	_, rrs, err := walk(msg)
+	if err != nil {
+		return err
+	}
+	for _, rr := range rrs {
+		if rr.typ == layers.DNSTypeOPT {
+			continue
+		}
+		ttl := binary.BigEndian.Uint32(msg[rr.nameEnd+4:])
+		binary.BigEndian.PutUint32(msg[rr.nameEnd+4:], ttl-min(ttl, elapsed))
+	}
+	return nil
+}
+
+// StripDNSSEC returns the DNS message msg without its RRSIG, NSEC and NSEC3 records and with the AD bit cleared.
+//
+// The names of the kept records are written uncompressed, since the compression pointers of msg
+// may point into the removed records.
+func StripDNSSEC(msg []byte) ([]byte, error) {
+	questionsEnd, rrs, err := walk(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	out := slices.Clone(msg[:questionsEnd])
+	out[3] &^= flagAD
+	var counts [3]uint16
+	for _, rr := range rrs {
+		if slices.Contains(dnssecTypes, rr.typ) {
+			continue
+		}
+
+		name, _, err := readName(msg, rr.start)
+		if err != nil {
+			return nil, err
+		}
+		rdata, err := expandRData(msg, rr)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, name...)
+		out = append(out, msg[rr.nameEnd:rr.nameEnd+8]...) // type, class, TTL
+		out = binary.BigEndian.AppendUint16(out, uint16(len(rdata)))
+		out = append(out, rdata...)
+		counts[rr.section]++
+	}
+	for i, c := range counts {
+		binary.BigEndian.PutUint16(out[6+2*i:], c)
+	}
+	return out, nil
+}
+
+// walk returns the end of the question section of the DNS message msg and its records.
+func walk(msg []byte) (int, []wireRecord, error) {
+	if len(msg) < headerLen {
+		return 0, nil, ErrInvalidDNSLayer
+	}
+
+	off := headerLen
+	for range binary.BigEndian.Uint16(msg[4:]) {
+		end, err := skipName(msg, off)
+		if err != nil {
+			return 0, nil, err
+		}
+		off = end + 4 // type, class
+	}
+	if off > len(msg) {
+		return 0, nil, ErrInvalidDNSLayer
+	}
+	questionsEnd := off
+
+	var rrs []wireRecord
+	for section := range 3 {
+		for range binary.BigEndian.Uint16(msg[6+2*section:]) {
+			nameEnd, err := skipName(msg, off)
+			if err != nil {
+				return 0, nil, err
+			}
+			if nameEnd+10 > len(msg) {
+				return 0, nil, ErrInvalidDNSLayer
+			}
+			end := nameEnd + 10 + int(binary.BigEndian.Uint16(msg[nameEnd+8:]))
+			if end > len(msg) {
+				return 0, nil, ErrInvalidDNSLayer
+			}
+			rrs = append(rrs, wireRecord{
+				section: section,
+				start:   off,
+				nameEnd: nameEnd,
+				end:     end,
+				typ:     layers.DNSType(binary.BigEndian.Uint16(msg[nameEnd:])),
+			})
+			off = end
+		}
+	}
+	return questionsEnd, rrs, nil
+}
+
+// skipName returns the offset following the possibly compressed name at off.
+func skipName(msg []byte, off int) (int, error) {
+	for off < len(msg) {
+		l := int(msg[off])
+		switch {
+		case l == 0:
+			return off + 1, nil
+		case l&0xc0 == 0xc0:
+			if off+2 > len(msg) {
+				return 0, ErrInvalidDNSLayer
+			}
+			return off + 2, nil
+		case l&0xc0 != 0:
+			return 0, ErrInvalidDNSLayer
+		}
+		off += 1 + l
+	}
+	return 0, ErrInvalidDNSLayer
+}
+
+// readName returns the name at off in uncompressed wire format and the offset following it in msg.
+func readName(msg []byte, off int) ([]byte, int, error) {
+	var name []byte
+	next := -1
+	for jumps := 0; ; {
+		if off >= len(msg) {
+			return nil, 0, ErrInvalidDNSLayer
+		}
+		l := int(msg[off])
+		switch {
+		case l == 0:
+			if next < 0 {
+				next = off + 1
+			}
+			return append(name, 0), next, nil
+		case l&0xc0 == 0xc0:
+			if off+2 > len(msg) || jumps >= maxPointers {
+				return nil, 0, ErrInvalidDNSLayer
+			}
+			if next < 0 {
+				next = off + 2
+			}
+			off = int(binary.BigEndian.Uint16(msg[off:]) & 0x3fff)
+			jumps++
+		case l&0xc0 != 0 || off+1+l > len(msg):
+			return nil, 0, ErrInvalidDNSLayer
+		default:
+			name = append(name, msg[off:off+1+l]...)
+			off += 1 + l
+		}
+	}
+}
+
+// expandRData returns the data of rr with the names that may be compressed (RFC 3597 section 4) written uncompressed.
+func expandRData(msg []byte, rr wireRecord) ([]byte, error) {
+	start := rr.nameEnd + 10
+	var names int
+	var prefix, suffix int
+	switch rr.typ {
+	case layers.DNSTypeNS, layers.DNSTypeMD, layers.DNSTypeMF, layers.DNSTypeCNAME,
+		layers.DNSTypeMB, layers.DNSTypeMG, layers.DNSTypeMR, layers.DNSTypePTR:
+		names = 1
+	case layers.DNSTypeMX:
+		prefix, names = 2, 1
+	case layers.DNSTypeMINFO:
+		names = 2
+	case layers.DNSTypeSOA:
+		names, suffix = 2, 20
+	default:
+		return msg[start:rr.end], nil
+	}
+
+	if start+prefix > rr.end {
+		return nil, ErrInvalidDNSLayer
+	}
+	out := slices.Clone(msg[start : start+prefix])
+	off := start + prefix
+	for range names {
+		name, next, err := readName(msg, off)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, name...)
+		off = next
+	}
+	if off+suffix != rr.end {
+		return nil, ErrInvalidDNSLayer
+	}
+	return append(out, msg[off:rr.end]...), nil
+}
@@ -0,0 +1,237 @@
+package dns
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/google/gopacket/layers"
+)
+
+// wireName encodes labels as an uncompressed name.
+func wireName(labels ...string) []byte {
+	var b []byte
+	for _, l := range labels {
+		b = append(b, byte(len(l)))
+		b = append(b, l...)
+	}
+	return append(b, 0)
+}
+
+// pointer encodes a compression pointer to off.
+func pointer(off int) []byte {
+	return []byte{0xc0 | byte(off>>8), byte(off)}
+}
+
+// wireRR encodes a record with class IN, the OPT record putting the UDP size there instead.
+func wireRR(name []byte, typ layers.DNSType, class uint16, ttl uint32, rdata []byte) []byte {
+	b := append([]byte(nil), name...)
+	b = binary.BigEndian.AppendUint16(b, uint16(typ))
+	b = binary.BigEndian.AppendUint16(b, class)
+	b = binary.BigEndian.AppendUint32(b, ttl)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(rdata)))
+	return append(b, rdata...)
+}
+
+// testResponse returns an authenticated response for example.com with compressed names: a CNAME, its RRSIG
+// and an A record, followed by an OPT record.
+//
+// The answers have TTLs 300, 300 and 60, the question name is at offset 12.
+func testResponse() []byte {
+	msg := []byte{0, 1, 0x81, 0x80 | flagAD, 0, 1, 0, 3, 0, 0, 0, 1}
+	msg = append(msg, wireName("example", "com")...)
+	msg = binary.BigEndian.AppendUint16(msg, uint16(layers.DNSTypeA))
+	msg = binary.BigEndian.AppendUint16(msg, uint16(layers.DNSClassIN))
+
+	msg = append(msg, wireRR(pointer(12), layers.DNSTypeCNAME, 1, 300, append([]byte{3, 'w', 'w', 'w'}, pointer(12)...))...)
+	msg = append(msg, wireRR(pointer(12), 46, 1, 300, []byte{1, 2, 3, 4})...)
+	msg = append(msg, wireRR(pointer(12), layers.DNSTypeA, 1, 60, net.IPv4(10, 0, 0, 1).To4())...)
+	// The TTL field of OPT holds the extended flags, 0 here so that treating it as a TTL shows.
+	return append(msg, wireRR([]byte{0}, layers.DNSTypeOPT, 1232, 0, nil)...)
+}
+
+func TestStripDNSSEC(t *testing.T) {
+	out, err := StripDNSSEC(testResponse())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out[3]&flagAD != 0 {
+		t.Error("AD bit still set")
+	}
+
+	d, err := ParseMessage(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.ANCount != 2 || d.NSCount != 0 || d.ARCount != 1 {
+		t.Errorf("counts = %d/%d/%d, want 2/0/1", d.ANCount, d.NSCount, d.ARCount)
+	}
+	if len(d.Answers) != 2 || d.Answers[0].Type != layers.DNSTypeCNAME || d.Answers[1].Type != layers.DNSTypeA {
+		t.Fatalf("answers = %v, want the CNAME and A records", d.Answers)
+	}
+	if name := string(d.Answers[0].CNAME); name != "www.example.com" {
+		t.Errorf("CNAME = %q, want www.example.com", name)
+	}
+	if len(d.Additionals) != 1 || d.Additionals[0].Type != layers.DNSTypeOPT {
+		t.Errorf("additionals = %v, want the OPT record", d.Additionals)
+	}
+	// Names were expanded, nothing may point into the removed RRSIG.
+	if bytes.Contains(out[headerLen:], pointer(12)) {
+		t.Error("compression pointer left in the stripped message")
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	msg := testResponse()
+	out, err := Truncate(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !Truncated(out) {
+		t.Error("TC bit not set")
+	}
+	if Truncated(msg) {
+		t.Error("Truncate() modified its input")
+	}
+	questionsEnd := headerLen + len(wireName("example", "com")) + 4
+	if len(out) != questionsEnd {
+		t.Errorf("length = %d, want %d, the header and question only", len(out), questionsEnd)
+	}
+
+	d, err := ParseMessage(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.QDCount != 1 || d.ANCount != 0 || d.NSCount != 0 || d.ARCount != 0 {
+		t.Errorf("counts = %d/%d/%d/%d, want 1/0/0/0, without the records nor OPT", d.QDCount, d.ANCount, d.NSCount, d.ARCount)
+	}
+}
+
+func TestAgeTTLs(t *testing.T) {
+	msg := testResponse()
+	if size := UDPSize(msg); size != 1232 {
+		t.Errorf("UDPSize() = %d, want 1232", size)
+	}
+	if ttl, ok := MinTTL(msg); !ok || ttl != 60 {
+		t.Errorf("MinTTL() = %d, %t, want 60 skipping OPT", ttl, ok)
+	}
+
+	if err := AgeTTLs(msg, 100); err != nil {
+		t.Fatal(err)
+	}
+	d, err := ParseMessage(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []uint32{200, 200, 0} {
+		if got := d.Answers[i].TTL; got != want {
+			t.Errorf("answer %d TTL = %d, want %d", i, got, want)
+		}
+	}
+	if ttl, ok := MinTTL(msg); !ok || ttl != 0 {
+		t.Errorf("MinTTL() once aged = %d, %t, want 0", ttl, ok)
+	}
+	if d.Additionals[0].TTL != 0 {
+		t.Errorf("OPT flags = %#x, want them untouched", d.Additionals[0].TTL)
+	}
+
+	if _, ok := MinTTL(msg[:headerLen]); ok {
+		t.Error("MinTTL() of a message without records reported one")
+	}
+}
+
+func TestWireInvalid(t *testing.T) {
+	msg := testResponse()
+	rrStart := headerLen + len(wireName("example", "com")) + 4
+
+	// loop is the response with the CNAME target pointing at itself.
+	loop := testResponse()
+	cnameTarget := rrStart + 2 + 10 + 4
+	copy(loop[cnameTarget:], pointer(cnameTarget))
+
+	// outside is the response with the CNAME target pointing past its end.
+	outside := testResponse()
+	copy(outside[cnameTarget:], pointer(len(outside)+10))
+
+	// label is the response with a reserved label type in the question.
+	label := testResponse()
+	label[headerLen] = 0x80
+
+	tests := []struct {
+		name string
+		msg  []byte
+		// stripOnly is set for messages walk accepts, their names are only read by StripDNSSEC
+		stripOnly bool
+	}{
+		{"short header", msg[:headerLen-1], false},
+		{"truncated question", msg[:headerLen+3], false},
+		{"truncated record header", msg[:rrStart+2+5], false},
+		{"truncated rdata", msg[:rrStart+2+10+3], false},
+		{"missing record", msg[:len(msg)-11], false},
+		{"reserved label type", label, false},
+		{"pointer loop", loop, true},
+		{"pointer out of bounds", outside, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := StripDNSSEC(tt.msg); !errors.Is(err, ErrInvalidDNSLayer) {
+				t.Errorf("StripDNSSEC() error = %v, want %v", err, ErrInvalidDNSLayer)
+			}
+			if tt.stripOnly {
+				return
+			}
+			if _, err := Truncate(tt.msg); !errors.Is(err, ErrInvalidDNSLayer) {
+				t.Errorf("Truncate() error = %v, want %v", err, ErrInvalidDNSLayer)
+			}
+			if err := AgeTTLs(tt.msg, 1); !errors.Is(err, ErrInvalidDNSLayer) {
+				t.Errorf("AgeTTLs() error = %v, want %v", err, ErrInvalidDNSLayer)
+			}
+			if _, ok := MinTTL(tt.msg); ok {
+				t.Error("MinTTL() of an invalid message reported a TTL")
+			}
+			if size := UDPSize(tt.msg); size != minUDPSize {
+				t.Errorf("UDPSize() = %d, want %d", size, minUDPSize)
+			}
+		})
+	}
+}
+
+func TestReadName(t *testing.T) {
+	msg := append(make([]byte, headerLen), wireName("example", "com")...)
+	www := len(msg)
+	msg = append(msg, 3, 'w', 'w', 'w')
+	msg = append(msg, pointer(headerLen)...)
+	loop := len(msg)
+	msg = append(msg, pointer(loop+2)...)
+	msg = append(msg, pointer(loop)...)
+
+	name, next, err := readName(msg, www)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := wireName("www", "example", "com"); !bytes.Equal(name, want) {
+		t.Errorf("name = %q, want %q", name, want)
+	}
+	if next != loop {
+		t.Errorf("next = %d, want %d, right after the pointer", next, loop)
+	}
+
+	for _, tt := range []struct {
+		name string
+		off  int
+		msg  []byte
+	}{
+		{"pointer loop", loop, msg},
+		{"pointer out of bounds", 0, pointer(len(msg))},
+		{"truncated pointer", 0, []byte{0xc0}},
+		{"truncated label", 0, []byte{7, 'e', 'x'}},
+		{"missing terminator", 0, []byte{2, 'e', 'x'}},
+	} {
+		if _, _, err := readName(tt.msg, tt.off); !errors.Is(err, ErrInvalidDNSLayer) {
+			t.Errorf("%s: readName() error = %v, want %v", tt.name, err, ErrInvalidDNSLayer)
+		}
+	}
+}
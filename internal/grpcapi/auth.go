@@ -0,0 +1,101 @@
+package grpcapi
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"os"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// secured reports whether opts encrypt the connections, without which a bearer token would travel in the clear.
+func (opts Options) secured() bool {
+	return opts.CertFile != ""
+}
+
+// serverOptions returns the TLS credentials and token interceptors of opts.
+func serverOptions(opts Options) ([]grpc.ServerOption, error) {
+	var out []grpc.ServerOption
+
+	if opts.CertFile != "" || opts.KeyFile != "" {
+		creds, err := loadTLS(opts)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, grpc.Creds(creds))
+	} else if opts.ClientCAFile != "" {
+		return nil, ErrClientCA
+	}
+
+	if opts.Token != "" {
+		out = append(out,
+			grpc.ChainUnaryInterceptor(func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+				if err := checkToken(ctx, opts.Token); err != nil {
+					return nil, err
+				}
+				return handler(ctx, req)
+			}),
+			grpc.ChainStreamInterceptor(func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+				if err := checkToken(ss.Context(), opts.Token); err != nil {
+					return err
+				}
+				return handler(srv, ss)
+			}),
+		)
+	}
+	return out, nil
+}
+
+// loadTLS returns the TLS credentials of opts, requiring client certificates if opts.ClientCAFile is set.
+func loadTLS(opts Options) (credentials.TransportCredentials, error) {
+	cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+	if err != nil {
+		return nil, errors.Join(ErrLoadTLS, err)
+	}
+	cfg := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+
+	if opts.ClientCAFile != "" {
+		pem, err := os.ReadFile(opts.ClientCAFile)
+		if err != nil {
+			return nil, errors.Join(ErrLoadTLS, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.Join(ErrLoadTLS, errors.New("no certificate in "+opts.ClientCAFile))
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return credentials.NewTLS(cfg), nil
+}
+
+// checkToken returns an Unauthenticated error unless ctx carries the bearer token.
+func checkToken(ctx context.Context, token string) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, v := range md.Get(authorization) {
+		got, ok := strings.CutPrefix(v, bearerPrefix)
+		if ok && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1 {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, ErrBadToken.Error())
+}
+
+// isLoopback reports whether the TCP address addr only accepts local connections.
+func isLoopback(addr string) (bool, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false, err
+	}
+	ip := net.ParseIP(host)
+	return host == "localhost" || (ip != nil && ip.IsLoopback()), nil
+}
@@ -0,0 +1,41 @@
+package grpcapi
+
+import (
+	"sync"
+
+	"github.com/Onyz107/dnsspoofer"
+	"github.com/Onyz107/dnsspoofer/internal/grpcapi/pb"
+)
+
+const (
+	// unixPrefix marks a listen address as a Unix socket path.
+	unixPrefix = "unix:"
+	// watchBuffer is the number of events buffered per WatchEvents stream before events are dropped.
+	watchBuffer = 256
+	// authorization is the metadata key holding the bearer token.
+	authorization = "authorization"
+	// bearerPrefix precedes the token in the authorization metadata.
+	bearerPrefix = "Bearer "
+)
+
+// Options secures the gRPC API, Serve refuses non-loopback TCP addresses unless TLS or Token is set.
+type Options struct {
+	// CertFile and KeyFile are the PEM certificate and key serving TLS
+	CertFile string
+	KeyFile  string
+	// ClientCAFile is a PEM bundle of the CAs client certificates must be signed by (mTLS), needs TLS
+	ClientCAFile string
+	// Token is the bearer token clients must send in the authorization metadata
+	Token string
+}
+
+// Server implements the DNSSpoofer gRPC service on top of an engine and its rule set.
+type Server struct {
+	pb.UnimplementedDNSSpooferServer
+
+	engine *dnsspoofer.Engine
+	rules  *dnsspoofer.RuleSet
+
+	mu       sync.RWMutex
+	watchers map[chan dnsspoofer.Event]struct{}
+}
@@ -0,0 +1,13 @@
+package grpcapi
+
+import "errors"
+
+var (
+	ErrListen      = errors.New("failed to listen for gRPC requests")
+	ErrInvalidIP   = errors.New("invalid rule IP")
+	ErrInvalidType = errors.New("invalid event type")
+	ErrInsecure    = errors.New("gRPC API must listen on a loopback address or a unix socket unless TLS is set")
+	ErrClientCA    = errors.New("client CA requires a TLS certificate and key")
+	ErrLoadTLS     = errors.New("failed to load TLS credentials")
+	ErrBadToken    = errors.New("missing or invalid bearer token")
+)
@@ -0,0 +1,228 @@
+// Package grpcapi serves the gRPC API of an engine: event streaming, rule updates and statistics.
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/Onyz107/dnsspoofer"
+	"github.com/Onyz107/dnsspoofer/internal/control"
+	"github.com/Onyz107/dnsspoofer/internal/grpcapi/pb"
+	"github.com/Onyz107/dnsspoofer/internal/logger"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// NewServer returns the gRPC service of engine and rules.
+//
+// Events only reach WatchEvents streams once Publish is wired into EngineOptions.OnEvent.
+func NewServer(engine *dnsspoofer.Engine, rules *dnsspoofer.RuleSet) *Server {
+	return &Server{
+		engine:   engine,
+		rules:    rules,
+		watchers: make(map[chan dnsspoofer.Event]struct{}),
+	}
+}
+
+// Register registers s on srv, e.g. an in-process server listening on a bufconn.Listener.
+func (s *Server) Register(srv *grpc.Server) {
+	pb.RegisterDNSSpooferServer(srv, s)
+}
+
+// Serve serves the gRPC API of s on addr, either "unix:/path/to.sock" or "host:port", until ctx is done.
+//
+// A non-loopback host:port is refused unless opts enable TLS, with or without a bearer token.
+// Returns an error if addr cannot be listened on, serving itself happens in the background.
+func Serve(ctx context.Context, addr string, s *Server, opts Options) error {
+	log := logger.LoggerFrom(ctx)
+
+	serverOpts, err := serverOptions(opts)
+	if err != nil {
+		return err
+	}
+
+	var ln net.Listener
+	if path, ok := strings.CutPrefix(addr, unixPrefix); ok {
+		if ln, err = control.ListenUnix(path); err != nil {
+			return errors.Join(ErrListen, err)
+		}
+	} else {
+		var loopback bool
+		if loopback, err = isLoopback(addr); err != nil {
+			return errors.Join(ErrListen, err)
+		}
+		if !loopback && !opts.secured() {
+			return ErrInsecure
+		}
+		if ln, err = net.Listen("tcp", addr); err != nil {
+			return errors.Join(ErrListen, err)
+		}
+	}
+
+	srv := grpc.NewServer(serverOpts...)
+	s.Register(srv)
+	go func() {
+		<-ctx.Done()
+		srv.Stop()
+	}()
+	go func() {
+		log.Info("serving gRPC API", "addr", addr)
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+			log.Error(ErrListen.Error(), "err", err)
+		}
+	}()
+
+	return nil
+}
+
+// Publish hands ev to every WatchEvents stream, streams that fall behind miss events.
+func (s *Server) Publish(ev dnsspoofer.Event) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for ch := range s.watchers {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// WatchEvents streams the published events matching req until the client goes away.
+func (s *Server) WatchEvents(req *pb.WatchEventsRequest, stream grpc.ServerStreamingServer[pb.Event]) error {
+	types := make(map[dnsspoofer.EventType]bool, len(req.GetTypes()))
+	for _, t := range req.GetTypes() {
+		if t <= pb.EventType_EVENT_TYPE_UNSPECIFIED || t > pb.EventType_EVENT_TYPE_DROP {
+			return status.Error(codes.InvalidArgument, fmt.Sprintf("%s: %d", ErrInvalidType, t))
+		}
+		types[dnsspoofer.EventType(t-1)] = true
+	}
+
+	ch := make(chan dnsspoofer.Event, watchBuffer)
+	s.mu.Lock()
+	s.watchers[ch] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.watchers, ch)
+		s.mu.Unlock()
+	}()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case ev := <-ch:
+			if len(types) > 0 && !types[ev.Type] {
+				continue
+			}
+			if err := stream.Send(newEvent(ev, req.GetPackets())); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// ApplyRules adds the requested rules, or replaces the rule set with them.
+func (s *Server) ApplyRules(ctx context.Context, req *pb.ApplyRulesRequest) (*pb.ApplyRulesResponse, error) {
+	rules := make([]dnsspoofer.Rule, 0, len(req.GetRules()))
+	for _, r := range req.GetRules() {
+		rule := dnsspoofer.Rule{Name: r.GetName(), Pattern: r.GetPattern()}
+		for _, s := range r.GetIps() {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("%s: %s", ErrInvalidIP, s))
+			}
+			rule.IPs = append(rule.IPs, ip)
+		}
+		rules = append(rules, rule)
+	}
+
+	var err error
+	if req.GetReplace() {
+		err = s.rules.Replace(rules...)
+	} else {
+		err = s.rules.Add(rules...)
+	}
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return &pb.ApplyRulesResponse{Rules: uint32(len(s.rules.List()))}, nil
+}
+
+// GetStats returns the engine statistics.
+func (s *Server) GetStats(ctx context.Context, req *pb.GetStatsRequest) (*pb.Stats, error) {
+	st := control.NewStats(s.engine.Stats())
+	return &pb.Stats{
+		Packets:   st.Packets,
+		Matched:   st.Matched,
+		Spoofed:   st.Spoofed,
+		Skipped:   st.Skipped,
+		Dropped:   st.Dropped,
+		Failed:    st.Failed,
+		QueueFull: st.QueueFull,
+
+		KernelPackets: st.KernelPackets,
+		KernelBytes:   st.KernelBytes,
+
+		Records:     st.Records,
+		Rules:       st.Rules,
+		Clients:     st.Clients,
+		Errors:      st.Errors,
+		ParseErrors: st.ParseErrors,
+
+		SpoofLatency: &pb.Histogram{
+			Count:   st.SpoofLatency.Count,
+			Sum:     durationpb.New(st.SpoofLatency.Sum),
+			Buckets: st.SpoofLatency.Buckets,
+		},
+		Backlog:        uint32(st.Backlog),
+		RulesInstalled: st.RulesInstalled,
+	}, nil
+}
+
+// newEvent converts an engine event into its protobuf form, with the packets only if packets is set.
+func newEvent(ev dnsspoofer.Event, packets bool) *pb.Event {
+	records := func(rs []dnsspoofer.Record) []*pb.Record {
+		out := make([]*pb.Record, 0, len(rs))
+		for _, r := range rs {
+			out = append(out, &pb.Record{Name: r.Name, Type: r.Type, Ttl: r.TTL, Data: r.Data})
+		}
+		return out
+	}
+	addr := func(ip net.IP) string {
+		if ip == nil {
+			return ""
+		}
+		return ip.String()
+	}
+
+	out := &pb.Event{
+		Type:      pb.EventType(ev.Type + 1),
+		Time:      timestamppb.New(ev.Time),
+		Client:    addr(ev.Client),
+		Server:    addr(ev.Server),
+		Name:      ev.Name,
+		Qtype:     ev.QType,
+		IsRequest: ev.IsRequest,
+		Rules:     ev.Rules,
+		Original:  records(ev.Original),
+		Forged:    records(ev.Forged),
+		Latency:   durationpb.New(ev.Latency),
+		Verdict:   ev.Verdict,
+	}
+	if ev.Err != nil {
+		out.Error = ev.Err.Error()
+	}
+	if packets {
+		out.Packet = ev.Packet
+		out.ForgedPacket = ev.ForgedPacket
+	}
+	return out
+}
@@ -0,0 +1,189 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Onyz107/dnsspoofer"
+	"github.com/Onyz107/dnsspoofer/internal/grpcapi/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// dial registers a service on an in-process server with opts and returns a client of it.
+func dial(t *testing.T, opts Options) (*Server, pb.DNSSpooferClient) {
+	t.Helper()
+	rules, err := dnsspoofer.NewRuleSet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(dnsspoofer.New(&dnsspoofer.EngineOptions{Decider: rules}), rules)
+
+	serverOpts, err := serverOptions(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer(serverOpts...)
+	s.Register(srv)
+	ln := bufconn.Listen(1 << 16)
+	go srv.Serve(ln)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return ln.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return s, pb.NewDNSSpooferClient(conn)
+}
+
+func TestApplyRules(t *testing.T) {
+	_, client := dial(t, Options{})
+	ctx := context.Background()
+
+	resp, err := client.ApplyRules(ctx, &pb.ApplyRulesRequest{Rules: []*pb.Rule{
+		{Name: "a", Pattern: "*.example.com", Ips: []string{"10.0.0.1"}},
+		{Name: "b", Pattern: "example.org", Ips: []string{"fd00::1"}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetRules() != 2 {
+		t.Errorf("rules = %d, want 2", resp.GetRules())
+	}
+
+	resp, err = client.ApplyRules(ctx, &pb.ApplyRulesRequest{Replace: true, Rules: []*pb.Rule{
+		{Name: "c", Pattern: "example.net", Ips: []string{"10.0.0.2"}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetRules() != 1 {
+		t.Errorf("rules after replace = %d, want 1", resp.GetRules())
+	}
+
+	_, err = client.ApplyRules(ctx, &pb.ApplyRulesRequest{Rules: []*pb.Rule{{Name: "d", Pattern: "x", Ips: []string{"not an ip"}}}})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("invalid IP error = %v, want %v", err, codes.InvalidArgument)
+	}
+}
+
+func TestGetStats(t *testing.T) {
+	_, client := dial(t, Options{})
+	st, err := client.GetStats(context.Background(), &pb.GetStatsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.GetPackets() != 0 || st.GetRulesInstalled() {
+		t.Errorf("stats of an idle engine = %v", st)
+	}
+}
+
+func TestWatchEvents(t *testing.T) {
+	s, client := dial(t, Options{})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := client.WatchEvents(ctx, &pb.WatchEventsRequest{Types: []pb.EventType{pb.EventType_EVENT_TYPE_SPOOF}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The stream only exists on the server once its first message went through.
+	go func() {
+		for ctx.Err() == nil {
+			s.Publish(dnsspoofer.Event{Type: dnsspoofer.EventQuery, Name: "skipped.example.com"})
+			s.Publish(dnsspoofer.Event{Type: dnsspoofer.EventSpoof, Name: "example.com", Packet: []byte{1}})
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	ev, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev.GetType() != pb.EventType_EVENT_TYPE_SPOOF || ev.GetName() != "example.com" {
+		t.Errorf("event = %v, want the spoof of example.com", ev)
+	}
+	if ev.GetPacket() != nil {
+		t.Errorf("packet = %v, want none without packets", ev.GetPacket())
+	}
+
+	stream, err = client.WatchEvents(ctx, &pb.WatchEventsRequest{Types: []pb.EventType{pb.EventType_EVENT_TYPE_UNSPECIFIED}})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("invalid type error = %v, want %v", err, codes.InvalidArgument)
+	}
+}
+
+func TestToken(t *testing.T) {
+	_, client := dial(t, Options{Token: "secret"})
+
+	tests := []struct {
+		name  string
+		value string
+		want  codes.Code
+	}{
+		{"missing", "", codes.Unauthenticated},
+		{"wrong", "Bearer nope", codes.Unauthenticated},
+		{"no scheme", "secret", codes.Unauthenticated},
+		{"valid", "Bearer secret", codes.OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.value != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, authorization, tt.value)
+			}
+			if _, err := client.GetStats(ctx, &pb.GetStatsRequest{}); status.Code(err) != tt.want {
+				t.Errorf("GetStats() error = %v, want %v", err, tt.want)
+			}
+
+			stream, err := client.WatchEvents(ctx, &pb.WatchEventsRequest{})
+			if err == nil && tt.want != codes.OK {
+				_, err = stream.Recv()
+			}
+			if status.Code(err) != tt.want {
+				t.Errorf("WatchEvents() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestServeRefusesInsecure(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := NewServer(nil, nil)
+
+	tests := []struct {
+		name string
+		addr string
+		opts Options
+		want error
+	}{
+		{"all interfaces", ":0", Options{}, ErrInsecure},
+		{"wildcard ipv4", "0.0.0.0:0", Options{}, ErrInsecure},
+		{"client CA without TLS", "127.0.0.1:0", Options{ClientCAFile: "ca.pem"}, ErrClientCA},
+		{"missing certificate", "127.0.0.1:0", Options{CertFile: "missing.pem", KeyFile: "missing.pem"}, ErrLoadTLS},
+		{"loopback", "127.0.0.1:0", Options{}, nil},
+		{"token without TLS on all interfaces", "0.0.0.0:0", Options{Token: "secret"}, ErrInsecure},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Serve(ctx, tt.addr, s, tt.opts); !errors.Is(err, tt.want) {
+				t.Errorf("Serve(%q) error = %v, want %v", tt.addr, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,978 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: dnsspoofer.proto
+
+package pb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	durationpb "google.golang.org/protobuf/types/known/durationpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type EventType int32
+
+const (
+	EventType_EVENT_TYPE_UNSPECIFIED EventType = 0
+	EventType_EVENT_TYPE_QUERY       EventType = 1
+	EventType_EVENT_TYPE_MATCH       EventType = 2
+	EventType_EVENT_TYPE_SPOOF       EventType = 3
+	EventType_EVENT_TYPE_ACCEPT      EventType = 4
+	EventType_EVENT_TYPE_ERROR       EventType = 5
+	EventType_EVENT_TYPE_DROP        EventType = 6
+)
+
+// Enum value maps for EventType.
+var (
+	EventType_name = map[int32]string{
+		0: "EVENT_TYPE_UNSPECIFIED",
+		1: "EVENT_TYPE_QUERY",
+		2: "EVENT_TYPE_MATCH",
+		3: "EVENT_TYPE_SPOOF",
+		4: "EVENT_TYPE_ACCEPT",
+		5: "EVENT_TYPE_ERROR",
+		6: "EVENT_TYPE_DROP",
+	}
+	EventType_value = map[string]int32{
+		"EVENT_TYPE_UNSPECIFIED": 0,
+		"EVENT_TYPE_QUERY":       1,
+		"EVENT_TYPE_MATCH":       2,
+		"EVENT_TYPE_SPOOF":       3,
+		"EVENT_TYPE_ACCEPT":      4,
+		"EVENT_TYPE_ERROR":       5,
+		"EVENT_TYPE_DROP":        6,
+	}
+)
+
+func (x EventType) Enum() *EventType {
+	p := new(EventType)
+	*p = x
+	return p
+}
+
+func (x EventType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (EventType) Descriptor() protoreflect.EnumDescriptor {
+	return file_dnsspoofer_proto_enumTypes[0].Descriptor()
+}
+
+func (EventType) Type() protoreflect.EnumType {
+	return &file_dnsspoofer_proto_enumTypes[0]
+}
+
+func (x EventType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use EventType.Descriptor instead.
+func (EventType) EnumDescriptor() ([]byte, []int) {
+	return file_dnsspoofer_proto_rawDescGZIP(), []int{0}
+}
+
+type WatchEventsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types filters the streamed events, every type is streamed if empty.
+	Types []EventType `protobuf:"varint,1,rep,packed,name=types,proto3,enum=dnsspoofer.v1.EventType" json:"types,omitempty"`
+	// Packets includes the queued and forged packets in the events.
+	Packets       bool `protobuf:"varint,2,opt,name=packets,proto3" json:"packets,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchEventsRequest) Reset() {
+	*x = WatchEventsRequest{}
+	mi := &file_dnsspoofer_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchEventsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchEventsRequest) ProtoMessage() {}
+
+func (x *WatchEventsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_dnsspoofer_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchEventsRequest.ProtoReflect.Descriptor instead.
+func (*WatchEventsRequest) Descriptor() ([]byte, []int) {
+	return file_dnsspoofer_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *WatchEventsRequest) GetTypes() []EventType {
+	if x != nil {
+		return x.Types
+	}
+	return nil
+}
+
+func (x *WatchEventsRequest) GetPackets() bool {
+	if x != nil {
+		return x.Packets
+	}
+	return false
+}
+
+type Record struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Type          string                 `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	Ttl           uint32                 `protobuf:"varint,3,opt,name=ttl,proto3" json:"ttl,omitempty"`
+	Data          string                 `protobuf:"bytes,4,opt,name=data,proto3" json:"data,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Record) Reset() {
+	*x = Record{}
+	mi := &file_dnsspoofer_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Record) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Record) ProtoMessage() {}
+
+func (x *Record) ProtoReflect() protoreflect.Message {
+	mi := &file_dnsspoofer_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Record.ProtoReflect.Descriptor instead.
+func (*Record) Descriptor() ([]byte, []int) {
+	return file_dnsspoofer_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Record) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Record) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *Record) GetTtl() uint32 {
+	if x != nil {
+		return x.Ttl
+	}
+	return 0
+}
+
+func (x *Record) GetData() string {
+	if x != nil {
+		return x.Data
+	}
+	return ""
+}
+
+type Event struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Type          EventType              `protobuf:"varint,1,opt,name=type,proto3,enum=dnsspoofer.v1.EventType" json:"type,omitempty"`
+	Time          *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=time,proto3" json:"time,omitempty"`
+	Client        string                 `protobuf:"bytes,3,opt,name=client,proto3" json:"client,omitempty"`
+	Server        string                 `protobuf:"bytes,4,opt,name=server,proto3" json:"server,omitempty"`
+	Name          string                 `protobuf:"bytes,5,opt,name=name,proto3" json:"name,omitempty"`
+	Qtype         string                 `protobuf:"bytes,6,opt,name=qtype,proto3" json:"qtype,omitempty"`
+	IsRequest     bool                   `protobuf:"varint,7,opt,name=is_request,json=isRequest,proto3" json:"is_request,omitempty"`
+	Rules         []string               `protobuf:"bytes,8,rep,name=rules,proto3" json:"rules,omitempty"`
+	Original      []*Record              `protobuf:"bytes,9,rep,name=original,proto3" json:"original,omitempty"`
+	Forged        []*Record              `protobuf:"bytes,10,rep,name=forged,proto3" json:"forged,omitempty"`
+	Latency       *durationpb.Duration   `protobuf:"bytes,11,opt,name=latency,proto3" json:"latency,omitempty"`
+	Error         string                 `protobuf:"bytes,12,opt,name=error,proto3" json:"error,omitempty"`
+	Verdict       string                 `protobuf:"bytes,13,opt,name=verdict,proto3" json:"verdict,omitempty"`
+	Packet        []byte                 `protobuf:"bytes,14,opt,name=packet,proto3" json:"packet,omitempty"`
+	ForgedPacket  []byte                 `protobuf:"bytes,15,opt,name=forged_packet,json=forgedPacket,proto3" json:"forged_packet,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Event) Reset() {
+	*x = Event{}
+	mi := &file_dnsspoofer_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Event) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Event) ProtoMessage() {}
+
+func (x *Event) ProtoReflect() protoreflect.Message {
+	mi := &file_dnsspoofer_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Event.ProtoReflect.Descriptor instead.
+func (*Event) Descriptor() ([]byte, []int) {
+	return file_dnsspoofer_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Event) GetType() EventType {
+	if x != nil {
+		return x.Type
+	}
+	return EventType_EVENT_TYPE_UNSPECIFIED
+}
+
+func (x *Event) GetTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+func (x *Event) GetClient() string {
+	if x != nil {
+		return x.Client
+	}
+	return ""
+}
+
+func (x *Event) GetServer() string {
+	if x != nil {
+		return x.Server
+	}
+	return ""
+}
+
+func (x *Event) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Event) GetQtype() string {
+	if x != nil {
+		return x.Qtype
+	}
+	return ""
+}
+
+func (x *Event) GetIsRequest() bool {
+	if x != nil {
+		return x.IsRequest
+	}
+	return false
+}
+
+func (x *Event) GetRules() []string {
+	if x != nil {
+		return x.Rules
+	}
+	return nil
+}
+
+func (x *Event) GetOriginal() []*Record {
+	if x != nil {
+		return x.Original
+	}
+	return nil
+}
+
+func (x *Event) GetForged() []*Record {
+	if x != nil {
+		return x.Forged
+	}
+	return nil
+}
+
+func (x *Event) GetLatency() *durationpb.Duration {
+	if x != nil {
+		return x.Latency
+	}
+	return nil
+}
+
+func (x *Event) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *Event) GetVerdict() string {
+	if x != nil {
+		return x.Verdict
+	}
+	return ""
+}
+
+func (x *Event) GetPacket() []byte {
+	if x != nil {
+		return x.Packet
+	}
+	return nil
+}
+
+func (x *Event) GetForgedPacket() []byte {
+	if x != nil {
+		return x.ForgedPacket
+	}
+	return nil
+}
+
+type Rule struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Pattern       string                 `protobuf:"bytes,2,opt,name=pattern,proto3" json:"pattern,omitempty"`
+	Ips           []string               `protobuf:"bytes,3,rep,name=ips,proto3" json:"ips,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Rule) Reset() {
+	*x = Rule{}
+	mi := &file_dnsspoofer_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Rule) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Rule) ProtoMessage() {}
+
+func (x *Rule) ProtoReflect() protoreflect.Message {
+	mi := &file_dnsspoofer_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Rule.ProtoReflect.Descriptor instead.
+func (*Rule) Descriptor() ([]byte, []int) {
+	return file_dnsspoofer_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Rule) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Rule) GetPattern() string {
+	if x != nil {
+		return x.Pattern
+	}
+	return ""
+}
+
+func (x *Rule) GetIps() []string {
+	if x != nil {
+		return x.Ips
+	}
+	return nil
+}
+
+type ApplyRulesRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Rules []*Rule                `protobuf:"bytes,1,rep,name=rules,proto3" json:"rules,omitempty"`
+	// Replace swaps the whole rule set for rules instead of adding them.
+	Replace       bool `protobuf:"varint,2,opt,name=replace,proto3" json:"replace,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ApplyRulesRequest) Reset() {
+	*x = ApplyRulesRequest{}
+	mi := &file_dnsspoofer_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ApplyRulesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ApplyRulesRequest) ProtoMessage() {}
+
+func (x *ApplyRulesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_dnsspoofer_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ApplyRulesRequest.ProtoReflect.Descriptor instead.
+func (*ApplyRulesRequest) Descriptor() ([]byte, []int) {
+	return file_dnsspoofer_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ApplyRulesRequest) GetRules() []*Rule {
+	if x != nil {
+		return x.Rules
+	}
+	return nil
+}
+
+func (x *ApplyRulesRequest) GetReplace() bool {
+	if x != nil {
+		return x.Replace
+	}
+	return false
+}
+
+type ApplyRulesResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Rules is the number of active rules after applying the request.
+	Rules         uint32 `protobuf:"varint,1,opt,name=rules,proto3" json:"rules,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ApplyRulesResponse) Reset() {
+	*x = ApplyRulesResponse{}
+	mi := &file_dnsspoofer_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ApplyRulesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ApplyRulesResponse) ProtoMessage() {}
+
+func (x *ApplyRulesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_dnsspoofer_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ApplyRulesResponse.ProtoReflect.Descriptor instead.
+func (*ApplyRulesResponse) Descriptor() ([]byte, []int) {
+	return file_dnsspoofer_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ApplyRulesResponse) GetRules() uint32 {
+	if x != nil {
+		return x.Rules
+	}
+	return 0
+}
+
+type GetStatsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetStatsRequest) Reset() {
+	*x = GetStatsRequest{}
+	mi := &file_dnsspoofer_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetStatsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetStatsRequest) ProtoMessage() {}
+
+func (x *GetStatsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_dnsspoofer_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetStatsRequest.ProtoReflect.Descriptor instead.
+func (*GetStatsRequest) Descriptor() ([]byte, []int) {
+	return file_dnsspoofer_proto_rawDescGZIP(), []int{6}
+}
+
+type Histogram struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Count uint64                 `protobuf:"varint,1,opt,name=count,proto3" json:"count,omitempty"`
+	Sum   *durationpb.Duration   `protobuf:"bytes,2,opt,name=sum,proto3" json:"sum,omitempty"`
+	// Buckets are keyed by their upper bound as a Go duration string ("1ms").
+	Buckets       map[string]uint64 `protobuf:"bytes,3,rep,name=buckets,proto3" json:"buckets,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Histogram) Reset() {
+	*x = Histogram{}
+	mi := &file_dnsspoofer_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Histogram) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Histogram) ProtoMessage() {}
+
+func (x *Histogram) ProtoReflect() protoreflect.Message {
+	mi := &file_dnsspoofer_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Histogram.ProtoReflect.Descriptor instead.
+func (*Histogram) Descriptor() ([]byte, []int) {
+	return file_dnsspoofer_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Histogram) GetCount() uint64 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+func (x *Histogram) GetSum() *durationpb.Duration {
+	if x != nil {
+		return x.Sum
+	}
+	return nil
+}
+
+func (x *Histogram) GetBuckets() map[string]uint64 {
+	if x != nil {
+		return x.Buckets
+	}
+	return nil
+}
+
+type Stats struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Packets        uint64                 `protobuf:"varint,1,opt,name=packets,proto3" json:"packets,omitempty"`
+	Matched        uint64                 `protobuf:"varint,2,opt,name=matched,proto3" json:"matched,omitempty"`
+	Spoofed        uint64                 `protobuf:"varint,3,opt,name=spoofed,proto3" json:"spoofed,omitempty"`
+	Skipped        uint64                 `protobuf:"varint,4,opt,name=skipped,proto3" json:"skipped,omitempty"`
+	Dropped        uint64                 `protobuf:"varint,5,opt,name=dropped,proto3" json:"dropped,omitempty"`
+	Failed         uint64                 `protobuf:"varint,6,opt,name=failed,proto3" json:"failed,omitempty"`
+	QueueFull      uint64                 `protobuf:"varint,7,opt,name=queue_full,json=queueFull,proto3" json:"queue_full,omitempty"`
+	Records        map[string]uint64      `protobuf:"bytes,8,rep,name=records,proto3" json:"records,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	Rules          map[string]uint64      `protobuf:"bytes,9,rep,name=rules,proto3" json:"rules,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	Clients        map[string]uint64      `protobuf:"bytes,10,rep,name=clients,proto3" json:"clients,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	Errors         map[string]uint64      `protobuf:"bytes,11,rep,name=errors,proto3" json:"errors,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	ParseErrors    map[string]uint64      `protobuf:"bytes,12,rep,name=parse_errors,json=parseErrors,proto3" json:"parse_errors,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	SpoofLatency   *Histogram             `protobuf:"bytes,13,opt,name=spoof_latency,json=spoofLatency,proto3" json:"spoof_latency,omitempty"`
+	Backlog        uint32                 `protobuf:"varint,14,opt,name=backlog,proto3" json:"backlog,omitempty"`
+	RulesInstalled bool                   `protobuf:"varint,15,opt,name=rules_installed,json=rulesInstalled,proto3" json:"rules_installed,omitempty"`
+	// Kernel counters of the installed nftables or iptables rule.
+	KernelPackets uint64 `protobuf:"varint,16,opt,name=kernel_packets,json=kernelPackets,proto3" json:"kernel_packets,omitempty"`
+	KernelBytes   uint64 `protobuf:"varint,17,opt,name=kernel_bytes,json=kernelBytes,proto3" json:"kernel_bytes,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Stats) Reset() {
+	*x = Stats{}
+	mi := &file_dnsspoofer_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Stats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Stats) ProtoMessage() {}
+
+func (x *Stats) ProtoReflect() protoreflect.Message {
+	mi := &file_dnsspoofer_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Stats.ProtoReflect.Descriptor instead.
+func (*Stats) Descriptor() ([]byte, []int) {
+	return file_dnsspoofer_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Stats) GetPackets() uint64 {
+	if x != nil {
+		return x.Packets
+	}
+	return 0
+}
+
+func (x *Stats) GetMatched() uint64 {
+	if x != nil {
+		return x.Matched
+	}
+	return 0
+}
+
+func (x *Stats) GetSpoofed() uint64 {
+	if x != nil {
+		return x.Spoofed
+	}
+	return 0
+}
+
+func (x *Stats) GetSkipped() uint64 {
+	if x != nil {
+		return x.Skipped
+	}
+	return 0
+}
+
+func (x *Stats) GetDropped() uint64 {
+	if x != nil {
+		return x.Dropped
+	}
+	return 0
+}
+
+func (x *Stats) GetFailed() uint64 {
+	if x != nil {
+		return x.Failed
+	}
+	return 0
+}
+
+func (x *Stats) GetQueueFull() uint64 {
+	if x != nil {
+		return x.QueueFull
+	}
+	return 0
+}
+
+func (x *Stats) GetRecords() map[string]uint64 {
+	if x != nil {
+		return x.Records
+	}
+	return nil
+}
+
+func (x *Stats) GetRules() map[string]uint64 {
+	if x != nil {
+		return x.Rules
+	}
+	return nil
+}
+
+func (x *Stats) GetClients() map[string]uint64 {
+	if x != nil {
+		return x.Clients
+	}
+	return nil
+}
+
+func (x *Stats) GetErrors() map[string]uint64 {
+	if x != nil {
+		return x.Errors
+	}
+	return nil
+}
+
+func (x *Stats) GetParseErrors() map[string]uint64 {
+	if x != nil {
+		return x.ParseErrors
+	}
+	return nil
+}
+
+func (x *Stats) GetSpoofLatency() *Histogram {
+	if x != nil {
+		return x.SpoofLatency
+	}
+	return nil
+}
+
+func (x *Stats) GetBacklog() uint32 {
+	if x != nil {
+		return x.Backlog
+	}
+	return 0
+}
+
+func (x *Stats) GetRulesInstalled() bool {
+	if x != nil {
+		return x.RulesInstalled
+	}
+	return false
+}
+
+func (x *Stats) GetKernelPackets() uint64 {
+	if x != nil {
+		return x.KernelPackets
+	}
+	return 0
+}
+
+func (x *Stats) GetKernelBytes() uint64 {
+	if x != nil {
+		return x.KernelBytes
+	}
+	return 0
+}
+
+var File_dnsspoofer_proto protoreflect.FileDescriptor
+
+const file_dnsspoofer_proto_rawDesc = "" +
+	"\n" +
+	"\x10dnsspoofer.proto\x12\rdnsspoofer.v1\x1a\x1egoogle/protobuf/duration.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"^\n" +
+	"\x12WatchEventsRequest\x12.\n" +
+	"\x05types\x18\x01 \x03(\x0e2\x18.dnsspoofer.v1.EventTypeR\x05types\x12\x18\n" +
+	"\apackets\x18\x02 \x01(\bR\apackets\"V\n" +
+	"\x06Record\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x12\n" +
+	"\x04type\x18\x02 \x01(\tR\x04type\x12\x10\n" +
+	"\x03ttl\x18\x03 \x01(\rR\x03ttl\x12\x12\n" +
+	"\x04data\x18\x04 \x01(\tR\x04data\"\xf8\x03\n" +
+	"\x05Event\x12,\n" +
+	"\x04type\x18\x01 \x01(\x0e2\x18.dnsspoofer.v1.EventTypeR\x04type\x12.\n" +
+	"\x04time\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x04time\x12\x16\n" +
+	"\x06client\x18\x03 \x01(\tR\x06client\x12\x16\n" +
+	"\x06server\x18\x04 \x01(\tR\x06server\x12\x12\n" +
+	"\x04name\x18\x05 \x01(\tR\x04name\x12\x14\n" +
+	"\x05qtype\x18\x06 \x01(\tR\x05qtype\x12\x1d\n" +
+	"\n" +
+	"is_request\x18\a \x01(\bR\tisRequest\x12\x14\n" +
+	"\x05rules\x18\b \x03(\tR\x05rules\x121\n" +
+	"\boriginal\x18\t \x03(\v2\x15.dnsspoofer.v1.RecordR\boriginal\x12-\n" +
+	"\x06forged\x18\n" +
+	" \x03(\v2\x15.dnsspoofer.v1.RecordR\x06forged\x123\n" +
+	"\alatency\x18\v \x01(\v2\x19.google.protobuf.DurationR\alatency\x12\x14\n" +
+	"\x05error\x18\f \x01(\tR\x05error\x12\x18\n" +
+	"\averdict\x18\r \x01(\tR\averdict\x12\x16\n" +
+	"\x06packet\x18\x0e \x01(\fR\x06packet\x12#\n" +
+	"\rforged_packet\x18\x0f \x01(\fR\fforgedPacket\"F\n" +
+	"\x04Rule\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x18\n" +
+	"\apattern\x18\x02 \x01(\tR\apattern\x12\x10\n" +
+	"\x03ips\x18\x03 \x03(\tR\x03ips\"X\n" +
+	"\x11ApplyRulesRequest\x12)\n" +
+	"\x05rules\x18\x01 \x03(\v2\x13.dnsspoofer.v1.RuleR\x05rules\x12\x18\n" +
+	"\areplace\x18\x02 \x01(\bR\areplace\"*\n" +
+	"\x12ApplyRulesResponse\x12\x14\n" +
+	"\x05rules\x18\x01 \x01(\rR\x05rules\"\x11\n" +
+	"\x0fGetStatsRequest\"\xcb\x01\n" +
+	"\tHistogram\x12\x14\n" +
+	"\x05count\x18\x01 \x01(\x04R\x05count\x12+\n" +
+	"\x03sum\x18\x02 \x01(\v2\x19.google.protobuf.DurationR\x03sum\x12?\n" +
+	"\abuckets\x18\x03 \x03(\v2%.dnsspoofer.v1.Histogram.BucketsEntryR\abuckets\x1a:\n" +
+	"\fBucketsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x04R\x05value:\x028\x01\"\xee\a\n" +
+	"\x05Stats\x12\x18\n" +
+	"\apackets\x18\x01 \x01(\x04R\apackets\x12\x18\n" +
+	"\amatched\x18\x02 \x01(\x04R\amatched\x12\x18\n" +
+	"\aspoofed\x18\x03 \x01(\x04R\aspoofed\x12\x18\n" +
+	"\askipped\x18\x04 \x01(\x04R\askipped\x12\x18\n" +
+	"\adropped\x18\x05 \x01(\x04R\adropped\x12\x16\n" +
+	"\x06failed\x18\x06 \x01(\x04R\x06failed\x12\x1d\n" +
+	"\n" +
+	"queue_full\x18\a \x01(\x04R\tqueueFull\x12;\n" +
+	"\arecords\x18\b \x03(\v2!.dnsspoofer.v1.Stats.RecordsEntryR\arecords\x125\n" +
+	"\x05rules\x18\t \x03(\v2\x1f.dnsspoofer.v1.Stats.RulesEntryR\x05rules\x12;\n" +
+	"\aclients\x18\n" +
+	" \x03(\v2!.dnsspoofer.v1.Stats.ClientsEntryR\aclients\x128\n" +
+	"\x06errors\x18\v \x03(\v2 .dnsspoofer.v1.Stats.ErrorsEntryR\x06errors\x12H\n" +
+	"\fparse_errors\x18\f \x03(\v2%.dnsspoofer.v1.Stats.ParseErrorsEntryR\vparseErrors\x12=\n" +
+	"\rspoof_latency\x18\r \x01(\v2\x18.dnsspoofer.v1.HistogramR\fspoofLatency\x12\x18\n" +
+	"\abacklog\x18\x0e \x01(\rR\abacklog\x12'\n" +
+	"\x0frules_installed\x18\x0f \x01(\bR\x0erulesInstalled\x12%\n" +
+	"\x0ekernel_packets\x18\x10 \x01(\x04R\rkernelPackets\x12!\n" +
+	"\fkernel_bytes\x18\x11 \x01(\x04R\vkernelBytes\x1a:\n" +
+	"\fRecordsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x04R\x05value:\x028\x01\x1a8\n" +
+	"\n" +
+	"RulesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x04R\x05value:\x028\x01\x1a:\n" +
+	"\fClientsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x04R\x05value:\x028\x01\x1a9\n" +
+	"\vErrorsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x04R\x05value:\x028\x01\x1a>\n" +
+	"\x10ParseErrorsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x04R\x05value:\x028\x01*\xab\x01\n" +
+	"\tEventType\x12\x1a\n" +
+	"\x16EVENT_TYPE_UNSPECIFIED\x10\x00\x12\x14\n" +
+	"\x10EVENT_TYPE_QUERY\x10\x01\x12\x14\n" +
+	"\x10EVENT_TYPE_MATCH\x10\x02\x12\x14\n" +
+	"\x10EVENT_TYPE_SPOOF\x10\x03\x12\x15\n" +
+	"\x11EVENT_TYPE_ACCEPT\x10\x04\x12\x14\n" +
+	"\x10EVENT_TYPE_ERROR\x10\x05\x12\x13\n" +
+	"\x0fEVENT_TYPE_DROP\x10\x062\xeb\x01\n" +
+	"\n" +
+	"DNSSpoofer\x12H\n" +
+	"\vWatchEvents\x12!.dnsspoofer.v1.WatchEventsRequest\x1a\x14.dnsspoofer.v1.Event0\x01\x12Q\n" +
+	"\n" +
+	"ApplyRules\x12 .dnsspoofer.v1.ApplyRulesRequest\x1a!.dnsspoofer.v1.ApplyRulesResponse\x12@\n" +
+	"\bGetStats\x12\x1e.dnsspoofer.v1.GetStatsRequest\x1a\x14.dnsspoofer.v1.StatsB3Z1github.com/Onyz107/dnsspoofer/internal/grpcapi/pbb\x06proto3"
+
+var (
+	file_dnsspoofer_proto_rawDescOnce sync.Once
+	file_dnsspoofer_proto_rawDescData []byte
+)
+
+func file_dnsspoofer_proto_rawDescGZIP() []byte {
+	file_dnsspoofer_proto_rawDescOnce.Do(func() {
+		file_dnsspoofer_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_dnsspoofer_proto_rawDesc), len(file_dnsspoofer_proto_rawDesc)))
+	})
+	return file_dnsspoofer_proto_rawDescData
+}
+
+var file_dnsspoofer_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_dnsspoofer_proto_msgTypes = make([]protoimpl.MessageInfo, 15)
+var file_dnsspoofer_proto_goTypes = []any{
+	(EventType)(0),                // 0: dnsspoofer.v1.EventType
+	(*WatchEventsRequest)(nil),    // 1: dnsspoofer.v1.WatchEventsRequest
+	(*Record)(nil),                // 2: dnsspoofer.v1.Record
+	(*Event)(nil),                 // 3: dnsspoofer.v1.Event
+	(*Rule)(nil),                  // 4: dnsspoofer.v1.Rule
+	(*ApplyRulesRequest)(nil),     // 5: dnsspoofer.v1.ApplyRulesRequest
+	(*ApplyRulesResponse)(nil),    // 6: dnsspoofer.v1.ApplyRulesResponse
+	(*GetStatsRequest)(nil),       // 7: dnsspoofer.v1.GetStatsRequest
+	(*Histogram)(nil),             // 8: dnsspoofer.v1.Histogram
+	(*Stats)(nil),                 // 9: dnsspoofer.v1.Stats
+	nil,                           // 10: dnsspoofer.v1.Histogram.BucketsEntry
+	nil,                           // 11: dnsspoofer.v1.Stats.RecordsEntry
+	nil,                           // 12: dnsspoofer.v1.Stats.RulesEntry
+	nil,                           // 13: dnsspoofer.v1.Stats.ClientsEntry
+	nil,                           // 14: dnsspoofer.v1.Stats.ErrorsEntry
+	nil,                           // 15: dnsspoofer.v1.Stats.ParseErrorsEntry
+	(*timestamppb.Timestamp)(nil), // 16: google.protobuf.Timestamp
+	(*durationpb.Duration)(nil),   // 17: google.protobuf.Duration
+}
+var file_dnsspoofer_proto_depIdxs = []int32{
+	0,  // 0: dnsspoofer.v1.WatchEventsRequest.types:type_name -> dnsspoofer.v1.EventType
+	0,  // 1: dnsspoofer.v1.Event.type:type_name -> dnsspoofer.v1.EventType
+	16, // 2: dnsspoofer.v1.Event.time:type_name -> google.protobuf.Timestamp
+	2,  // 3: dnsspoofer.v1.Event.original:type_name -> dnsspoofer.v1.Record
+	2,  // 4: dnsspoofer.v1.Event.forged:type_name -> dnsspoofer.v1.Record
+	17, // 5: dnsspoofer.v1.Event.latency:type_name -> google.protobuf.Duration
+	4,  // 6: dnsspoofer.v1.ApplyRulesRequest.rules:type_name -> dnsspoofer.v1.Rule
+	17, // 7: dnsspoofer.v1.Histogram.sum:type_name -> google.protobuf.Duration
+	10, // 8: dnsspoofer.v1.Histogram.buckets:type_name -> dnsspoofer.v1.Histogram.BucketsEntry
+	11, // 9: dnsspoofer.v1.Stats.records:type_name -> dnsspoofer.v1.Stats.RecordsEntry
+	12, // 10: dnsspoofer.v1.Stats.rules:type_name -> dnsspoofer.v1.Stats.RulesEntry
+	13, // 11: dnsspoofer.v1.Stats.clients:type_name -> dnsspoofer.v1.Stats.ClientsEntry
+	14, // 12: dnsspoofer.v1.Stats.errors:type_name -> dnsspoofer.v1.Stats.ErrorsEntry
+	15, // 13: dnsspoofer.v1.Stats.parse_errors:type_name -> dnsspoofer.v1.Stats.ParseErrorsEntry
+	8,  // 14: dnsspoofer.v1.Stats.spoof_latency:type_name -> dnsspoofer.v1.Histogram
+	1,  // 15: dnsspoofer.v1.DNSSpoofer.WatchEvents:input_type -> dnsspoofer.v1.WatchEventsRequest
+	5,  // 16: dnsspoofer.v1.DNSSpoofer.ApplyRules:input_type -> dnsspoofer.v1.ApplyRulesRequest
+	7,  // 17: dnsspoofer.v1.DNSSpoofer.GetStats:input_type -> dnsspoofer.v1.GetStatsRequest
+	3,  // 18: dnsspoofer.v1.DNSSpoofer.WatchEvents:output_type -> dnsspoofer.v1.Event
+	6,  // 19: dnsspoofer.v1.DNSSpoofer.ApplyRules:output_type -> dnsspoofer.v1.ApplyRulesResponse
+	9,  // 20: dnsspoofer.v1.DNSSpoofer.GetStats:output_type -> dnsspoofer.v1.Stats
+	18, // [18:21] is the sub-list for method output_type
+	15, // [15:18] is the sub-list for method input_type
+	15, // [15:15] is the sub-list for extension type_name
+	15, // [15:15] is the sub-list for extension extendee
+	0,  // [0:15] is the sub-list for field type_name
+}
+
+func init() { file_dnsspoofer_proto_init() }
+func file_dnsspoofer_proto_init() {
+	if File_dnsspoofer_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_dnsspoofer_proto_rawDesc), len(file_dnsspoofer_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   15,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_dnsspoofer_proto_goTypes,
+		DependencyIndexes: file_dnsspoofer_proto_depIdxs,
+		EnumInfos:         file_dnsspoofer_proto_enumTypes,
+		MessageInfos:      file_dnsspoofer_proto_msgTypes,
+	}.Build()
+	File_dnsspoofer_proto = out.File
+	file_dnsspoofer_proto_goTypes = nil
+	file_dnsspoofer_proto_depIdxs = nil
+}
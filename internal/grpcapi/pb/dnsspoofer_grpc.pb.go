@@ -0,0 +1,211 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: dnsspoofer.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	DNSSpoofer_WatchEvents_FullMethodName = "/dnsspoofer.v1.DNSSpoofer/WatchEvents"
+	DNSSpoofer_ApplyRules_FullMethodName  = "/dnsspoofer.v1.DNSSpoofer/ApplyRules"
+	DNSSpoofer_GetStats_FullMethodName    = "/dnsspoofer.v1.DNSSpoofer/GetStats"
+)
+
+// DNSSpooferClient is the client API for DNSSpoofer service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// DNSSpoofer exposes a running engine to orchestration tools.
+type DNSSpooferClient interface {
+	// WatchEvents streams the engine events until the client cancels.
+	WatchEvents(ctx context.Context, in *WatchEventsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error)
+	// ApplyRules adds rules to the active rule set, or replaces it.
+	ApplyRules(ctx context.Context, in *ApplyRulesRequest, opts ...grpc.CallOption) (*ApplyRulesResponse, error)
+	// GetStats returns the engine statistics.
+	GetStats(ctx context.Context, in *GetStatsRequest, opts ...grpc.CallOption) (*Stats, error)
+}
+
+type dNSSpooferClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewDNSSpooferClient(cc grpc.ClientConnInterface) DNSSpooferClient {
+	return &dNSSpooferClient{cc}
+}
+
+func (c *dNSSpooferClient) WatchEvents(ctx context.Context, in *WatchEventsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &DNSSpoofer_ServiceDesc.Streams[0], DNSSpoofer_WatchEvents_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchEventsRequest, Event]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type DNSSpoofer_WatchEventsClient = grpc.ServerStreamingClient[Event]
+
+func (c *dNSSpooferClient) ApplyRules(ctx context.Context, in *ApplyRulesRequest, opts ...grpc.CallOption) (*ApplyRulesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ApplyRulesResponse)
+	err := c.cc.Invoke(ctx, DNSSpoofer_ApplyRules_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dNSSpooferClient) GetStats(ctx context.Context, in *GetStatsRequest, opts ...grpc.CallOption) (*Stats, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Stats)
+	err := c.cc.Invoke(ctx, DNSSpoofer_GetStats_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DNSSpooferServer is the server API for DNSSpoofer service.
+// All implementations must embed UnimplementedDNSSpooferServer
+// for forward compatibility.
+//
+// DNSSpoofer exposes a running engine to orchestration tools.
+type DNSSpooferServer interface {
+	// WatchEvents streams the engine events until the client cancels.
+	WatchEvents(*WatchEventsRequest, grpc.ServerStreamingServer[Event]) error
+	// ApplyRules adds rules to the active rule set, or replaces it.
+	ApplyRules(context.Context, *ApplyRulesRequest) (*ApplyRulesResponse, error)
+	// GetStats returns the engine statistics.
+	GetStats(context.Context, *GetStatsRequest) (*Stats, error)
+	mustEmbedUnimplementedDNSSpooferServer()
+}
+
+// UnimplementedDNSSpooferServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedDNSSpooferServer struct{}
+
+func (UnimplementedDNSSpooferServer) WatchEvents(*WatchEventsRequest, grpc.ServerStreamingServer[Event]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchEvents not implemented")
+}
+func (UnimplementedDNSSpooferServer) ApplyRules(context.Context, *ApplyRulesRequest) (*ApplyRulesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ApplyRules not implemented")
+}
+func (UnimplementedDNSSpooferServer) GetStats(context.Context, *GetStatsRequest) (*Stats, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStats not implemented")
+}
+func (UnimplementedDNSSpooferServer) mustEmbedUnimplementedDNSSpooferServer() {}
+func (UnimplementedDNSSpooferServer) testEmbeddedByValue()                    {}
+
+// UnsafeDNSSpooferServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to DNSSpooferServer will
+// result in compilation errors.
+type UnsafeDNSSpooferServer interface {
+	mustEmbedUnimplementedDNSSpooferServer()
+}
+
+func RegisterDNSSpooferServer(s grpc.ServiceRegistrar, srv DNSSpooferServer) {
+	// If the following call pancis, it indicates UnimplementedDNSSpooferServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&DNSSpoofer_ServiceDesc, srv)
+}
+
+func _DNSSpoofer_WatchEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchEventsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(DNSSpooferServer).WatchEvents(m, &grpc.GenericServerStream[WatchEventsRequest, Event]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type DNSSpoofer_WatchEventsServer = grpc.ServerStreamingServer[Event]
+
+func _DNSSpoofer_ApplyRules_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ApplyRulesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DNSSpooferServer).ApplyRules(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DNSSpoofer_ApplyRules_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DNSSpooferServer).ApplyRules(ctx, req.(*ApplyRulesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DNSSpoofer_GetStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DNSSpooferServer).GetStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DNSSpoofer_GetStats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DNSSpooferServer).GetStats(ctx, req.(*GetStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// DNSSpoofer_ServiceDesc is the grpc.ServiceDesc for DNSSpoofer service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var DNSSpoofer_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "dnsspoofer.v1.DNSSpoofer",
+	HandlerType: (*DNSSpooferServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ApplyRules",
+			Handler:    _DNSSpoofer_ApplyRules_Handler,
+		},
+		{
+			MethodName: "GetStats",
+			Handler:    _DNSSpoofer_GetStats_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchEvents",
+			Handler:       _DNSSpoofer_WatchEvents_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "dnsspoofer.proto",
+}
@@ -0,0 +1,7 @@
+// Package pb holds the generated gRPC stubs of the dnsspoofer API.
+//
+// Regenerate them after editing dnsspoofer.proto with buf and the plugins named in the headers of the
+// generated files on the PATH: protoc-gen-go v1.36.11 and protoc-gen-go-grpc v1.5.1.
+package pb
+
+//go:generate buf generate
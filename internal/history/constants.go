@@ -0,0 +1,73 @@
+package history
+
+import (
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/Onyz107/dnsspoofer"
+)
+
+// backlog is the number of events buffered before new ones are dropped.
+const backlog = 4096
+
+// schema creates the history tables.
+const schema = `
+CREATE TABLE IF NOT EXISTS queries (
+	id         INTEGER PRIMARY KEY,
+	time       INTEGER NOT NULL,
+	client     TEXT NOT NULL,
+	server     TEXT NOT NULL,
+	qname      TEXT NOT NULL,
+	qtype      TEXT NOT NULL,
+	request    INTEGER NOT NULL,
+	action     TEXT NOT NULL,
+	original   TEXT,
+	spoofed    TEXT,
+	latency_us INTEGER NOT NULL,
+	error      TEXT
+);
+CREATE INDEX IF NOT EXISTS queries_client ON queries (client);
+CREATE INDEX IF NOT EXISTS queries_qname ON queries (qname);
+
+CREATE TABLE IF NOT EXISTS rule_hits (
+	query_id INTEGER NOT NULL REFERENCES queries (id),
+	rule     TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS rule_hits_rule ON rule_hits (rule);
+`
+
+// Store persists engine events into a SQLite database.
+type Store struct {
+	db *sql.DB
+
+	events  chan dnsspoofer.Event
+	done    chan struct{}
+	closing sync.Once
+}
+
+// DomainCount is a queried name and how often it was seen.
+type DomainCount struct {
+	Client  string
+	Name    string
+	Count   uint64
+	Spoofed uint64
+}
+
+// ClientSummary is the activity of one client.
+type ClientSummary struct {
+	Client    string
+	Queries   uint64
+	Spoofed   uint64
+	FirstSeen time.Time
+	LastSeen  time.Time
+}
+
+// RuleSummary is the activity of one rule.
+type RuleSummary struct {
+	Rule      string
+	Hits      uint64
+	Clients   uint64
+	FirstSeen time.Time
+	LastSeen  time.Time
+}
@@ -0,0 +1,9 @@
+package history
+
+import "errors"
+
+var (
+	ErrOpenDB      = errors.New("failed to open history database")
+	ErrInsertEvent = errors.New("failed to insert event into history")
+	ErrQuery       = errors.New("failed to query history")
+)
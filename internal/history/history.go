@@ -0,0 +1,232 @@
+package history
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/Onyz107/dnsspoofer"
+	"github.com/Onyz107/dnsspoofer/internal/logger"
+	_ "modernc.org/sqlite"
+)
+
+// Open opens or creates the history database at path.
+//
+// Events passed to Record are written in the background until Close is called,
+// write failures are logged with the logger of ctx.
+func Open(ctx context.Context, path string) (*Store, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, errors.Join(ErrOpenDB, err)
+	}
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.ExecContext(ctx, "PRAGMA journal_mode = WAL"); err != nil {
+		db.Close()
+		return nil, errors.Join(ErrOpenDB, err)
+	}
+	if _, err := db.ExecContext(ctx, schema); err != nil {
+		db.Close()
+		return nil, errors.Join(ErrOpenDB, err)
+	}
+
+	s := &Store{
+		db:     db,
+		events: make(chan dnsspoofer.Event, backlog),
+		done:   make(chan struct{}),
+	}
+	go s.writer(logger.LoggerFrom(ctx))
+
+	return s, nil
+}
+
+// Record queues ev for writing if it is a final event, see dnsspoofer.EventType.Final.
+//
+// It never blocks: events are dropped while the write backlog is full.
+func (s *Store) Record(ev dnsspoofer.Event) {
+	if !ev.Type.Final() {
+		return
+	}
+
+	select {
+	case s.events <- ev:
+	default:
+	}
+}
+
+// writer inserts queued events, batching whatever is pending into one transaction.
+func (s *Store) writer(log logger.Logger) {
+	defer close(s.done)
+
+	for ev := range s.events {
+		batch := []dnsspoofer.Event{ev}
+	drain:
+		for {
+			select {
+			case ev, ok := <-s.events:
+				if !ok {
+					break drain
+				}
+				batch = append(batch, ev)
+			default:
+				break drain
+			}
+		}
+
+		if err := s.insert(batch); err != nil {
+			log.Error(ErrInsertEvent.Error(), "err", err, "events", len(batch))
+		}
+	}
+}
+
+func (s *Store) insert(batch []dnsspoofer.Event) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, ev := range batch {
+		var client, server, errStr string
+		if ev.Client != nil {
+			client = ev.Client.String()
+		}
+		if ev.Server != nil {
+			server = ev.Server.String()
+		}
+		if ev.Err != nil {
+			errStr = ev.Err.Error()
+		}
+		original, _ := json.Marshal(ev.Original)
+		spoofed, _ := json.Marshal(ev.Forged)
+
+		res, err := tx.Exec(`INSERT INTO queries
+			(time, client, server, qname, qtype, request, action, original, spoofed, latency_us, error)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			ev.Time.UnixNano(), client, server, ev.Name, ev.QType, ev.IsRequest, ev.Type.String(),
+			string(original), string(spoofed), ev.Latency.Microseconds(), errStr,
+		)
+		if err != nil {
+			return err
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		for _, rule := range ev.Rules {
+			if _, err := tx.Exec(`INSERT INTO rule_hits (query_id, rule) VALUES (?, ?)`, id, rule); err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// TopDomains returns the most queried names per client, limit rows per client.
+// If client is not empty only that client is returned.
+func (s *Store) TopDomains(ctx context.Context, client string, limit int) ([]DomainCount, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT client, qname, n, spoofed FROM (
+			SELECT client, qname, COUNT(*) AS n,
+				SUM(action = 'spoof') AS spoofed,
+				ROW_NUMBER() OVER (PARTITION BY client ORDER BY COUNT(*) DESC, qname) AS rank
+			FROM queries
+			WHERE qname != '' AND (? = '' OR client = ?)
+			GROUP BY client, qname
+		)
+		WHERE rank <= ?
+		ORDER BY client, n DESC, qname`,
+		client, client, limit,
+	)
+	if err != nil {
+		return nil, errors.Join(ErrQuery, err)
+	}
+	defer rows.Close()
+
+	var out []DomainCount
+	for rows.Next() {
+		var d DomainCount
+		if err := rows.Scan(&d.Client, &d.Name, &d.Count, &d.Spoofed); err != nil {
+			return nil, errors.Join(ErrQuery, err)
+		}
+		out = append(out, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Join(ErrQuery, err)
+	}
+	return out, nil
+}
+
+// Clients returns the activity of every client, most active first.
+func (s *Store) Clients(ctx context.Context) ([]ClientSummary, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT client, COUNT(*), SUM(action = 'spoof'), MIN(time), MAX(time)
+		FROM queries
+		WHERE client != ''
+		GROUP BY client
+		ORDER BY COUNT(*) DESC, client`,
+	)
+	if err != nil {
+		return nil, errors.Join(ErrQuery, err)
+	}
+	defer rows.Close()
+
+	var out []ClientSummary
+	for rows.Next() {
+		var c ClientSummary
+		var first, last int64
+		if err := rows.Scan(&c.Client, &c.Queries, &c.Spoofed, &first, &last); err != nil {
+			return nil, errors.Join(ErrQuery, err)
+		}
+		c.FirstSeen, c.LastSeen = time.Unix(0, first), time.Unix(0, last)
+		out = append(out, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Join(ErrQuery, err)
+	}
+	return out, nil
+}
+
+// Rules returns which rules fired, most hits first.
+func (s *Store) Rules(ctx context.Context) ([]RuleSummary, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT r.rule, COUNT(*), COUNT(DISTINCT q.client), MIN(q.time), MAX(q.time)
+		FROM rule_hits r JOIN queries q ON q.id = r.query_id
+		GROUP BY r.rule
+		ORDER BY COUNT(*) DESC, r.rule`,
+	)
+	if err != nil {
+		return nil, errors.Join(ErrQuery, err)
+	}
+	defer rows.Close()
+
+	var out []RuleSummary
+	for rows.Next() {
+		var r RuleSummary
+		var first, last int64
+		if err := rows.Scan(&r.Rule, &r.Hits, &r.Clients, &first, &last); err != nil {
+			return nil, errors.Join(ErrQuery, err)
+		}
+		r.FirstSeen, r.LastSeen = time.Unix(0, first), time.Unix(0, last)
+		out = append(out, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Join(ErrQuery, err)
+	}
+	return out, nil
+}
+
+// Close flushes the queued events and closes the database.
+//
+// Record must not be called after Close.
+func (s *Store) Close() error {
+	s.closing.Do(func() {
+		close(s.events)
+	})
+	<-s.done
+	return s.db.Close()
+}
@@ -0,0 +1,20 @@
+package inject
+
+import "sync"
+
+const (
+	// ipv4DstOffset is the offset of the destination address in an IPv4 header.
+	ipv4DstOffset = 16
+	// ipv6DstOffset is the offset of the destination address in an IPv6 header.
+	ipv6DstOffset = 24
+)
+
+// Injector sends complete IP packets through raw sockets, routed by the kernel like locally generated packets.
+type Injector struct {
+	// fd4 and fd6 are the IPv4 and IPv6 raw sockets, -1 when not opened
+	fd4 int
+	fd6 int
+
+	mu     sync.RWMutex
+	closed bool
+}
@@ -0,0 +1,12 @@
+package inject
+
+import "errors"
+
+var (
+	ErrOpenSocket        = errors.New("failed to open raw socket")
+	ErrSend              = errors.New("failed to send packet")
+	ErrShortPacket       = errors.New("packet too short for its IP header")
+	ErrInvalidIPVersion  = errors.New("invalid IP version")
+	ErrUnsupportedFamily = errors.New("raw socket not opened for this IP version")
+	ErrClosed            = errors.New("injector closed")
+)
@@ -0,0 +1,103 @@
+package inject
+
+import (
+	"errors"
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+// Open opens the raw sockets for the enabled IP versions.
+//
+// IPPROTO_RAW sockets carry the IP header of the sent packets, the source address is kept as is.
+// Requires CAP_NET_RAW.
+func Open(ipv4, ipv6 bool) (*Injector, error) {
+	in := &Injector{fd4: -1, fd6: -1}
+	if ipv4 {
+		fd, err := unix.Socket(unix.AF_INET, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.IPPROTO_RAW)
+		if err != nil {
+			return nil, errors.Join(ErrOpenSocket, err)
+		}
+		in.fd4 = fd
+	}
+	if ipv6 {
+		fd, err := unix.Socket(unix.AF_INET6, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.IPPROTO_RAW)
+		if err != nil {
+			in.Close()
+			return nil, errors.Join(ErrOpenSocket, err)
+		}
+		in.fd6 = fd
+	}
+	return in, nil
+}
+
+// Send sends pkt, an IPv4 or IPv6 packet starting with its IP header, to its destination address.
+func (in *Injector) Send(pkt []byte) error {
+	dst, err := Destination(pkt)
+	if err != nil {
+		return err
+	}
+
+	in.mu.RLock()
+	defer in.mu.RUnlock()
+	if in.closed {
+		return ErrClosed
+	}
+
+	var fd int
+	var sa unix.Sockaddr
+	if pkt[0]>>4 == 4 {
+		fd = in.fd4
+		sa = &unix.SockaddrInet4{Addr: [4]byte(dst.To4())}
+	} else {
+		fd = in.fd6
+		sa = &unix.SockaddrInet6{Addr: [16]byte(dst)}
+	}
+	if fd < 0 {
+		return ErrUnsupportedFamily
+	}
+
+	if err := unix.Sendto(fd, pkt, 0, sa); err != nil {
+		return errors.Join(ErrSend, err)
+	}
+	return nil
+}
+
+// Destination returns the destination address of pkt, an IPv4 or IPv6 packet starting with its IP header.
+func Destination(pkt []byte) (net.IP, error) {
+	if len(pkt) == 0 {
+		return nil, ErrShortPacket
+	}
+	switch pkt[0] >> 4 {
+	case 4:
+		if len(pkt) < ipv4DstOffset+net.IPv4len {
+			return nil, ErrShortPacket
+		}
+		return net.IP(pkt[ipv4DstOffset : ipv4DstOffset+net.IPv4len]), nil
+	case 6:
+		if len(pkt) < ipv6DstOffset+net.IPv6len {
+			return nil, ErrShortPacket
+		}
+		return net.IP(pkt[ipv6DstOffset : ipv6DstOffset+net.IPv6len]), nil
+	default:
+		return nil, ErrInvalidIPVersion
+	}
+}
+
+// Close closes the raw sockets, Send fails afterwards.
+func (in *Injector) Close() error {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if in.closed {
+		return nil
+	}
+	in.closed = true
+
+	var errs []error
+	for _, fd := range []int{in.fd4, in.fd6} {
+		if fd >= 0 {
+			errs = append(errs, unix.Close(fd))
+		}
+	}
+	return errors.Join(errs...)
+}
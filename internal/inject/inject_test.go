@@ -0,0 +1,190 @@
+package inject
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Onyz107/dnsspoofer/internal/dns"
+	"github.com/Onyz107/dnsspoofer/internal/nfqueue"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"golang.org/x/sys/unix"
+)
+
+// request serializes an A query for example.com from client:port to server:53.
+func request(t *testing.T, client, server net.IP, port uint16) []byte {
+	t.Helper()
+	msg := &layers.DNS{
+		ID:        0x1234,
+		RD:        true,
+		Questions: []layers.DNSQuestion{{Name: []byte("example.com"), Type: layers.DNSTypeA, Class: layers.DNSClassIN}},
+	}
+	udp := &layers.UDP{SrcPort: layers.UDPPort(port), DstPort: 53}
+
+	var ip gopacket.SerializableLayer
+	if client.To4() != nil {
+		ip4 := &layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolUDP, SrcIP: client.To4(), DstIP: server.To4()}
+		udp.SetNetworkLayerForChecksum(ip4)
+		ip = ip4
+	} else {
+		ip6 := &layers.IPv6{Version: 6, HopLimit: 64, NextHeader: layers.IPProtocolUDP, SrcIP: client, DstIP: server}
+		udp.SetNetworkLayerForChecksum(ip6)
+		ip = ip6
+	}
+
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, ip, udp, msg); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// forge turns the request pkt into the reply the engine injects.
+func forge(t *testing.T, pkt []byte) []byte {
+	t.Helper()
+	version := uint32(pkt[0] >> 4)
+	parsed, err := dns.ParsePacket(context.Background(), nfqueue.Packet{Payload: pkt, IPVersion: version})
+	if err != nil {
+		t.Fatal(err)
+	}
+	spoofed, err := dns.SpoofRequest(parsed, layers.DNSResponseCodeNoErr, 0, net.ParseIP("10.0.0.1"), net.ParseIP("fd00::1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply, err := spoofed.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reply
+}
+
+func TestForgedReplyAddressing(t *testing.T) {
+	tests := []struct {
+		name   string
+		client string
+		server string
+	}{
+		// Local scope: the client is this machine, the request leaves through OUTPUT.
+		{"local ipv4", "192.168.1.10", "1.1.1.1"},
+		{"local ipv4 stub", "127.0.0.1", "127.0.0.53"},
+		{"local ipv6", "fd00::10", "2606:4700:4700::1111"},
+		// Remote scope: the client is another host, the request crosses FORWARD.
+		{"remote ipv4", "192.168.1.50", "8.8.8.8"},
+		{"remote ipv6", "fd00::50", "2001:4860:4860::8888"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := net.ParseIP(tt.client), net.ParseIP(tt.server)
+			reply := forge(t, request(t, client, server, 40000))
+
+			dst, err := Destination(reply)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !dst.Equal(client) {
+				t.Errorf("Destination() = %s, want the client %s", dst, client)
+			}
+
+			first := layers.LayerTypeIPv4
+			if client.To4() == nil {
+				first = layers.LayerTypeIPv6
+			}
+			packet := gopacket.NewPacket(reply, first, gopacket.Default)
+			var src net.IP
+			if ip4, ok := packet.NetworkLayer().(*layers.IPv4); ok {
+				src = ip4.SrcIP
+			} else if ip6, ok := packet.NetworkLayer().(*layers.IPv6); ok {
+				src = ip6.SrcIP
+			}
+			if !src.Equal(server) {
+				t.Errorf("source = %s, want the server %s", src, server)
+			}
+			udp, ok := packet.TransportLayer().(*layers.UDP)
+			if !ok {
+				t.Fatal("reply has no UDP layer")
+			}
+			if udp.SrcPort != 53 || udp.DstPort != 40000 {
+				t.Errorf("ports = %d -> %d, want 53 -> 40000", udp.SrcPort, udp.DstPort)
+			}
+		})
+	}
+}
+
+func TestDestinationErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		pkt  []byte
+		want error
+	}{
+		{"empty", nil, ErrShortPacket},
+		{"short ipv4", []byte{0x45, 0, 0, 20}, ErrShortPacket},
+		{"short ipv6", append([]byte{0x60}, make([]byte, 29)...), ErrShortPacket},
+		{"unknown version", []byte{0x50}, ErrInvalidIPVersion},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Destination(tt.pkt); !errors.Is(err, tt.want) {
+				t.Errorf("Destination() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// TestSendLoopback injects a reply to a local UDP client, it needs CAP_NET_RAW.
+func TestSendLoopback(t *testing.T) {
+	in, err := Open(true, false)
+	if errors.Is(err, unix.EPERM) || errors.Is(err, unix.EACCES) {
+		t.Skip("raw sockets need CAP_NET_RAW")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	port := uint16(conn.LocalAddr().(*net.UDPAddr).Port)
+
+	server := net.IPv4(127, 0, 0, 53)
+	if err := in.Send(forge(t, request(t, net.IPv4(127, 0, 0, 1), server, port))); err != nil {
+		t.Fatal(err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, 1500)
+	n, from, err := conn.ReadFromUDP(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !from.IP.Equal(server) || from.Port != 53 {
+		t.Errorf("reply from %s, want %s:53", from, server)
+	}
+	var msg layers.DNS
+	if err := msg.DecodeFromBytes(buf[:n], gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	if msg.ID != 0x1234 || !msg.QR || len(msg.Answers) != 1 || !msg.Answers[0].IP.Equal(net.ParseIP("10.0.0.1")) {
+		t.Errorf("reply = id %#x qr %v answers %v", msg.ID, msg.QR, msg.Answers)
+	}
+}
+
+func TestSendClosed(t *testing.T) {
+	in := &Injector{fd4: -1, fd6: -1}
+	in.Close()
+	if err := in.Send(make([]byte, 40)); !errors.Is(err, ErrInvalidIPVersion) {
+		t.Errorf("Send() on a zero packet error = %v, want %v", err, ErrInvalidIPVersion)
+	}
+	pkt := []byte{0x45, 0, 0, 20, 0, 0, 0, 0, 64, 17, 0, 0, 127, 0, 0, 1, 127, 0, 0, 1}
+	if err := in.Send(pkt); !errors.Is(err, ErrClosed) {
+		t.Errorf("Send() after Close error = %v, want %v", err, ErrClosed)
+	}
+}
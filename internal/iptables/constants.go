@@ -0,0 +1,66 @@
+package iptables
+
+import "sync"
+
+const (
+	// chainPrefix is the name prefix of every chain created by this package.
+	chainPrefix = "dnsspoof_"
+	// dnsPort is the UDP port of the queued packets.
+	dnsPort = "53"
+)
+
+var (
+	// binaries are the commands of the address families cleanup looks into.
+	binaries = []string{"iptables", "ip6tables"}
+	// tables are the tables the rules are installed in, depending on the hook and spoof mode.
+	tables = []string{"filter", "mangle", "nat"}
+)
+
+// Command is the argument list of one iptables or ip6tables invocation, starting with the binary name.
+type Command []string
+
+// Plan is the commands behind the rules of one engine.
+type Plan struct {
+	// Add installs the rules
+	Add []Command
+	// Del removes the rules, Del[len(Del)-i:] undoes Add[:i]
+	Del []Command
+	// List prints the counters of the NFQUEUE rules, once per chain and address family
+	List []Command
+}
+
+// match selects the DNS packets queued by one rule and the built-in chain jumping to it.
+type match struct {
+	suffix    string
+	ifaceFlag string
+	portFlag  string
+	hook      string
+	protos    []string
+	extra     []string
+}
+
+// installed implements nftables.Installed for a Plan that was run.
+type installed struct {
+	plan *Plan
+	mu   sync.Mutex
+	// pending is the commands of plan.Del that did not succeed yet
+	pending []Command
+}
+
+// ChainInfo describes a dnsspoofer chain found in the kernel.
+type ChainInfo struct {
+	// Binary is iptables or ip6tables
+	Binary string
+	Table  string
+	Name   string
+	// PID is the process that created the chain, 0 if its rules carry no owner tag
+	PID int
+	// Stale reports that the owner is gone, or unknown, and the chain only queues to a dead queue
+	Stale bool
+}
+
+// owned is a dnsspoofer chain with the commands deleting it and the jumps to it.
+type owned struct {
+	info ChainInfo
+	del  []Command
+}
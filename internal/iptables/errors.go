@@ -0,0 +1,17 @@
+package iptables
+
+import "errors"
+
+var (
+	ErrMissingIface      = errors.New("no interface to match DNS packets on")
+	ErrInvalidIPMode     = errors.New("invalid IP mode")
+	ErrInvalidScope      = errors.New("invalid scope")
+	ErrInvalidSpoofMode  = errors.New("invalid spoof mode")
+	ErrInvalidHook       = errors.New("invalid hook")
+	ErrInvalidProxyPort  = errors.New("invalid proxy port")
+	ErrInvalidQueueRange = errors.New("invalid NFQUEUE range")
+	ErrUnsupported       = errors.New("chain priority and existing nftables tables are not supported by the iptables backend")
+	ErrRun               = errors.New("failed to run iptables")
+	ErrRollback          = errors.New("failed to roll back iptables rules")
+	ErrParseCounter      = errors.New("failed to parse iptables rule counters")
+)
@@ -0,0 +1,449 @@
+// Package iptables installs the DNS queueing rules with iptables and ip6tables, for kernels without nftables.
+package iptables
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Onyz107/dnsspoofer/internal/logger"
+	"github.com/Onyz107/dnsspoofer/internal/nftables"
+	"github.com/google/uuid"
+)
+
+// AddDNSQueue is the iptables counterpart of nftables.AddDNSQueue: it creates a chain per address family
+// holding the NFQUEUE rule, and jumps to it from the top of the hook's built-in chain.
+//
+// iptables has no transactions, so if a command fails the ones already run are undone on a best effort basis.
+// Installed.Remove can be retried until it succeeds.
+//
+// Returns an error if an invalid parameter is provided, or if an iptables command fails.
+func AddDNSQueue(ctx context.Context, opts *nftables.Options) (nftables.Installed, error) {
+	log := logger.LoggerFrom(ctx)
+
+	plan, err := Commands(opts, uuid.New().String()[:8])
+	if err != nil {
+		return nil, err
+	}
+
+	if owned, err := listOwned(ctx); err == nil {
+		for _, o := range owned {
+			if o.info.Stale {
+				log.Error("found a stale dnsspoofer iptables chain still queueing DNS packets, remove it with the cleanup command",
+					"binary", o.info.Binary, "table", o.info.Table, "chain", o.info.Name, "pid", o.info.PID)
+			}
+		}
+	}
+
+	for i, cmd := range plan.Add {
+		if err := run(ctx, cmd); err != nil {
+			// Undo in reverse order, skipping what the failed command and those after it would have undone.
+			if _, rbErr := runAll(ctx, plan.Del[len(plan.Del)-i:]); rbErr != nil {
+				log.Error(ErrRollback.Error(), "err", rbErr)
+			}
+			return nil, err
+		}
+	}
+	log.Debug("installed iptables rules", "commands", len(plan.Add))
+
+	return &installed{plan: plan, pending: plan.Del}, nil
+}
+
+// Remove implements nftables.Installed.
+//
+// Commands that succeeded are not run again on retry, iptables would refuse to delete what is already gone.
+func (in *installed) Remove() error {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	failed, err := runAll(context.Background(), in.pending)
+	in.pending = failed
+	return err
+}
+
+// Counter implements nftables.Installed, summing the NFQUEUE rule counters of every address family.
+func (in *installed) Counter() (nftables.Counter, error) {
+	var total nftables.Counter
+	for _, cmd := range in.plan.List {
+		out, err := exec.Command(cmd[0], cmd[1:]...).Output()
+		if err != nil {
+			return nftables.Counter{}, errors.Join(ErrRun, fmt.Errorf("%s: %w", cmd, err))
+		}
+		c, err := parseCounter(out)
+		if err != nil {
+			return nftables.Counter{}, err
+		}
+		total.Packets += c.Packets
+		total.Bytes += c.Bytes
+	}
+	return total, nil
+}
+
+// String implements nftables.Installed.
+func (in *installed) String() string {
+	return in.plan.String()
+}
+
+// parseCounter reads the counters of the single rule listed by "iptables -L chain -v -x -n":
+// a chain header, a column header, then the rule starting with its packets and bytes.
+func parseCounter(out []byte) (nftables.Counter, error) {
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if len(lines) < 3 {
+		return nftables.Counter{}, ErrParseCounter
+	}
+
+	// The chain header and the column names come before one line per rule.
+	var total nftables.Counter
+	for _, line := range lines[2:] {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return nftables.Counter{}, ErrParseCounter
+		}
+		packets, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			return nftables.Counter{}, errors.Join(ErrParseCounter, err)
+		}
+		bytes, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return nftables.Counter{}, errors.Join(ErrParseCounter, err)
+		}
+		total.Packets += packets
+		total.Bytes += bytes
+	}
+	return total, nil
+}
+
+// Render returns the iptables command lines AddDNSQueue would run for opts, without running them.
+//
+// The chain name and the rule comment end with a random ID that differs on every call.
+//
+// Returns an error if an invalid parameter is provided.
+func Render(ctx context.Context, opts *nftables.Options) (string, error) {
+	plan, err := Commands(opts, uuid.New().String()[:8])
+	if err != nil {
+		return "", err
+	}
+
+	return plan.String(), nil
+}
+
+// Commands returns the commands installing, removing and listing the rules for opts, id naming the created chains.
+//
+// Returns an error if an invalid parameter is provided.
+func Commands(opts *nftables.Options, id string) (*Plan, error) {
+	if opts.Iface == nil {
+		return nil, ErrMissingIface
+	}
+	if opts.Priority != 0 || opts.Table != "" || opts.Chain != "" {
+		return nil, ErrUnsupported
+	}
+
+	var binaries []string
+	switch opts.IPMode {
+	case nftables.IPv4Only:
+		binaries = []string{"iptables"}
+	case nftables.IPv6Only:
+		binaries = []string{"ip6tables"}
+	case nftables.IPv4AndIPv6:
+		binaries = []string{"iptables", "ip6tables"}
+	default:
+		return nil, ErrInvalidIPMode
+	}
+
+	if opts.SpoofMode == nftables.Proxy {
+		return proxyCommands(opts, binaries, id)
+	}
+
+	request := match{suffix: "_req", ifaceFlag: "-o", portFlag: "--dport", hook: "OUTPUT", protos: []string{"udp"}}
+	response := match{suffix: "_res", ifaceFlag: "-i", portFlag: "--sport", hook: "INPUT", protos: []string{"udp"}}
+	var matches []match
+	switch opts.SpoofMode {
+	case nftables.Aggressive:
+		request.suffix = ""
+		matches = []match{request}
+	case nftables.Passive:
+		response.suffix = ""
+		matches = []match{response}
+	case nftables.Hybrid:
+		matches = []match{request, response}
+	default:
+		return nil, ErrInvalidSpoofMode
+	}
+
+	switch opts.Scope {
+	case nftables.Local:
+	case nftables.Remote:
+		for i := range matches {
+			matches[i].hook = "FORWARD"
+		}
+	default:
+		return nil, ErrInvalidScope
+	}
+
+	// The filter table has no PREROUTING and POSTROUTING chains, mangle has them all.
+	table := "filter"
+	switch opts.Hook {
+	case nftables.HookAuto:
+	case nftables.HookPrerouting:
+		table = "mangle"
+		for i := range matches {
+			matches[i].ifaceFlag, matches[i].hook = "-i", "PREROUTING"
+		}
+	case nftables.HookPostrouting:
+		table = "mangle"
+		for i := range matches {
+			matches[i].ifaceFlag, matches[i].hook = "-o", "POSTROUTING"
+		}
+	default:
+		return nil, ErrInvalidHook
+	}
+
+	queueTotal := opts.QueueTotal
+	if queueTotal == 0 {
+		queueTotal = 1
+	}
+	if uint32(opts.Queue)+uint32(queueTotal)-1 > math.MaxUint16 {
+		return nil, ErrInvalidQueueRange
+	}
+	target := []string{"-j", "NFQUEUE"}
+	if queueTotal > 1 {
+		target = append(target, "--queue-balance", fmt.Sprintf("%d:%d", opts.Queue, opts.Queue+queueTotal-1), "--queue-cpu-fanout")
+	} else {
+		target = append(target, "--queue-num", strconv.Itoa(int(opts.Queue)))
+	}
+	if !opts.FailClosed {
+		target = append(target, "--queue-bypass")
+	}
+
+	return newPlan(binaries, table, matches, opts.Iface.Name, id, target), nil
+}
+
+// proxyCommands returns the commands redirecting UDP and TCP DNS requests to the local proxy port,
+// except the upstream queries of the proxy itself, marked with nftables.ProxyMark.
+func proxyCommands(opts *nftables.Options, binaries []string, id string) (*Plan, error) {
+	if opts.ProxyPort == 0 {
+		return nil, ErrInvalidProxyPort
+	}
+
+	m := match{
+		ifaceFlag: "-o",
+		portFlag:  "--dport",
+		hook:      "OUTPUT",
+		protos:    []string{"udp", "tcp"},
+		extra:     []string{"-m", "mark", "!", "--mark", fmt.Sprintf("%#x", nftables.ProxyMark)},
+	}
+	switch opts.Scope {
+	case nftables.Local:
+	case nftables.Remote:
+		m.ifaceFlag, m.hook = "-i", "PREROUTING"
+	default:
+		return nil, ErrInvalidScope
+	}
+	switch opts.Hook {
+	case nftables.HookAuto:
+	case nftables.HookPrerouting:
+		m.ifaceFlag, m.hook = "-i", "PREROUTING"
+	default:
+		return nil, ErrInvalidHook
+	}
+
+	target := []string{"-j", "REDIRECT", "--to-ports", strconv.Itoa(int(opts.ProxyPort))}
+	return newPlan(binaries, "nat", []match{m}, opts.Iface.Name, id, target), nil
+}
+
+// newPlan returns the commands adding one chain per match to table, jumped to from the match hook,
+// with a rule sending the packets of each protocol to target.
+func newPlan(binaries []string, table string, matches []match, iface, id string, target []string) *Plan {
+	comment := []string{"-m", "comment", "--comment", nftables.OwnerComment(id)}
+	plan := new(Plan)
+	for _, bin := range binaries {
+		// -w waits for the xtables lock instead of failing while another program holds it.
+		ipt := func(args ...string) Command {
+			return append(Command{bin, "-w", "-t", table}, args...)
+		}
+
+		// Each match gets its own chain, iptables refuses -o in a chain reachable from INPUT and -i from OUTPUT.
+		for _, m := range matches {
+			chain := chainPrefix + id + m.suffix
+			jump := slices.Concat([]string{m.hook}, comment, []string{"-j", chain})
+
+			plan.Add = append(plan.Add, ipt("-N", chain))
+			for _, proto := range m.protos {
+				plan.Add = append(plan.Add, ipt(slices.Concat(
+					[]string{"-A", chain, m.ifaceFlag, iface, "-p", proto, m.portFlag, dnsPort}, m.extra, comment, target)...))
+			}
+			plan.Add = append(plan.Add, ipt(append([]string{"-I"}, jump...)...))
+
+			// Flushing the chain undoes any number of its rules.
+			del := []Command{ipt(append([]string{"-D"}, jump...)...)}
+			for range m.protos {
+				del = append(del, ipt("-F", chain))
+			}
+			plan.Del = slices.Insert(plan.Del, 0, append(del, ipt("-X", chain))...)
+			plan.List = append(plan.List, ipt("-L", chain, "-v", "-x", "-n"))
+		}
+	}
+	return plan
+}
+
+// CleanupChains deletes the stale dnsspoofer chains and the jumps to them, or every one of them if all is set,
+// and returns the chains that were deleted.
+//
+// A binary that is not installed is skipped. Chains whose deletion fails are left out of the result and
+// their errors are joined.
+func CleanupChains(ctx context.Context, all bool) ([]ChainInfo, error) {
+	log := logger.LoggerFrom(ctx)
+
+	chains, err := listOwned(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var deleted []ChainInfo
+	var errs []error
+	for _, o := range chains {
+		if !all && !o.info.Stale {
+			continue
+		}
+		log.Debug("deleting iptables chain", "binary", o.info.Binary, "table", o.info.Table, "chain", o.info.Name,
+			"pid", o.info.PID, "stale", o.info.Stale)
+		if _, err := runAll(ctx, o.del); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		deleted = append(deleted, o.info)
+	}
+	return deleted, errors.Join(errs...)
+}
+
+// listOwned returns the dnsspoofer chains of every binary and table, read from "iptables -S".
+//
+// Chains without an owner tag predate it or were not created by dnsspoofer, so no process claims them.
+func listOwned(ctx context.Context) ([]owned, error) {
+	var chains []owned
+	for _, bin := range binaries {
+		for _, table := range tables {
+			ipt := func(args ...string) Command {
+				return append(Command{bin, "-w", "-t", table}, args...)
+			}
+
+			list := ipt("-S")
+			out, err := runCommand(ctx, list)
+			if errors.Is(err, exec.ErrNotFound) {
+				break
+			}
+			if err != nil {
+				return nil, errors.Join(ErrRun, fmt.Errorf("%s: %w: %s", list, err, strings.TrimSpace(string(out))))
+			}
+
+			var rules [][]string
+			byName := make(map[string]*owned)
+			var names []string
+			for line := range strings.Lines(string(out)) {
+				args := splitRule(line)
+				if len(args) < 2 {
+					continue
+				}
+				switch {
+				case args[0] == "-N" && strings.HasPrefix(args[1], chainPrefix):
+					byName[args[1]] = &owned{info: ChainInfo{Binary: bin, Table: table, Name: args[1], Stale: true}}
+					names = append(names, args[1])
+				case args[0] == "-A":
+					rules = append(rules, args[1:])
+				}
+			}
+
+			for _, rule := range rules {
+				chain, target := rule[0], argAfter(rule, "-j")
+				o, ok := byName[chain]
+				if !ok {
+					if o, ok = byName[target]; !ok {
+						continue
+					}
+					o.del = append(o.del, ipt(append([]string{"-D"}, rule...)...))
+				}
+				if pid, stale, ok := nftables.Owner(argAfter(rule, "--comment")); ok {
+					o.info.PID, o.info.Stale = pid, stale
+				}
+			}
+			for _, name := range names {
+				o := byName[name]
+				o.del = append(o.del, ipt("-F", name), ipt("-X", name))
+				chains = append(chains, *o)
+			}
+		}
+	}
+	return chains, nil
+}
+
+// splitRule splits a line of "iptables -S" into arguments, unquoting the double-quoted ones.
+func splitRule(line string) []string {
+	var args []string
+	var arg strings.Builder
+	inArg, quoted, escaped := false, false, false
+	for _, r := range strings.TrimSpace(line) {
+		switch {
+		case escaped:
+			arg.WriteRune(r)
+			escaped = false
+		case quoted && r == '\\':
+			escaped = true
+		case r == '"':
+			quoted = !quoted
+			inArg = true
+		case !quoted && r == ' ':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args
+}
+
+// argAfter returns the argument following flag in args, "" if there is none.
+func argAfter(args []string, flag string) string {
+	i := slices.Index(args, flag)
+	if i < 0 || i+1 >= len(args) {
+		return ""
+	}
+	return args[i+1]
+}
+
+// runCommand runs cmd and returns its combined output.
+var runCommand = func(ctx context.Context, cmd Command) ([]byte, error) {
+	return exec.CommandContext(ctx, cmd[0], cmd[1:]...).CombinedOutput()
+}
+
+// run runs cmd, the error includes its output.
+func run(ctx context.Context, cmd Command) error {
+	out, err := runCommand(ctx, cmd)
+	if err != nil {
+		return errors.Join(ErrRun, fmt.Errorf("%s: %w: %s", cmd, err, strings.TrimSpace(string(out))))
+	}
+	return nil
+}
+
+// runAll runs every command of cmds even if some fail, and returns the failed ones with their joined errors.
+func runAll(ctx context.Context, cmds []Command) ([]Command, error) {
+	var failed []Command
+	var errs []error
+	for _, cmd := range cmds {
+		if err := run(ctx, cmd); err != nil {
+			failed = append(failed, cmd)
+			errs = append(errs, err)
+		}
+	}
+	return failed, errors.Join(errs...)
+}
@@ -0,0 +1,448 @@
+package iptables
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Onyz107/dnsspoofer/internal/nftables"
+)
+
+var testIface = &net.Interface{Index: 7, Name: "eth0"}
+
+// fakeTables models the chains of every iptables binary and table, enough to check that a Plan
+// only runs commands iptables accepts and that Del undoes Add.
+type fakeTables map[string]*fakeTable
+
+type fakeTable struct {
+	chains  map[string][][]string
+	builtin map[string][][]string
+}
+
+// run applies cmd, failing where iptables would.
+func (f fakeTables) run(cmd Command) error {
+	if len(cmd) < 6 || cmd[1] != "-w" || cmd[2] != "-t" {
+		return fmt.Errorf("malformed command %s", cmd)
+	}
+	key := cmd[0] + " " + cmd[3]
+	t, ok := f[key]
+	if !ok {
+		t = &fakeTable{chains: make(map[string][][]string), builtin: make(map[string][][]string)}
+		f[key] = t
+	}
+
+	op, chain, rule := cmd[4], cmd[5], []string(cmd[6:])
+	_, exists := t.chains[chain]
+	switch op {
+	case "-N":
+		if exists {
+			return fmt.Errorf("%s: chain already exists", cmd)
+		}
+		t.chains[chain] = [][]string{}
+	case "-A":
+		if !exists {
+			return fmt.Errorf("%s: no chain", cmd)
+		}
+		t.chains[chain] = append(t.chains[chain], rule)
+	case "-I":
+		if target := rule[len(rule)-1]; t.chains[target] == nil {
+			return fmt.Errorf("%s: no target chain", cmd)
+		}
+		t.builtin[chain] = slices.Insert(t.builtin[chain], 0, rule)
+	case "-D":
+		i := slices.IndexFunc(t.builtin[chain], func(r []string) bool { return slices.Equal(r, rule) })
+		if i < 0 {
+			return fmt.Errorf("%s: no such rule", cmd)
+		}
+		t.builtin[chain] = slices.Delete(t.builtin[chain], i, i+1)
+	case "-F":
+		if !exists {
+			return fmt.Errorf("%s: no chain", cmd)
+		}
+		t.chains[chain] = [][]string{}
+	case "-X":
+		if !exists || len(t.chains[chain]) > 0 {
+			return fmt.Errorf("%s: missing or not empty chain", cmd)
+		}
+		for _, rules := range t.builtin {
+			for _, r := range rules {
+				if r[len(r)-1] == chain {
+					return fmt.Errorf("%s: chain still referenced", cmd)
+				}
+			}
+		}
+		delete(t.chains, chain)
+	case "-L":
+		if !exists {
+			return fmt.Errorf("%s: no chain", cmd)
+		}
+	default:
+		return fmt.Errorf("%s: unknown operation", cmd)
+	}
+	return nil
+}
+
+// list prints the chains and rules of table like "iptables -S" does.
+func (f fakeTables) list(bin, table string) string {
+	t, ok := f[bin+" "+table]
+	if !ok {
+		return ""
+	}
+	quote := func(rule []string) string {
+		args := make([]string, len(rule))
+		for i, arg := range rule {
+			if strings.Contains(arg, " ") {
+				arg = `"` + arg + `"`
+			}
+			args[i] = arg
+		}
+		return strings.Join(args, " ")
+	}
+
+	var b strings.Builder
+	for _, name := range slices.Sorted(maps.Keys(t.chains)) {
+		fmt.Fprintf(&b, "-N %s\n", name)
+	}
+	for _, chains := range []map[string][][]string{t.builtin, t.chains} {
+		for _, name := range slices.Sorted(maps.Keys(chains)) {
+			for _, rule := range chains[name] {
+				fmt.Fprintf(&b, "-A %s %s\n", name, quote(rule))
+			}
+		}
+	}
+	return b.String()
+}
+
+// empty reports whether nothing is left in any table.
+func (f fakeTables) empty() bool {
+	for _, t := range f {
+		if len(t.chains) > 0 {
+			return false
+		}
+		for _, rules := range t.builtin {
+			if len(rules) > 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func TestCommands(t *testing.T) {
+	ipModes := []struct {
+		mode     nftables.IPMode
+		binaries []string
+	}{
+		{nftables.IPv4Only, []string{"iptables"}},
+		{nftables.IPv6Only, []string{"ip6tables"}},
+		{nftables.IPv4AndIPv6, []string{"iptables", "ip6tables"}},
+	}
+	tests := []struct {
+		name  string
+		mode  nftables.SpoofMode
+		scope nftables.Scope
+		hook  nftables.Hook
+		table string
+		// jumps are the built-in chains jumping to the created chains, protos the rules per chain
+		jumps  []string
+		protos int
+	}{
+		{"aggressive local", nftables.Aggressive, nftables.Local, nftables.HookAuto, "filter", []string{"OUTPUT"}, 1},
+		{"aggressive remote", nftables.Aggressive, nftables.Remote, nftables.HookAuto, "filter", []string{"FORWARD"}, 1},
+		{"passive local", nftables.Passive, nftables.Local, nftables.HookAuto, "filter", []string{"INPUT"}, 1},
+		{"passive postrouting", nftables.Passive, nftables.Remote, nftables.HookPostrouting, "mangle", []string{"POSTROUTING"}, 1},
+		{"hybrid local", nftables.Hybrid, nftables.Local, nftables.HookAuto, "filter", []string{"OUTPUT", "INPUT"}, 1},
+		{"hybrid remote", nftables.Hybrid, nftables.Remote, nftables.HookAuto, "filter", []string{"FORWARD", "FORWARD"}, 1},
+		{"hybrid prerouting", nftables.Hybrid, nftables.Local, nftables.HookPrerouting, "mangle", []string{"PREROUTING", "PREROUTING"}, 1},
+		{"proxy local", nftables.Proxy, nftables.Local, nftables.HookAuto, "nat", []string{"OUTPUT"}, 2},
+		{"proxy remote", nftables.Proxy, nftables.Remote, nftables.HookAuto, "nat", []string{"PREROUTING"}, 2},
+	}
+
+	for _, tt := range tests {
+		for _, ip := range ipModes {
+			t.Run(tt.name+"/"+ip.mode.String(), func(t *testing.T) {
+				opts := &nftables.Options{IPMode: ip.mode, Iface: testIface, SpoofMode: tt.mode, Scope: tt.scope, Hook: tt.hook, ProxyPort: 10053}
+				plan, err := Commands(opts, "abcd1234")
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				// -N, one -A per protocol and -I per chain, and as many commands to undo them.
+				perChain := 2 + tt.protos
+				want := len(ip.binaries) * len(tt.jumps) * perChain
+				if len(plan.Add) != want || len(plan.Del) != want {
+					t.Fatalf("commands = %d to add and %d to delete, want %d", len(plan.Add), len(plan.Del), want)
+				}
+				if len(plan.List) != len(ip.binaries)*len(tt.jumps) {
+					t.Errorf("list commands = %d, want one per chain", len(plan.List))
+				}
+				var jumps []string
+				for _, cmd := range plan.Add {
+					if !slices.Contains(ip.binaries, cmd[0]) || cmd[3] != tt.table {
+						t.Errorf("command %s, want %v in table %s", cmd, ip.binaries, tt.table)
+					}
+					if cmd[4] == "-I" && cmd[0] == ip.binaries[0] {
+						jumps = append(jumps, cmd[5])
+					}
+				}
+				if !slices.Equal(jumps, tt.jumps) {
+					t.Errorf("jumps from %v, want %v", jumps, tt.jumps)
+				}
+
+				// Every failure point rolls back with the tail of Del, as AddDNSQueue does.
+				for i := range len(plan.Add) + 1 {
+					state := make(fakeTables)
+					for _, cmd := range plan.Add[:i] {
+						if err := state.run(cmd); err != nil {
+							t.Fatal(err)
+						}
+					}
+					if i == len(plan.Add) {
+						for _, cmd := range plan.List {
+							if err := state.run(cmd); err != nil {
+								t.Error(err)
+							}
+						}
+					}
+					for _, cmd := range plan.Del[len(plan.Del)-i:] {
+						if err := state.run(cmd); err != nil {
+							t.Errorf("undoing %d commands: %v", i, err)
+						}
+					}
+					if !state.empty() {
+						t.Errorf("undoing %d commands left rules behind", i)
+					}
+				}
+			})
+		}
+	}
+}
+
+// use makes the package run its commands against f for the rest of the test, failing once the commands
+// matched by failOnce, and returns the number of commands run.
+func (f fakeTables) use(t *testing.T, failOnce func(Command) bool) *int {
+	t.Helper()
+	prev := runCommand
+	t.Cleanup(func() { runCommand = prev })
+
+	var runs int
+	failed := make(map[string]bool)
+	runCommand = func(_ context.Context, cmd Command) ([]byte, error) {
+		runs++
+		if cmd[len(cmd)-1] == "-S" {
+			return []byte(f.list(cmd[0], cmd[3])), nil
+		}
+		if key := cmd.String(); failOnce(cmd) && !failed[key] {
+			failed[key] = true
+			return []byte("iptables: Resource temporarily unavailable."), errors.New("exit status 4")
+		}
+		if err := f.run(cmd); err != nil {
+			return []byte(err.Error()), errors.New("exit status 1")
+		}
+		return nil, nil
+	}
+	return &runs
+}
+
+func TestRemoveRetry(t *testing.T) {
+	state := make(fakeTables)
+	runs := state.use(t, func(cmd Command) bool { return cmd[0] == "ip6tables" && cmd[4] == "-D" })
+
+	in, err := AddDNSQueue(context.Background(), &nftables.Options{
+		IPMode: nftables.IPv4AndIPv6, Iface: testIface, SpoofMode: nftables.Hybrid, Scope: nftables.Local,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The ip6tables jumps stay, so their chains cannot be deleted either.
+	if err := in.Remove(); !errors.Is(err, ErrRun) {
+		t.Fatalf("Remove() error = %v, want %v", err, ErrRun)
+	}
+	if state.empty() {
+		t.Fatal("failed Remove() left nothing behind")
+	}
+	// Only what is left is run again.
+	*runs = 0
+	if err := in.Remove(); err != nil {
+		t.Fatalf("retried Remove() error = %v", err)
+	}
+	if !state.empty() {
+		t.Error("retried Remove() left rules behind")
+	}
+	if *runs != 4 {
+		t.Errorf("retried Remove() ran %d commands, want the 2 jumps and 2 chains of ip6tables", *runs)
+	}
+
+	*runs = 0
+	if err := in.Remove(); err != nil || *runs != 0 {
+		t.Errorf("Remove() once removed = %v after %d commands, want nothing run", err, *runs)
+	}
+}
+
+func TestCleanupChains(t *testing.T) {
+	state := make(fakeTables)
+	state.use(t, func(Command) bool { return false })
+
+	// A running instance, this test.
+	if _, err := AddDNSQueue(context.Background(), &nftables.Options{
+		IPMode: nftables.IPv4AndIPv6, Iface: testIface, SpoofMode: nftables.Passive, Scope: nftables.Local,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// A killed instance, and a chain without an owner tag.
+	plan, err := Commands(&nftables.Options{
+		IPMode: nftables.IPv4Only, Iface: testIface, SpoofMode: nftables.Hybrid, Scope: nftables.Remote,
+	}, "deadbeef")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, cmd := range plan.Add {
+		for i, arg := range cmd {
+			if strings.HasPrefix(arg, "dnsspoofer pid=") {
+				cmd[i] = "dnsspoofer pid=999999999 start=1 id=deadbeef"
+			}
+		}
+		if err := state.run(cmd); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := state.run(Command{"iptables", "-w", "-t", "mangle", "-N", "dnsspoof_manual"}); err != nil {
+		t.Fatal(err)
+	}
+
+	deleted, err := CleanupChains(context.Background(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ChainInfo{
+		{Binary: "iptables", Table: "filter", Name: "dnsspoof_deadbeef_req", PID: 999999999, Stale: true},
+		{Binary: "iptables", Table: "filter", Name: "dnsspoof_deadbeef_res", PID: 999999999, Stale: true},
+		{Binary: "iptables", Table: "mangle", Name: "dnsspoof_manual", Stale: true},
+	}
+	if !slices.Equal(deleted, want) {
+		t.Errorf("stale chains deleted = %+v, want %+v", deleted, want)
+	}
+	if state.empty() {
+		t.Fatal("the chains of the running instance were deleted")
+	}
+
+	deleted, err = CleanupChains(context.Background(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != 2 || deleted[0].PID != os.Getpid() || deleted[0].Stale {
+		t.Errorf("chains deleted with all = %+v, want the iptables and ip6tables chains of this process", deleted)
+	}
+	if !state.empty() {
+		t.Error("CleanupChains() with all left rules behind")
+	}
+}
+
+func TestSplitRule(t *testing.T) {
+	got := splitRule(`-A OUTPUT -m comment --comment "dnsspoofer pid=1 start=2 id=\"x\\" -j dnsspoof_x` + "\n")
+	want := []string{"-A", "OUTPUT", "-m", "comment", "--comment", `dnsspoofer pid=1 start=2 id="x\`, "-j", "dnsspoof_x"}
+	if !slices.Equal(got, want) {
+		t.Errorf("splitRule() = %q, want %q", got, want)
+	}
+}
+
+func TestRender(t *testing.T) {
+	owner := regexp.MustCompile(`dnsspoofer pid=\d+ start=\d+ id=([0-9a-f]{8})`)
+	id := regexp.MustCompile(`dnsspoof_[0-9a-f]{8}`)
+
+	tests := []struct {
+		name string
+		opts nftables.Options
+		want string
+	}{
+		{
+			"hybrid remote fanout",
+			nftables.Options{IPMode: nftables.IPv4Only, SpoofMode: nftables.Hybrid, Scope: nftables.Remote, Queue: 2, QueueTotal: 2},
+			`iptables -w -t filter -N dnsspoof_ID_req
+iptables -w -t filter -A dnsspoof_ID_req -o eth0 -p udp --dport 53 -m comment --comment 'OWNER' -j NFQUEUE --queue-balance 2:3 --queue-cpu-fanout --queue-bypass
+iptables -w -t filter -I FORWARD -m comment --comment 'OWNER' -j dnsspoof_ID_req
+iptables -w -t filter -N dnsspoof_ID_res
+iptables -w -t filter -A dnsspoof_ID_res -i eth0 -p udp --sport 53 -m comment --comment 'OWNER' -j NFQUEUE --queue-balance 2:3 --queue-cpu-fanout --queue-bypass
+iptables -w -t filter -I FORWARD -m comment --comment 'OWNER' -j dnsspoof_ID_res
+`,
+		},
+		{
+			"proxy remote",
+			nftables.Options{IPMode: nftables.IPv6Only, SpoofMode: nftables.Proxy, Scope: nftables.Remote, ProxyPort: 10053},
+			`ip6tables -w -t nat -N dnsspoof_ID
+ip6tables -w -t nat -A dnsspoof_ID -i eth0 -p udp --dport 53 -m mark '!' --mark 0x646e73 -m comment --comment 'OWNER' -j REDIRECT --to-ports 10053
+ip6tables -w -t nat -A dnsspoof_ID -i eth0 -p tcp --dport 53 -m mark '!' --mark 0x646e73 -m comment --comment 'OWNER' -j REDIRECT --to-ports 10053
+ip6tables -w -t nat -I PREROUTING -m comment --comment 'OWNER' -j dnsspoof_ID
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Iface = testIface
+			got, err := Render(context.Background(), &tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = id.ReplaceAllString(owner.ReplaceAllString(got, "OWNER"), "dnsspoof_ID")
+			if got != tt.want {
+				t.Errorf("Render() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommandsErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		opts nftables.Options
+		err  error
+	}{
+		{"missing interface", nftables.Options{}, ErrMissingIface},
+		{"priority", nftables.Options{Iface: testIface, Priority: -10}, ErrUnsupported},
+		{"existing table", nftables.Options{Iface: testIface, Table: "inet filter", Chain: "forward"}, ErrUnsupported},
+		{"ip mode", nftables.Options{Iface: testIface, IPMode: 9}, ErrInvalidIPMode},
+		{"spoof mode", nftables.Options{Iface: testIface, SpoofMode: 9}, ErrInvalidSpoofMode},
+		{"scope", nftables.Options{Iface: testIface, Scope: 9}, ErrInvalidScope},
+		{"hook", nftables.Options{Iface: testIface, Hook: 9}, ErrInvalidHook},
+		{"queue range", nftables.Options{Iface: testIface, Queue: 65535, QueueTotal: 2}, ErrInvalidQueueRange},
+		{"proxy port", nftables.Options{Iface: testIface, SpoofMode: nftables.Proxy}, ErrInvalidProxyPort},
+		{"proxy postrouting", nftables.Options{Iface: testIface, SpoofMode: nftables.Proxy, ProxyPort: 53, Hook: nftables.HookPostrouting}, ErrInvalidHook},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Commands(&tt.opts, "abcd1234"); !errors.Is(err, tt.err) {
+				t.Errorf("Commands() error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestParseCounter(t *testing.T) {
+	out := strings.Join([]string{
+		"Chain dnsspoof_abcd1234 (1 references)",
+		"    pkts      bytes target     prot opt in     out     source               destination",
+		"      12     1044 NFQUEUE    17   --  *      eth0    0.0.0.0/0            0.0.0.0/0            udp dpt:53",
+		"       3      180 NFQUEUE    6    --  *      eth0    0.0.0.0/0            0.0.0.0/0            tcp dpt:53",
+	}, "\n")
+	c, err := parseCounter([]byte(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Packets != 15 || c.Bytes != 1224 {
+		t.Errorf("counter = %+v, want 15 packets and 1224 bytes", c)
+	}
+
+	if _, err := parseCounter([]byte("Chain dnsspoof_abcd1234 (1 references)\n")); !errors.Is(err, ErrParseCounter) {
+		t.Errorf("parseCounter() of a chain without rules error = %v, want %v", err, ErrParseCounter)
+	}
+}
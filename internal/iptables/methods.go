@@ -0,0 +1,25 @@
+package iptables
+
+import "strings"
+
+// String returns c as a shell command line, quoting the arguments that need it.
+func (c Command) String() string {
+	args := make([]string, len(c))
+	for i, arg := range c {
+		if arg == "" || strings.ContainsAny(arg, " \t\n\"'\\$`!*?[]{}()<>|&;#~") {
+			arg = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+		}
+		args[i] = arg
+	}
+	return strings.Join(args, " ")
+}
+
+// String returns the command lines installing the rules.
+func (p *Plan) String() string {
+	var b strings.Builder
+	for _, cmd := range p.Add {
+		b.WriteString(cmd.String())
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
@@ -0,0 +1,63 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+const namespace = "dnsspoofer"
+
+var (
+	packetsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "packets_total"),
+		"Queued DNS packets by verdict.",
+		[]string{"verdict"}, nil,
+	)
+	matchedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "matched_total"),
+		"DNS packets answered or dropped by a rule.",
+		nil, nil,
+	)
+	recordsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "records_total"),
+		"Parsed DNS packets by question record type.",
+		[]string{"type"}, nil,
+	)
+	ruleHitsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "rule_hits_total"),
+		"Decisions per rule (hosts pattern).",
+		[]string{"rule"}, nil,
+	)
+	errorsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "errors_total"),
+		"Packet handling failures by error.",
+		[]string{"error"}, nil,
+	)
+	parseErrorsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "parse_errors_total"),
+		"DNS packet parse failures by cause.",
+		[]string{"error"}, nil,
+	)
+	latencyDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "spoof_latency_seconds"),
+		"Time from dequeuing a spoofed packet to issuing its verdict.",
+		nil, nil,
+	)
+	backlogDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "backlog_packets"),
+		"Packets waiting for a worker.",
+		nil, nil,
+	)
+	kernelPacketsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "kernel", "queued_packets_total"),
+		"Packets queued by the kernel rule since it was installed.",
+		nil, nil,
+	)
+	kernelBytesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "kernel", "queued_bytes_total"),
+		"Bytes queued by the kernel rule since it was installed.",
+		nil, nil,
+	)
+	installedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "nftables_rules_installed"),
+		"Whether the nftables rules are installed (1) or not (0).",
+		nil, nil,
+	)
+)
@@ -0,0 +1,8 @@
+package metrics
+
+import "errors"
+
+var (
+	ErrRegister = errors.New("failed to register metrics collector")
+	ErrListen   = errors.New("failed to listen for metrics requests")
+)
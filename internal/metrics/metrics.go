@@ -0,0 +1,124 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/Onyz107/dnsspoofer"
+	"github.com/Onyz107/dnsspoofer/internal/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Collector exports the statistics of an engine as Prometheus metrics.
+type Collector struct {
+	engine *dnsspoofer.Engine
+}
+
+// NewCollector creates a collector reading engine.Stats on every scrape.
+func NewCollector(engine *dnsspoofer.Engine) *Collector {
+	return &Collector{engine: engine}
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- packetsDesc
+	ch <- matchedDesc
+	ch <- recordsDesc
+	ch <- ruleHitsDesc
+	ch <- errorsDesc
+	ch <- parseErrorsDesc
+	ch <- latencyDesc
+	ch <- backlogDesc
+	ch <- kernelPacketsDesc
+	ch <- kernelBytesDesc
+	ch <- installedDesc
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.engine.Stats()
+
+	verdicts := map[string]uint64{
+		"spoofed":    stats.Spoofed,
+		"skipped":    stats.Skipped,
+		"dropped":    stats.Dropped,
+		"failed":     stats.Failed,
+		"queue_full": stats.QueueFull,
+	}
+	for verdict, n := range verdicts {
+		ch <- prometheus.MustNewConstMetric(packetsDesc, prometheus.CounterValue, float64(n), verdict)
+	}
+	ch <- prometheus.MustNewConstMetric(matchedDesc, prometheus.CounterValue, float64(stats.Matched))
+
+	for record, n := range stats.Records {
+		ch <- prometheus.MustNewConstMetric(recordsDesc, prometheus.CounterValue, float64(n), record)
+	}
+	for rule, n := range stats.Rules {
+		ch <- prometheus.MustNewConstMetric(ruleHitsDesc, prometheus.CounterValue, float64(n), rule)
+	}
+	for err, n := range stats.Errors {
+		ch <- prometheus.MustNewConstMetric(errorsDesc, prometheus.CounterValue, float64(n), err.Error())
+	}
+	for err, n := range stats.ParseErrors {
+		ch <- prometheus.MustNewConstMetric(parseErrorsDesc, prometheus.CounterValue, float64(n), err.Error())
+	}
+
+	buckets := make(map[float64]uint64, len(stats.SpoofLatency.Buckets))
+	for bound, n := range stats.SpoofLatency.Buckets {
+		buckets[bound.Seconds()] = n
+	}
+	ch <- prometheus.MustNewConstHistogram(latencyDesc,
+		stats.SpoofLatency.Count, stats.SpoofLatency.Sum.Seconds(), buckets)
+
+	ch <- prometheus.MustNewConstMetric(backlogDesc, prometheus.GaugeValue, float64(stats.Backlog))
+	ch <- prometheus.MustNewConstMetric(kernelPacketsDesc, prometheus.CounterValue, float64(stats.KernelPackets))
+	ch <- prometheus.MustNewConstMetric(kernelBytesDesc, prometheus.CounterValue, float64(stats.KernelBytes))
+
+	installed := 0.0
+	if stats.RulesInstalled {
+		installed = 1
+	}
+	ch <- prometheus.MustNewConstMetric(installedDesc, prometheus.GaugeValue, installed)
+}
+
+// Serve serves the engine metrics on addr at /metrics until ctx is done.
+//
+// Returns an error if addr cannot be listened on, serving itself happens in the background.
+func Serve(ctx context.Context, addr string, engine *dnsspoofer.Engine) error {
+	log := logger.LoggerFrom(ctx)
+
+	reg := prometheus.NewRegistry()
+	if err := reg.Register(NewCollector(engine)); err != nil {
+		return errors.Join(ErrRegister, err)
+	}
+	reg.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return errors.Join(ErrListen, err)
+	}
+
+	srv := &http.Server{Handler: mux}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+	go func() {
+		log.Info("serving metrics", "addr", ln.Addr().String())
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error(ErrListen.Error(), "err", err)
+		}
+	}()
+
+	return nil
+}
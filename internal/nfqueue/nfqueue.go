@@ -8,12 +8,45 @@ import (
 	"github.com/florianl/go-nfqueue/v2"
 )
 
-func GetPacketChan(ctx context.Context, nfq *nfqueue.Nfqueue) (<-chan Packet, error) {
+// GetPacketChan registers a hook on nfq and forwards every queued packet to the returned channel.
+//
+// The channel holds up to size packets. When it is full, or ctx is done, the packet is given
+// overflowVerdict, so an overloaded spoofer degrades to a passthrough (NfAccept) or fails closed (NfDrop).
+// onOverflow, if not nil, is called for every packet skipped because the channel was full.
+func GetPacketChan(ctx context.Context, nfq *nfqueue.Nfqueue, size int, overflowVerdict int, onOverflow func()) (<-chan Packet, error) {
 	log := logger.LoggerFrom(ctx)
 
-	packetCh := make(chan Packet, 1024)
+	packetCh := make(chan Packet, size)
+	handler := newHook(ctx, packetCh, nfq.SetVerdict, overflowVerdict, onOverflow)
 
-	handler := func(attr nfqueue.Attribute) int {
+	err := nfq.RegisterWithErrorFunc(
+		ctx,
+		handler,
+		func(e error) int {
+			log.Error(ErrNFQUEUERead, "err", e)
+			return 0
+		},
+	)
+	if err != nil {
+		nfq.Close()
+		return nil, errors.Join(ErrRegisterFunc, err)
+	}
+
+	go func() {
+		<-ctx.Done()
+		nfq.Close()
+	}()
+
+	return packetCh, nil
+}
+
+// newHook returns the hook forwarding queued packets to packetCh, the others are given overflowVerdict
+// through setVerdict.
+func newHook(ctx context.Context, packetCh chan<- Packet, setVerdict func(id uint32, verdict int) error,
+	overflowVerdict int, onOverflow func()) nfqueue.HookFunc {
+	log := logger.LoggerFrom(ctx)
+
+	return func(attr nfqueue.Attribute) int {
 		if attr.PacketID == nil || attr.Payload == nil {
 			return 0
 		}
@@ -29,34 +62,16 @@ func GetPacketChan(ctx context.Context, nfq *nfqueue.Nfqueue) (<-chan Packet, er
 		case packetCh <- pkt:
 			log.Debug("received packet sending to channel", "id", pkt.PacketID)
 		case <-ctx.Done():
-			nfq.SetVerdict(pkt.PacketID, nfqueue.NfAccept)
+			setVerdict(pkt.PacketID, overflowVerdict)
 			return 0
 		default:
-			log.Debug("packet channel full, dropping packet")
-			nfq.SetVerdict(pkt.PacketID, nfqueue.NfDrop)
+			log.Debug("packet channel full, skipping packet", "id", pkt.PacketID, "verdict", overflowVerdict)
+			setVerdict(pkt.PacketID, overflowVerdict)
+			if onOverflow != nil {
+				onOverflow()
+			}
 		}
 
 		return 0
 	}
-
-	err := nfq.RegisterWithErrorFunc(
-		ctx,
-		handler,
-		func(e error) int {
-			log.Error(ErrNFQUEUERead, "err", e)
-			return 0
-		},
-	)
-	if err != nil {
-		nfq.Close()
-		return nil, errors.Join(ErrRegisterFunc, err)
-	}
-
-	go func() {
-		<-ctx.Done()
-		nfq.Close()
-		close(packetCh)
-	}()
-
-	return packetCh, nil
 }
@@ -0,0 +1,93 @@
+package nfqueue
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/florianl/go-nfqueue/v2"
+)
+
+// attribute returns the attribute of a queued 60 bytes IPv4 packet.
+func attribute(id uint32) nfqueue.Attribute {
+	payload := make([]byte, 60)
+	payload[0] = 0x45
+	return nfqueue.Attribute{PacketID: &id, Payload: &payload}
+}
+
+func TestHookOverflow(t *testing.T) {
+	packetCh := make(chan Packet, 1)
+	verdicts := make(map[uint32]int)
+	var overflows int
+	hook := newHook(context.Background(), packetCh, func(id uint32, verdict int) error {
+		verdicts[id] = verdict
+		return nil
+	}, nfqueue.NfDrop, func() { overflows++ })
+
+	hook(attribute(1))
+	hook(attribute(2))
+	hook(nfqueue.Attribute{})
+
+	if pkt := <-packetCh; pkt.PacketID != 1 || pkt.IPVersion != 4 {
+		t.Errorf("queued packet = id %d version %d, want id 1 version 4", pkt.PacketID, pkt.IPVersion)
+	}
+	if len(verdicts) != 1 || verdicts[2] != nfqueue.NfDrop || overflows != 1 {
+		t.Errorf("verdicts = %v with %d overflows, want packet 2 dropped once", verdicts, overflows)
+	}
+}
+
+func TestHookDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var verdict int
+	var overflows int
+	hook := newHook(ctx, make(chan Packet), func(id uint32, v int) error {
+		verdict = v
+		return nil
+	}, nfqueue.NfAccept, func() { overflows++ })
+
+	// Once ctx is done, packets are not counted as overflow.
+	hook(attribute(1))
+	if verdict != nfqueue.NfAccept || overflows != 0 {
+		t.Errorf("verdict = %d with %d overflows, want %d without", verdict, overflows, nfqueue.NfAccept)
+	}
+}
+
+// BenchmarkHookQueued measures handing packets to a worker draining the channel.
+func BenchmarkHookQueued(b *testing.B) {
+	packetCh := make(chan Packet, 1024)
+	done := make(chan struct{})
+	go func() {
+		for range packetCh {
+		}
+		close(done)
+	}()
+
+	hook := newHook(context.Background(), packetCh, func(uint32, int) error { return nil }, nfqueue.NfAccept, nil)
+	attr := attribute(1)
+	b.ReportAllocs()
+	for b.Loop() {
+		hook(attr)
+	}
+	close(packetCh)
+	<-done
+}
+
+// BenchmarkHookOverflow measures the verdict path of packets arriving while the channel is full,
+// the load an overwhelmed spoofer has to shed.
+func BenchmarkHookOverflow(b *testing.B) {
+	var verdicts, overflows atomic.Uint64
+	hook := newHook(context.Background(), make(chan Packet), func(uint32, int) error {
+		verdicts.Add(1)
+		return nil
+	}, nfqueue.NfAccept, func() { overflows.Add(1) })
+
+	attr := attribute(1)
+	b.ReportAllocs()
+	for b.Loop() {
+		hook(attr)
+	}
+	if verdicts.Load() != overflows.Load() {
+		b.Fatalf("%d verdicts for %d overflows", verdicts.Load(), overflows.Load())
+	}
+}
@@ -1,5 +1,13 @@
 package nftables
 
+import (
+	"net"
+	"sync"
+
+	"github.com/google/nftables"
+	"github.com/google/nftables/expr"
+)
+
 // IPMode determines the IP spoofing mode.
 type IPMode uint32
 
@@ -19,12 +27,19 @@ const (
 )
 
 const (
-	// Aggressive SpoofMode intercepts DNS requests and responds to them, then eventually drops the request.
+	// Aggressive SpoofMode intercepts DNS requests, injects a forged reply toward the client and drops the request.
 	Aggressive SpoofMode = iota
 	// Passive SpoofMode intercepts DNS responses and modifies them.
 	Passive
+	// Hybrid SpoofMode intercepts both, answering requests and modifying the responses that still get through.
+	Hybrid
+	// Proxy SpoofMode redirects DNS requests, over UDP and TCP, to a local resolver instead of queueing them.
+	Proxy
 )
 
+// ProxyMark is the packet mark of the upstream queries of the Proxy SpoofMode resolver, never redirected.
+const ProxyMark = 0x646e73
+
 const (
 	// Local only spoofs packets coming from the local machine (OUTPUT chain)
 	Local Scope = iota
@@ -32,7 +47,137 @@ const (
 	Remote
 )
 
+// Hook overrides the netfilter hook the rules attach to.
+type Hook uint32
+
+const (
+	// HookAuto uses OUTPUT or INPUT depending on the SpoofMode, or FORWARD for the Remote Scope
+	HookAuto Hook = iota
+	// HookPrerouting matches packets entering the interface, before routing, whether local or forwarded
+	HookPrerouting
+	// HookPostrouting matches packets leaving the interface, after routing, whether local or forwarded
+	HookPostrouting
+)
+
 const (
 	udpDestPortOffset   = 2
 	udpSourcePortOffset = 0
 )
+
+// tablePrefix is the name prefix of every table created by this package.
+const tablePrefix = "dnsspoof_"
+
+const (
+	// ownerTag starts the rule comment recording the process that owns a table.
+	ownerTag = "dnsspoofer"
+	// procStatStartField is the index of starttime in /proc/<pid>/stat once pid and comm are cut off.
+	procStatStartField = 19
+)
+
+// TableInfo describes an installed dnsspoofer table.
+type TableInfo struct {
+	Name   string      `json:"name"`
+	Family string      `json:"family"`
+	Chains []ChainInfo `json:"chains"`
+	// PID is the process that installed the table, 0 if its rules carry no owner tag
+	PID int `json:"pid"`
+	// Stale reports that the owner is gone, or unknown, and the table only queues to a dead queue
+	Stale bool `json:"stale"`
+	// Attached reports an existing table dnsspoofer rules were inserted into, listed once per owning process:
+	// Chains then only lists the chains holding the rules of PID and Rules only counts them
+	Attached bool `json:"attached"`
+}
+
+// ChainInfo describes a chain of an installed dnsspoofer table.
+type ChainInfo struct {
+	Name     string `json:"name"`
+	Hook     string `json:"hook"`
+	Priority int32  `json:"priority"`
+	Rules    int    `json:"rules"`
+}
+
+// Options holds the parameters of the rules created by AddDNSQueue.
+type Options struct {
+	// IPMode selects whether IPv4 and/or IPv6 packets are matched
+	IPMode IPMode
+	// Iface is the interface DNS packets are matched on
+	Iface *net.Interface
+	// SpoofMode selects whether requests or responses are queued
+	SpoofMode SpoofMode
+	// Scope selects the OUTPUT/INPUT or FORWARD hook
+	Scope Scope
+	// Queue is the first NFQUEUE number packets are sent to
+	Queue uint16
+	// QueueTotal is the number of consecutive queues packets are fanned out to, 0 is treated as 1
+	QueueTotal uint16
+	// FailClosed drops queued packets while no program is bound to the queue instead of accepting them
+	FailClosed bool
+	// Hook overrides the hook chosen from SpoofMode and Scope
+	Hook Hook
+	// Priority is the priority of the created chain, 0 being the filter priority and lower values running first
+	Priority int32
+	// Table and Chain name an existing table, as "family name" (e.g. "inet filter"), and one of its base chains.
+	// When set, the rule is inserted at the top of that chain instead of in a new table, Hook and Priority are
+	// then those of the existing chain.
+	Table string
+	Chain string
+	// ProxyPort is the local port DNS requests are redirected to in Proxy SpoofMode
+	ProxyPort uint16
+}
+
+// ruleset is what AddDNSQueue installs: a table holding a base chain per hook with the rules of the SpoofMode,
+// or only the rules when attached to an existing chain.
+type ruleset struct {
+	table    *nftables.Table
+	chains   []*nftables.Chain
+	rules    []*nftables.Rule
+	comment  string
+	iface    string
+	attached bool
+}
+
+// dnsMatch selects the DNS packets of one rule.
+type dnsMatch struct {
+	key       expr.MetaKey
+	l4proto   byte
+	offset    uint32
+	hook      *nftables.ChainHook
+	skipProxy bool
+}
+
+// Installed is the rules installed by AddDNSQueue.
+type Installed interface {
+	// Remove deletes the rules, it can be retried until it succeeds and does nothing once it has
+	Remove() error
+	// Counter returns what the rules queued since they were installed
+	Counter() (Counter, error)
+	// String returns the rules, in nft syntax or as firewall command lines
+	String() string
+}
+
+// Counter is the number of packets and bytes a rule matched.
+type Counter struct {
+	Packets uint64
+	Bytes   uint64
+}
+
+// installed implements Installed for a ruleset.
+type installed struct {
+	rs      *ruleset
+	mu      sync.Mutex
+	deleted bool
+}
+
+// owned is a table holding dnsspoofer state, with the tagged rules of one process to delete
+// when it is not a dnsspoofer table.
+type owned struct {
+	info  TableInfo
+	table *nftables.Table
+	rules []*nftables.Rule
+}
+
+// owner is the process recorded in the comment of a rule.
+type owner struct {
+	pid   int
+	start uint64
+}
@@ -5,9 +5,20 @@ import "errors"
 var (
 	ErrNewNetlinkConn       = errors.New("failed to create a new netlink connection")
 	ErrFlush                = errors.New("failed to push nftables rules to kernel, try flushing nftables")
+	ErrMissingIface         = errors.New("no interface to match DNS packets on")
 	ErrInvalidIPMode        = errors.New("invalid IP mode")
 	ErrInvalidScope         = errors.New("invalid scope")
 	ErrInvalidSpoofMode     = errors.New("invalid spoof mode")
+	ErrInvalidHook          = errors.New("invalid hook")
+	ErrInvalidProxyPort     = errors.New("invalid proxy port")
+	ErrInvalidTable         = errors.New(`invalid existing table, expected "family name" along with a chain`)
+	ErrMissingRule          = errors.New("installed rule not found")
+	ErrMissingChain         = errors.New("existing chain not found")
+	ErrAttachedHooks        = errors.New("rules span several hooks and cannot share an existing chain, use the remote scope or a prerouting/postrouting hook")
+	ErrInvalidQueueRange    = errors.New("invalid NFQUEUE range")
+	ErrListRuleset          = errors.New("failed to list nftables ruleset")
+	ErrRollback             = errors.New("failed to roll back nftables table")
+	ErrParseProcStat        = errors.New("failed to parse process stat")
 	ErrUnkownIPModeValue    = errors.New("unknown IP mode value")
 	ErrUnkownSpoofModeValue = errors.New("unknown spoof mode value")
 	ErrUnkownScopeValue     = errors.New("unknown scope value")
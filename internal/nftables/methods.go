@@ -1,5 +1,7 @@
 package nftables
 
+import "github.com/google/nftables"
+
 func (m *IPMode) String() string {
 	switch *m {
 	case IPv4Only:
@@ -19,6 +21,10 @@ func (m *SpoofMode) String() string {
 		return "aggressive"
 	case Passive:
 		return "passive"
+	case Hybrid:
+		return "hybrid"
+	case Proxy:
+		return "proxy"
 	default:
 		return "unknown"
 	}
@@ -34,3 +40,68 @@ func (s *Scope) String() string {
 		return "unknown"
 	}
 }
+
+func (h *Hook) String() string {
+	switch *h {
+	case HookAuto:
+		return "auto"
+	case HookPrerouting:
+		return "prerouting"
+	case HookPostrouting:
+		return "postrouting"
+	default:
+		return "unknown"
+	}
+}
+
+func familyName(f nftables.TableFamily) string {
+	switch f {
+	case nftables.TableFamilyINet:
+		return "inet"
+	case nftables.TableFamilyIPv4:
+		return "ip"
+	case nftables.TableFamilyIPv6:
+		return "ip6"
+	case nftables.TableFamilyARP:
+		return "arp"
+	case nftables.TableFamilyBridge:
+		return "bridge"
+	case nftables.TableFamilyNetdev:
+		return "netdev"
+	default:
+		return "unknown"
+	}
+}
+
+func hookName(h *nftables.ChainHook) string {
+	if h == nil {
+		return ""
+	}
+	switch *h {
+	case *nftables.ChainHookPrerouting:
+		return "prerouting"
+	case *nftables.ChainHookInput:
+		return "input"
+	case *nftables.ChainHookForward:
+		return "forward"
+	case *nftables.ChainHookOutput:
+		return "output"
+	case *nftables.ChainHookPostrouting:
+		return "postrouting"
+	default:
+		return "unknown"
+	}
+}
+
+func parseFamily(name string) (nftables.TableFamily, bool) {
+	switch name {
+	case "inet":
+		return nftables.TableFamilyINet, true
+	case "ip":
+		return nftables.TableFamilyIPv4, true
+	case "ip6":
+		return nftables.TableFamilyIPv6, true
+	default:
+		return 0, false
+	}
+}
@@ -5,163 +5,578 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
-	"net"
-	"sync"
+	"math"
+	"slices"
+	"strings"
 
 	"github.com/Onyz107/dnsspoofer/internal/logger"
 	"github.com/google/nftables"
 	"github.com/google/nftables/expr"
+	"github.com/google/nftables/userdata"
 	"github.com/google/uuid"
 	"golang.org/x/sys/unix"
 )
 
-func createNFTRule(tableName string, family nftables.TableFamily, chainName string, hook *nftables.ChainHook,
-	key expr.MetaKey, offset uint32, queue uint16, ifaceIndex uint32) (func() error, error) {
-	conn, err := nftables.New()
-	if err != nil {
-		return nil, errors.Join(ErrNewNetlinkConn, err)
-	}
+// newConn opens the netlink connection every kernel operation of this package goes through.
+var newConn = func() (*nftables.Conn, error) { return nftables.New() }
+
+// dnsRule builds the rule queueing UDP DNS packets of iface to queue in chain.
+//
+// A nil nfproto matches both IPv4 and IPv6. The comment records the owner of the rule, see OwnerComment.
+func dnsRule(table *nftables.Table, chain *nftables.Chain, nfproto []byte,
+	m dnsMatch, ifaceIndex uint32, comment string, verdict ...expr.Any) *nftables.Rule {
+	dataBuf := make([]byte, 4)
+	binary.LittleEndian.PutUint32(dataBuf, ifaceIndex)
 
-	table := &nftables.Table{
-		Name:   tableName,
-		Family: family,
+	var exprs []expr.Any
+	if nfproto != nil {
+		// meta nfproto ipv4/ipv6
+		exprs = append(exprs,
+			&expr.Meta{Key: expr.MetaKeyNFPROTO, Register: 1},
+			&expr.Cmp{Register: 1, Op: expr.CmpOpEq, Data: nfproto},
+		)
+	}
+	// match ingoing/outgoing interface
+	exprs = append(exprs,
+		&expr.Meta{Key: m.key, Register: 1},
+		&expr.Cmp{Register: 1, Op: expr.CmpOpEq, Data: dataBuf},
+	)
+	if m.skipProxy {
+		// meta mark != ProxyMark, leaves the upstream queries of the proxy alone
+		mark := make([]byte, 4)
+		binary.NativeEndian.PutUint32(mark, ProxyMark)
+		exprs = append(exprs,
+			&expr.Meta{Key: expr.MetaKeyMARK, Register: 1},
+			&expr.Cmp{Register: 1, Op: expr.CmpOpNeq, Data: mark},
+		)
 	}
-	conn.AddTable(table)
+	exprs = append(exprs,
+		// meta l4proto udp/tcp
+		&expr.Meta{Key: expr.MetaKeyL4PROTO, Register: 1},
+		&expr.Cmp{Register: 1, Op: expr.CmpOpEq, Data: []byte{m.l4proto}},
 
-	policy := nftables.ChainPolicyAccept
-	chain := &nftables.Chain{
-		Name:     chainName,
+		// sport/dport 53
+		&expr.Payload{DestRegister: 2, Base: expr.PayloadBaseTransportHeader, Offset: m.offset, Len: 2},
+		&expr.Cmp{Register: 2, Op: expr.CmpOpEq, Data: []byte{0x00, 0x35}},
+
+		// counter, read back by Installed.Counter
+		&expr.Counter{},
+	)
+	exprs = append(exprs, verdict...)
+
+	return &nftables.Rule{
 		Table:    table,
-		Type:     nftables.ChainTypeFilter,
-		Hooknum:  hook,
-		Priority: nftables.ChainPriorityFilter,
-		Policy:   &policy,
+		Chain:    chain,
+		Exprs:    exprs,
+		UserData: userdata.AppendString(nil, userdata.TypeComment, comment),
 	}
-	conn.AddChain(chain)
-
-	dataBuf := make([]byte, 4)
-	binary.LittleEndian.PutUint32(dataBuf, ifaceIndex)
-	rule := &nftables.Rule{
-		Table: table,
-		Chain: chain,
-		Exprs: []expr.Any{
-			// match ingoing/outgoing interface
-			&expr.Meta{Key: key, Register: 1},
-			&expr.Cmp{Register: 1, Op: expr.CmpOpEq, Data: dataBuf},
+}
 
-			// meta l4proto udp
-			&expr.Meta{Key: expr.MetaKeyL4PROTO, Register: 1},
-			&expr.Cmp{Register: 1, Op: expr.CmpOpEq, Data: []byte{unix.IPPROTO_UDP}},
+// AddDNSQueue creates an inet nftables table whose single chain sends DNS packets to a netfilter queue.
+// It supports filtering for IPv4, IPv6, or both, and can target either DNS requests or responses.
+// The table is created and deleted atomically, together with its chain and rule. If creating it fails,
+// the table is deleted again in case the kernel committed the batch before the error was reported.
+// Installed.Remove can be retried until it succeeds.
+//
+// Packets are queued to opts.Queue, or fanned out by CPU over the opts.QueueTotal queues starting at opts.Queue.
+// Unless opts.FailClosed is set, the queue bypass flag lets packets through while no program is bound to the queue.
+//
+// When opts.Table and opts.Chain are set, the rule is instead inserted at the top of that existing chain
+// and Remove only deletes the rule.
+//
+// Returns an error if an invalid parameter is provided, or if creating or flushing nftables rules fails.
+func AddDNSQueue(ctx context.Context, opts *Options) (Installed, error) {
+	log := logger.LoggerFrom(ctx)
 
-			// udp sport/dport 53
-			&expr.Payload{DestRegister: 2, Base: expr.PayloadBaseTransportHeader, Offset: offset, Len: 2},
-			&expr.Cmp{Register: 2, Op: expr.CmpOpEq, Data: []byte{0x00, 0x35}},
+	rs, err := buildRuleset(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
 
-			// nfqueue
-			&expr.Queue{Num: queue, Flag: expr.QueueFlagBypass},
-		},
+	conn, err := newConn()
+	if err != nil {
+		return nil, errors.Join(ErrNewNetlinkConn, err)
 	}
 
-	conn.AddRule(rule)
+	if owned, err := listOwned(conn); err == nil {
+		for _, o := range owned {
+			if o.info.Stale {
+				log.Error("found a stale dnsspoofer nftables table still queueing DNS packets, remove it with the cleanup command",
+					"table", o.info.Name, "family", o.info.Family, "pid", o.info.PID)
+			}
+		}
+	}
 
+	if rs.attached {
+		if _, err := conn.ListChain(rs.table, rs.chains[0].Name); err != nil {
+			return nil, errors.Join(ErrMissingChain, err)
+		}
+		for _, r := range rs.rules {
+			conn.InsertRule(r)
+		}
+	} else {
+		// The table, chains and rules are committed by the kernel in a single transaction.
+		conn.AddTable(rs.table)
+		for _, c := range rs.chains {
+			conn.AddChain(c)
+		}
+		for _, r := range rs.rules {
+			conn.AddRule(r)
+		}
+	}
 	if err := conn.Flush(); err != nil {
+		if rbErr := rs.remove(); rbErr != nil {
+			log.Error(ErrRollback.Error(), "table", rs.table.Name, "err", rbErr)
+		}
 		return nil, errors.Join(ErrFlush, err)
 	}
+	log.Debug("installed nftables rules", "table", rs.table.Name, "rules", len(rs.rules), "attached", rs.attached)
+
+	return &installed{rs: rs}, nil
+}
 
-	return func() error {
-		conn, err := nftables.New()
+// Remove implements Installed.
+func (in *installed) Remove() error {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if in.deleted {
+		return nil
+	}
+	if err := in.rs.remove(); err != nil {
+		return err
+	}
+	in.deleted = true
+	return nil
+}
+
+// Counter implements Installed, reading back the counter expression of the rule.
+func (in *installed) Counter() (Counter, error) {
+	conn, err := newConn()
+	if err != nil {
+		return Counter{}, errors.Join(ErrNewNetlinkConn, err)
+	}
+
+	var total Counter
+	found := false
+	for _, chain := range in.rs.chains {
+		rules, err := conn.GetRules(in.rs.table, chain)
 		if err != nil {
-			return errors.Join(ErrNewNetlinkConn, err)
+			return Counter{}, errors.Join(ErrListRuleset, err)
 		}
-
-		conn.DelTable(table)
-		if err := conn.Flush(); err != nil {
-			return errors.Join(ErrFlush, err)
+		for _, r := range rules {
+			if comment, _ := userdata.GetString(r.UserData, userdata.TypeComment); comment != in.rs.comment {
+				continue
+			}
+			for _, e := range r.Exprs {
+				if c, ok := e.(*expr.Counter); ok {
+					total.Packets += c.Packets
+					total.Bytes += c.Bytes
+					found = true
+				}
+			}
 		}
-		return nil
-	}, nil
+	}
+	if !found {
+		return Counter{}, ErrMissingRule
+	}
+	return total, nil
 }
 
-// AddDNSQueue creates nftables rules to capture DNS packets and send them to a netfilter queue.
-// It supports filtering for IPv4, IPv6, or both, and can target either DNS requests or responses.
+// String implements Installed.
+func (in *installed) String() string {
+	return in.rs.render()
+}
+
+// buildRuleset builds the table, chain and rule AddDNSQueue installs for opts, without touching the kernel.
 //
-// Returns an error if an invalid parameter is provided, or if creating or flushing nftables rules fails.
-func AddDNSQueue(ctx context.Context, ipMode IPMode, iface *net.Interface, spoofMode SpoofMode, scope Scope, queue uint16) (func() error, error) {
+// Returns an error if an invalid parameter is provided.
+func buildRuleset(ctx context.Context, opts *Options) (*ruleset, error) {
 	log := logger.LoggerFrom(ctx)
 
-	var families map[string]nftables.TableFamily
-	switch ipMode {
+	if opts.Iface == nil {
+		return nil, ErrMissingIface
+	}
+
+	queueTotal := opts.QueueTotal
+	if queueTotal == 0 {
+		queueTotal = 1
+	}
+	if uint32(opts.Queue)+uint32(queueTotal)-1 > math.MaxUint16 {
+		return nil, ErrInvalidQueueRange
+	}
+	queue := &expr.Queue{Num: opts.Queue, Total: queueTotal}
+	if !opts.FailClosed {
+		queue.Flag |= expr.QueueFlagBypass
+	}
+	if queueTotal > 1 {
+		queue.Flag |= expr.QueueFlagFanout
+	}
+	log.Debug("queueing DNS packets", "queue", opts.Queue, "total", queueTotal, "fail_closed", opts.FailClosed)
+
+	var nfproto []byte
+	switch opts.IPMode {
 	case IPv4Only:
 		log.Debug("filtering only for IPv4")
-		families = map[string]nftables.TableFamily{
-			"dnsspoof_IPv4_filter": nftables.TableFamilyIPv4,
-		}
+		nfproto = []byte{unix.NFPROTO_IPV4}
 	case IPv6Only:
 		log.Debug("filtering only for IPv6")
-		families = map[string]nftables.TableFamily{
-			"dnsspoof_IPv6_filter": nftables.TableFamilyIPv6,
-		}
+		nfproto = []byte{unix.NFPROTO_IPV6}
 	case IPv4AndIPv6:
 		log.Debug("filtering for both IPv4 and IPv6")
-		families = map[string]nftables.TableFamily{
-			"dnsspoof_IPv4_filter": nftables.TableFamilyIPv4,
-			"dnsspoof_IPv6_filter": nftables.TableFamilyIPv6,
-		}
 	default:
 		return nil, ErrInvalidIPMode
 	}
 
-	var key expr.MetaKey
-	var hook *nftables.ChainHook
-	var offset uint32
-	switch spoofMode {
+	request := dnsMatch{key: expr.MetaKeyOIF, l4proto: unix.IPPROTO_UDP, offset: udpDestPortOffset, hook: nftables.ChainHookOutput}
+	response := dnsMatch{key: expr.MetaKeyIIF, l4proto: unix.IPPROTO_UDP, offset: udpSourcePortOffset, hook: nftables.ChainHookInput}
+	var matches []dnsMatch
+	switch opts.SpoofMode {
 	case Aggressive:
-		key = expr.MetaKeyOIF           // sniff from output interface
-		offset = udpDestPortOffset      // dport
-		hook = nftables.ChainHookOutput // OUTPUT chain
+		matches = []dnsMatch{request}
 		log.Debug("filtering for DNS requests", "key", "OIF", "offset", "2 (dport)", "hook", "OUTPUT")
 	case Passive:
-		key = expr.MetaKeyIIF          // sniff from input interface
-		offset = udpSourcePortOffset   // sport
-		hook = nftables.ChainHookInput // INPUT chain
+		matches = []dnsMatch{response}
 		log.Debug("filtering for DNS responses", "key", "IIF", "offset", "1 (sport)", "hook", "INPUT")
+	case Hybrid:
+		matches = []dnsMatch{request, response}
+		log.Debug("filtering for DNS requests and responses", "hooks", "OUTPUT, INPUT")
+	case Proxy:
+		return buildProxyRuleset(ctx, opts, nfproto)
 	default:
 		return nil, ErrInvalidSpoofMode
 	}
 
-	if scope == Remote {
-		hook = nftables.ChainHookForward
+	if opts.Scope == Remote {
+		for i := range matches {
+			matches[i].hook = nftables.ChainHookForward
+		}
 		log.Debug("filtering for remote DNS packets", "hook", "FORWARD")
-	} else if scope != Local {
+	} else if opts.Scope != Local {
 		return nil, ErrInvalidScope
 	}
 
-	var cleanups []func() error
-	for tableName, family := range families {
-		cleanup, err := createNFTRule(tableName, family,
-			fmt.Sprintf("dnsspoof_chain_%s_%s_%s", spoofMode.String(), scope.String(), uuid.New().String()),
-			hook, key, offset, queue, uint32(iface.Index))
+	// Prerouting only knows the input interface and postrouting the output one,
+	// both see local and forwarded packets alike.
+	switch opts.Hook {
+	case HookAuto:
+	case HookPrerouting:
+		for i := range matches {
+			matches[i].key = expr.MetaKeyIIF
+			matches[i].hook = nftables.ChainHookPrerouting
+		}
+		log.Debug("filtering DNS packets before routing", "key", "IIF", "hook", "PREROUTING")
+	case HookPostrouting:
+		for i := range matches {
+			matches[i].key = expr.MetaKeyOIF
+			matches[i].hook = nftables.ChainHookPostrouting
+		}
+		log.Debug("filtering DNS packets after routing", "key", "OIF", "hook", "POSTROUTING")
+	default:
+		return nil, ErrInvalidHook
+	}
+
+	rs, err := newRuleset(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	// An existing chain sits on a single hook, the rules of one direction would never match in it.
+	if rs.attached && slices.ContainsFunc(matches, func(m dnsMatch) bool { return *m.hook != *matches[0].hook }) {
+		return nil, ErrAttachedHooks
+	}
+	for _, m := range matches {
+		chain := rs.chainFor(m.hook, nftables.ChainTypeFilter, nftables.ChainPriority(opts.Priority))
+		rs.rules = append(rs.rules, dnsRule(rs.table, chain, nfproto, m, uint32(opts.Iface.Index), rs.comment, queue))
+	}
+
+	return rs, nil
+}
 
+// buildProxyRuleset builds the rules redirecting UDP and TCP DNS requests to the local proxy port,
+// except the upstream queries of the proxy itself, marked with ProxyMark.
+func buildProxyRuleset(ctx context.Context, opts *Options, nfproto []byte) (*ruleset, error) {
+	log := logger.LoggerFrom(ctx)
+
+	if opts.ProxyPort == 0 {
+		return nil, ErrInvalidProxyPort
+	}
+
+	// Redirection needs a nat chain: OUTPUT for local requests, PREROUTING for forwarded ones.
+	key, hook := expr.MetaKeyOIF, nftables.ChainHookOutput
+	if opts.Scope == Remote {
+		key, hook = expr.MetaKeyIIF, nftables.ChainHookPrerouting
+	} else if opts.Scope != Local {
+		return nil, ErrInvalidScope
+	}
+	switch opts.Hook {
+	case HookAuto:
+	case HookPrerouting:
+		key, hook = expr.MetaKeyIIF, nftables.ChainHookPrerouting
+	default:
+		return nil, ErrInvalidHook
+	}
+	log.Debug("redirecting DNS requests to the proxy", "port", opts.ProxyPort, "hook", hookName(hook))
+
+	rs, err := newRuleset(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	port := binary.BigEndian.AppendUint16(nil, opts.ProxyPort)
+	chain := rs.chainFor(hook, nftables.ChainTypeNAT, nftables.ChainPriority(opts.Priority))
+	for _, l4proto := range []byte{unix.IPPROTO_UDP, unix.IPPROTO_TCP} {
+		m := dnsMatch{key: key, l4proto: l4proto, offset: udpDestPortOffset, hook: hook, skipProxy: true}
+		rs.rules = append(rs.rules, dnsRule(rs.table, chain, nfproto, m, uint32(opts.Iface.Index), rs.comment,
+			// redirect to :ProxyPort
+			&expr.Immediate{Register: 1, Data: port},
+			&expr.Redir{RegisterProtoMin: 1},
+		))
+	}
+
+	return rs, nil
+}
+
+// newRuleset returns an empty ruleset, with the existing chain opts attaches to or a new table.
+func newRuleset(ctx context.Context, opts *Options) (*ruleset, error) {
+	log := logger.LoggerFrom(ctx)
+
+	id := uuid.New().String()[:8]
+	rs := &ruleset{comment: OwnerComment(id), iface: opts.Iface.Name}
+	if opts.Table != "" || opts.Chain != "" {
+		family, name, ok := strings.Cut(opts.Table, " ")
+		tableFamily, known := parseFamily(family)
+		if !ok || !known || name == "" || opts.Chain == "" {
+			return nil, ErrInvalidTable
+		}
+		rs.attached = true
+		rs.table = &nftables.Table{Name: name, Family: tableFamily}
+		rs.chains = []*nftables.Chain{{Name: opts.Chain, Table: rs.table}}
+		log.Debug("attaching to an existing chain", "table", opts.Table, "chain", opts.Chain)
+	} else {
+		rs.table = &nftables.Table{
+			Name:   fmt.Sprintf("%s%s_%s_%s", tablePrefix, opts.SpoofMode.String(), opts.Scope.String(), id),
+			Family: nftables.TableFamilyINet,
+		}
+	}
+
+	return rs, nil
+}
+
+// chainFor returns the base chain of rs registered on hook, adding it if needed,
+// or the existing chain rs is attached to.
+func (rs *ruleset) chainFor(hook *nftables.ChainHook, typ nftables.ChainType, priority nftables.ChainPriority) *nftables.Chain {
+	if rs.attached {
+		return rs.chains[0]
+	}
+	for _, c := range rs.chains {
+		if *c.Hooknum == *hook {
+			return c
+		}
+	}
+	policy := nftables.ChainPolicyAccept
+	c := &nftables.Chain{
+		Name:     tablePrefix + hookName(hook),
+		Table:    rs.table,
+		Type:     typ,
+		Hooknum:  hook,
+		Priority: &priority,
+		Policy:   &policy,
+	}
+	rs.chains = append(rs.chains, c)
+	return c
+}
+
+// remove deletes what AddDNSQueue installed for rs: the whole table, or only the rule of an attached ruleset.
+// Anything already gone is not an error.
+//
+// A fresh connection is used so that a failed batch of another connection cannot leak into this one.
+func (rs *ruleset) remove() error {
+	conn, err := newConn()
+	if err != nil {
+		return errors.Join(ErrNewNetlinkConn, err)
+	}
+
+	if !rs.attached {
+		conn.DelTable(rs.table)
+	} else {
+		chains, err := conn.ListChainsOfTableFamily(rs.table.Family)
+		if err != nil {
+			return errors.Join(ErrListRuleset, err)
+		}
+		if !slices.ContainsFunc(chains, func(c *nftables.Chain) bool {
+			return c.Table.Name == rs.table.Name && c.Name == rs.chains[0].Name
+		}) {
+			return nil
+		}
+
+		rules, err := conn.GetRules(rs.table, rs.chains[0])
 		if err != nil {
-			return nil, err
+			return errors.Join(ErrListRuleset, err)
+		}
+		// The kernel assigned the rule handle, the unique comment tells the rules apart.
+		for _, r := range rules {
+			if comment, _ := userdata.GetString(r.UserData, userdata.TypeComment); comment == rs.comment {
+				if err := conn.DelRule(r); err != nil {
+					return errors.Join(ErrFlush, err)
+				}
+			}
+		}
+	}
+
+	if err := conn.Flush(); err != nil && !errors.Is(err, unix.ENOENT) {
+		return errors.Join(ErrFlush, err)
+	}
+	return nil
+}
+
+// ListTables returns the dnsspoofer tables currently installed in the kernel, with their chains and owner,
+// followed by the existing tables dnsspoofer rules were inserted into.
+func ListTables() ([]TableInfo, error) {
+	conn, err := newConn()
+	if err != nil {
+		return nil, errors.Join(ErrNewNetlinkConn, err)
+	}
+
+	owned, err := listOwned(conn)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]TableInfo, 0, len(owned))
+	for _, o := range owned {
+		infos = append(infos, o.info)
+	}
+	return infos, nil
+}
+
+// CleanupTables deletes the stale dnsspoofer tables and rules, or every one of them if all is set,
+// in a single transaction and returns the tables they were deleted from.
+//
+// Within an existing table only the rules of dead processes are deleted, those of running instances are kept.
+func CleanupTables(ctx context.Context, all bool) ([]TableInfo, error) {
+	log := logger.LoggerFrom(ctx)
+
+	conn, err := newConn()
+	if err != nil {
+		return nil, errors.Join(ErrNewNetlinkConn, err)
+	}
+
+	owned, err := listOwned(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	var deleted []TableInfo
+	for _, o := range owned {
+		if !all && !o.info.Stale {
+			continue
 		}
+		log.Debug("deleting nftables state", "table", o.info.Name, "family", o.info.Family,
+			"pid", o.info.PID, "stale", o.info.Stale, "attached", o.info.Attached)
+		if o.info.Attached {
+			for _, r := range o.rules {
+				if err := conn.DelRule(r); err != nil {
+					return nil, errors.Join(ErrFlush, err)
+				}
+			}
+		} else {
+			conn.DelTable(o.table)
+		}
+		deleted = append(deleted, o.info)
+	}
+	if len(deleted) == 0 {
+		return nil, nil
+	}
 
-		cleanups = append(cleanups, cleanup)
+	if err := conn.Flush(); err != nil {
+		return nil, errors.Join(ErrFlush, err)
 	}
+	return deleted, nil
+}
 
-	var once sync.Once
+// listOwned returns the dnsspoofer tables of conn, then the rules inserted into other tables,
+// one entry per table and owning process so that instances sharing a chain are told apart.
+func listOwned(conn *nftables.Conn) ([]owned, error) {
+	tables, err := conn.ListTables()
+	if err != nil {
+		return nil, errors.Join(ErrListRuleset, err)
+	}
+	chains, err := conn.ListChains()
+	if err != nil {
+		return nil, errors.Join(ErrListRuleset, err)
+	}
+
+	var ours, attached []owned
+	for _, t := range tables {
+		own := strings.HasPrefix(t.Name, tablePrefix)
+
+		// Tables without an owner tag predate it or were not created by dnsspoofer, so no process claims them.
+		o := owned{
+			info:  TableInfo{Name: t.Name, Family: familyName(t.Family), Stale: own},
+			table: t,
+		}
+		var owners []owner
+		byOwner := make(map[owner]*owned)
+		for _, c := range chains {
+			if c.Table.Name != t.Name || c.Table.Family != t.Family {
+				continue
+			}
 
-	return func() error {
-		var err error
-		once.Do(func() {
-			var errs []error
-			for _, c := range cleanups {
-				if e := c(); e != nil {
-					errs = append(errs, e)
+			rules, err := conn.GetRules(t, c)
+			if err != nil {
+				return nil, errors.Join(ErrListRuleset, err)
+			}
+			if own {
+				for _, r := range rules {
+					comment, _ := userdata.GetString(r.UserData, userdata.TypeComment)
+					if pid, start, ok := parseOwner(comment); ok {
+						o.info.PID = pid
+						o.info.Stale = !alive(pid, start)
+					}
 				}
+				o.info.Chains = append(o.info.Chains, chainInfo(c, len(rules)))
+				continue
 			}
-			err = errors.Join(errs...)
-		})
-		return err
-	}, nil
+
+			tagged := make(map[owner][]*nftables.Rule)
+			for _, r := range rules {
+				comment, _ := userdata.GetString(r.UserData, userdata.TypeComment)
+				pid, start, ok := parseOwner(comment)
+				if !ok {
+					continue
+				}
+				k := owner{pid: pid, start: start}
+				if _, seen := byOwner[k]; !seen {
+					byOwner[k] = &owned{
+						info:  TableInfo{Name: t.Name, Family: o.info.Family, PID: pid, Stale: !alive(pid, start), Attached: true},
+						table: t,
+					}
+					owners = append(owners, k)
+				}
+				tagged[k] = append(tagged[k], r)
+			}
+			for _, k := range owners {
+				if len(tagged[k]) == 0 {
+					continue
+				}
+				byOwner[k].rules = append(byOwner[k].rules, tagged[k]...)
+				byOwner[k].info.Chains = append(byOwner[k].info.Chains, chainInfo(c, len(tagged[k])))
+			}
+		}
+
+		if own {
+			ours = append(ours, o)
+		}
+		for _, k := range owners {
+			attached = append(attached, *byOwner[k])
+		}
+	}
+
+	return append(ours, attached...), nil
+}
+
+// chainInfo describes the chain c holding n dnsspoofer rules.
+func chainInfo(c *nftables.Chain, n int) ChainInfo {
+	info := ChainInfo{Name: c.Name, Hook: hookName(c.Hooknum), Rules: n}
+	if c.Priority != nil {
+		info.Priority = int32(*c.Priority)
+	}
+	return info
 }
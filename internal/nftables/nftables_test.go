@@ -0,0 +1,607 @@
+package nftables
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/nftables"
+	"github.com/google/nftables/expr"
+	"github.com/google/nftables/userdata"
+	"github.com/mdlayher/netlink"
+	"github.com/mdlayher/netlink/nltest"
+	"golang.org/x/sys/unix"
+)
+
+var testIface = &net.Interface{Index: 7, Name: "eth0"}
+
+// ruleSummary is what a rule built by dnsRule matches, read back from its expressions.
+type ruleSummary struct {
+	hook      nftables.ChainHook
+	key       expr.MetaKey
+	nfproto   byte
+	l4proto   byte
+	offset    uint32
+	skipProxy bool
+}
+
+// summarize reads back the expressions of r in the order dnsRule emits them.
+func summarize(t *testing.T, r *nftables.Rule) ruleSummary {
+	t.Helper()
+	s := ruleSummary{hook: *r.Chain.Hooknum}
+	for i := 0; i < len(r.Exprs); i++ {
+		switch e := r.Exprs[i].(type) {
+		case *expr.Meta:
+			i++
+			cmp := r.Exprs[i].(*expr.Cmp)
+			switch e.Key {
+			case expr.MetaKeyNFPROTO:
+				s.nfproto = cmp.Data[0]
+			case expr.MetaKeyIIF, expr.MetaKeyOIF:
+				s.key = e.Key
+				if got := binary.LittleEndian.Uint32(cmp.Data); got != uint32(testIface.Index) {
+					t.Errorf("interface index = %d, want %d", got, testIface.Index)
+				}
+			case expr.MetaKeyMARK:
+				s.skipProxy = cmp.Op == expr.CmpOpNeq && binary.NativeEndian.Uint32(cmp.Data) == ProxyMark
+			case expr.MetaKeyL4PROTO:
+				s.l4proto = cmp.Data[0]
+			}
+		case *expr.Payload:
+			i++
+			if cmp := r.Exprs[i].(*expr.Cmp); !reflect.DeepEqual(cmp.Data, []byte{0, 53}) {
+				t.Errorf("port = %v, want 53", cmp.Data)
+			}
+			s.offset = e.Offset
+		}
+	}
+	return s
+}
+
+func TestBuildRuleset(t *testing.T) {
+	var (
+		output      = *nftables.ChainHookOutput
+		input       = *nftables.ChainHookInput
+		forward     = *nftables.ChainHookForward
+		prerouting  = *nftables.ChainHookPrerouting
+		postrouting = *nftables.ChainHookPostrouting
+	)
+	request := func(hook nftables.ChainHook, key expr.MetaKey) ruleSummary {
+		return ruleSummary{hook: hook, key: key, l4proto: unix.IPPROTO_UDP, offset: udpDestPortOffset}
+	}
+	response := func(hook nftables.ChainHook, key expr.MetaKey) ruleSummary {
+		return ruleSummary{hook: hook, key: key, l4proto: unix.IPPROTO_UDP, offset: udpSourcePortOffset}
+	}
+	redirect := func(hook nftables.ChainHook, key expr.MetaKey, l4proto byte) ruleSummary {
+		return ruleSummary{hook: hook, key: key, l4proto: l4proto, offset: udpDestPortOffset, skipProxy: true}
+	}
+
+	tests := []struct {
+		name      string
+		mode      SpoofMode
+		scope     Scope
+		hook      Hook
+		chainType nftables.ChainType
+		want      []ruleSummary
+	}{
+		{"aggressive local", Aggressive, Local, HookAuto, nftables.ChainTypeFilter,
+			[]ruleSummary{request(output, expr.MetaKeyOIF)}},
+		{"aggressive remote", Aggressive, Remote, HookAuto, nftables.ChainTypeFilter,
+			[]ruleSummary{request(forward, expr.MetaKeyOIF)}},
+		{"aggressive prerouting", Aggressive, Remote, HookPrerouting, nftables.ChainTypeFilter,
+			[]ruleSummary{request(prerouting, expr.MetaKeyIIF)}},
+		{"aggressive postrouting", Aggressive, Local, HookPostrouting, nftables.ChainTypeFilter,
+			[]ruleSummary{request(postrouting, expr.MetaKeyOIF)}},
+		{"passive local", Passive, Local, HookAuto, nftables.ChainTypeFilter,
+			[]ruleSummary{response(input, expr.MetaKeyIIF)}},
+		{"passive remote", Passive, Remote, HookAuto, nftables.ChainTypeFilter,
+			[]ruleSummary{response(forward, expr.MetaKeyIIF)}},
+		{"passive prerouting", Passive, Local, HookPrerouting, nftables.ChainTypeFilter,
+			[]ruleSummary{response(prerouting, expr.MetaKeyIIF)}},
+		{"passive postrouting", Passive, Remote, HookPostrouting, nftables.ChainTypeFilter,
+			[]ruleSummary{response(postrouting, expr.MetaKeyOIF)}},
+		{"hybrid local", Hybrid, Local, HookAuto, nftables.ChainTypeFilter,
+			[]ruleSummary{request(output, expr.MetaKeyOIF), response(input, expr.MetaKeyIIF)}},
+		{"hybrid remote", Hybrid, Remote, HookAuto, nftables.ChainTypeFilter,
+			[]ruleSummary{request(forward, expr.MetaKeyOIF), response(forward, expr.MetaKeyIIF)}},
+		{"hybrid prerouting", Hybrid, Remote, HookPrerouting, nftables.ChainTypeFilter,
+			[]ruleSummary{request(prerouting, expr.MetaKeyIIF), response(prerouting, expr.MetaKeyIIF)}},
+		{"proxy local", Proxy, Local, HookAuto, nftables.ChainTypeNAT,
+			[]ruleSummary{redirect(output, expr.MetaKeyOIF, unix.IPPROTO_UDP), redirect(output, expr.MetaKeyOIF, unix.IPPROTO_TCP)}},
+		{"proxy remote", Proxy, Remote, HookAuto, nftables.ChainTypeNAT,
+			[]ruleSummary{redirect(prerouting, expr.MetaKeyIIF, unix.IPPROTO_UDP), redirect(prerouting, expr.MetaKeyIIF, unix.IPPROTO_TCP)}},
+		{"proxy prerouting", Proxy, Local, HookPrerouting, nftables.ChainTypeNAT,
+			[]ruleSummary{redirect(prerouting, expr.MetaKeyIIF, unix.IPPROTO_UDP), redirect(prerouting, expr.MetaKeyIIF, unix.IPPROTO_TCP)}},
+	}
+
+	for _, tt := range tests {
+		for _, ip := range []struct {
+			mode    IPMode
+			nfproto byte
+		}{{IPv4Only, unix.NFPROTO_IPV4}, {IPv6Only, unix.NFPROTO_IPV6}, {IPv4AndIPv6, 0}} {
+			t.Run(tt.name+"/"+ip.mode.String(), func(t *testing.T) {
+				opts := &Options{IPMode: ip.mode, Iface: testIface, SpoofMode: tt.mode, Scope: tt.scope, Hook: tt.hook, ProxyPort: 10053}
+				rs, err := buildRuleset(context.Background(), opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if rs.attached || rs.table.Family != nftables.TableFamilyINet {
+					t.Errorf("table = %s family %d attached %v, want a new inet table", rs.table.Name, rs.table.Family, rs.attached)
+				}
+				hooks := make(map[nftables.ChainHook]bool)
+				for _, c := range rs.chains {
+					if c.Table != rs.table || c.Type != tt.chainType || *c.Policy != nftables.ChainPolicyAccept {
+						t.Errorf("chain %s = type %s policy %d, want type %s policy accept", c.Name, c.Type, *c.Policy, tt.chainType)
+					}
+					if hooks[*c.Hooknum] {
+						t.Errorf("several chains on hook %s", hookName(c.Hooknum))
+					}
+					hooks[*c.Hooknum] = true
+				}
+
+				var got []ruleSummary
+				for _, r := range rs.rules {
+					if r.Table != rs.table {
+						t.Errorf("rule of table %s, want %s", r.Table.Name, rs.table.Name)
+					}
+					got = append(got, summarize(t, r))
+				}
+				want := make([]ruleSummary, len(tt.want))
+				for i, w := range tt.want {
+					w.nfproto = ip.nfproto
+					want[i] = w
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("rules =\n%+v\nwant\n%+v", got, want)
+				}
+			})
+		}
+	}
+}
+
+func TestBuildRulesetVerdicts(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+		want []expr.Any
+	}{
+		{
+			"queue bypass",
+			Options{Queue: 3},
+			[]expr.Any{&expr.Counter{}, &expr.Queue{Num: 3, Total: 1, Flag: expr.QueueFlagBypass}},
+		},
+		{
+			"fail closed",
+			Options{Queue: 3, FailClosed: true},
+			[]expr.Any{&expr.Counter{}, &expr.Queue{Num: 3, Total: 1}},
+		},
+		{
+			"fanout",
+			Options{Queue: 10, QueueTotal: 4},
+			[]expr.Any{&expr.Counter{}, &expr.Queue{Num: 10, Total: 4, Flag: expr.QueueFlagBypass | expr.QueueFlagFanout}},
+		},
+		{
+			"proxy redirect",
+			Options{SpoofMode: Proxy, ProxyPort: 10053},
+			[]expr.Any{&expr.Counter{}, &expr.Immediate{Register: 1, Data: []byte{0x27, 0x45}}, &expr.Redir{RegisterProtoMin: 1}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Iface = testIface
+			rs, err := buildRuleset(context.Background(), &tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, r := range rs.rules {
+				got := r.Exprs[len(r.Exprs)-len(tt.want):]
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("verdict = %#v, want %#v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestBuildRulesetAttached(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  Options
+		rules int
+		err   error
+	}{
+		{"aggressive", Options{SpoofMode: Aggressive, Scope: Local}, 1, nil},
+		{"hybrid remote", Options{SpoofMode: Hybrid, Scope: Remote}, 2, nil},
+		{"hybrid prerouting", Options{SpoofMode: Hybrid, Scope: Local, Hook: HookPrerouting}, 2, nil},
+		{"hybrid local", Options{SpoofMode: Hybrid, Scope: Local}, 0, ErrAttachedHooks},
+		{"proxy", Options{SpoofMode: Proxy, Scope: Local, ProxyPort: 53}, 2, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Iface = testIface
+			tt.opts.Table, tt.opts.Chain = "inet filter", "forward"
+			rs, err := buildRuleset(context.Background(), &tt.opts)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("buildRuleset() error = %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if !rs.attached || len(rs.chains) != 1 || rs.chains[0].Name != "forward" || rs.table.Name != "filter" {
+				t.Errorf("ruleset = table %s chains %v attached %v, want the forward chain of filter", rs.table.Name, rs.chains, rs.attached)
+			}
+			if len(rs.rules) != tt.rules {
+				t.Errorf("rules = %d, want %d", len(rs.rules), tt.rules)
+			}
+			for _, r := range rs.rules {
+				if r.Chain != rs.chains[0] {
+					t.Errorf("rule in chain %s, want forward", r.Chain.Name)
+				}
+			}
+		})
+	}
+}
+
+func TestBuildRulesetErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+		err  error
+	}{
+		{"missing interface", Options{}, ErrMissingIface},
+		{"ip mode", Options{Iface: testIface, IPMode: 9}, ErrInvalidIPMode},
+		{"spoof mode", Options{Iface: testIface, SpoofMode: 9}, ErrInvalidSpoofMode},
+		{"scope", Options{Iface: testIface, Scope: 9}, ErrInvalidScope},
+		{"hook", Options{Iface: testIface, Hook: 9}, ErrInvalidHook},
+		{"queue range", Options{Iface: testIface, Queue: 65535, QueueTotal: 2}, ErrInvalidQueueRange},
+		{"proxy port", Options{Iface: testIface, SpoofMode: Proxy}, ErrInvalidProxyPort},
+		{"proxy postrouting", Options{Iface: testIface, SpoofMode: Proxy, ProxyPort: 53, Hook: HookPostrouting}, ErrInvalidHook},
+		{"proxy scope", Options{Iface: testIface, SpoofMode: Proxy, ProxyPort: 53, Scope: 9}, ErrInvalidScope},
+		{"table without family", Options{Iface: testIface, Table: "filter", Chain: "forward"}, ErrInvalidTable},
+		{"unknown family", Options{Iface: testIface, Table: "bogus filter", Chain: "forward"}, ErrInvalidTable},
+		{"table without chain", Options{Iface: testIface, Table: "inet filter"}, ErrInvalidTable},
+		{"chain without table", Options{Iface: testIface, Chain: "forward"}, ErrInvalidTable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := buildRuleset(context.Background(), &tt.opts); !errors.Is(err, tt.err) {
+				t.Errorf("buildRuleset() error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	// The table name and comment carry the PID, its start time and a random ID.
+	random := regexp.MustCompile(`_[0-9a-f]{8} |pid=\d+ start=\d+ id=[0-9a-f]{8}`)
+
+	tests := []struct {
+		name string
+		opts Options
+		want string
+	}{
+		{
+			"hybrid local ipv4",
+			Options{IPMode: IPv4Only, SpoofMode: Hybrid, Scope: Local, Queue: 1, Priority: -10},
+			`table inet dnsspoof_hybrid_local_ID {
+	chain dnsspoof_output {
+		type filter hook output priority -10; policy accept;
+		meta nfproto ipv4 oif "eth0" meta l4proto udp udp dport 53 counter queue flags bypass to 1 comment "dnsspoofer OWNER"
+	}
+
+	chain dnsspoof_input {
+		type filter hook input priority -10; policy accept;
+		meta nfproto ipv4 iif "eth0" meta l4proto udp udp sport 53 counter queue flags bypass to 1 comment "dnsspoofer OWNER"
+	}
+}
+`,
+		},
+		{
+			"passive remote fanout",
+			Options{IPMode: IPv4AndIPv6, SpoofMode: Passive, Scope: Remote, QueueTotal: 4, FailClosed: true},
+			`table inet dnsspoof_passive_remote_ID {
+	chain dnsspoof_forward {
+		type filter hook forward priority 0; policy accept;
+		iif "eth0" meta l4proto udp udp sport 53 counter queue flags fanout to 0-3 comment "dnsspoofer OWNER"
+	}
+}
+`,
+		},
+		{
+			"proxy attached",
+			Options{IPMode: IPv6Only, SpoofMode: Proxy, Scope: Remote, ProxyPort: 10053, Table: "ip6 nat", Chain: "prerouting"},
+			`insert rule ip6 nat prerouting meta nfproto ipv6 iif "eth0" meta mark != 0x00646e73 meta l4proto udp udp dport 53 counter redirect to :10053 comment "dnsspoofer OWNER"
+insert rule ip6 nat prerouting meta nfproto ipv6 iif "eth0" meta mark != 0x00646e73 meta l4proto tcp tcp dport 53 counter redirect to :10053 comment "dnsspoofer OWNER"
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Iface = testIface
+			got, err := Render(context.Background(), &tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = random.ReplaceAllStringFunc(got, func(s string) string {
+				if s[0] == '_' {
+					return "_ID "
+				}
+				return "OWNER"
+			})
+			if got != tt.want {
+				t.Errorf("Render() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOwnerComment(t *testing.T) {
+	comment := OwnerComment("abcd1234")
+	pid, start, ok := parseOwner(comment)
+	if !ok {
+		t.Fatalf("parseOwner(%q) failed", comment)
+	}
+	if !alive(pid, start) {
+		t.Errorf("owner of %q reported dead", comment)
+	}
+	if alive(pid, start+1) {
+		t.Errorf("owner with another start time reported alive")
+	}
+	if _, _, ok := parseOwner("added by hand"); ok {
+		t.Error("parseOwner() accepted a foreign comment")
+	}
+}
+
+// kernel fakes the nftables netlink API: it lists its chains and rules, applies the rules of the batches
+// sent to it and records a summary of each batch. The batches listed in fail are applied, then reported as
+// failed, as when the kernel commits a transaction before an error reaches userspace.
+type kernel struct {
+	chains  []*nftables.Chain
+	rules   []kernelRule
+	handle  uint64
+	batches [][]string
+	fail    map[int]bool
+}
+
+// kernelRule is a rule held by kernel.
+type kernelRule struct {
+	table, chain string
+	handle       uint64
+	comment      string
+}
+
+// use makes the package talk to k for the rest of the test.
+func (k *kernel) use(t *testing.T) {
+	t.Helper()
+	prev := newConn
+	newConn = func() (*nftables.Conn, error) { return nftables.New(nftables.WithTestDial(k.dial)) }
+	t.Cleanup(func() { newConn = prev })
+}
+
+// dial answers the netlink messages of a request, k knows of no table.
+func (k *kernel) dial(req []netlink.Message) ([]netlink.Message, error) {
+	if len(req) == 0 {
+		return nil, nil
+	}
+	var replies []netlink.Message
+	switch req[0].Header.Type {
+	case netlink.HeaderType(unix.NFNL_MSG_BATCH_BEGIN):
+		return nil, k.batch(req[1 : len(req)-1])
+	case msgType(unix.NFT_MSG_GETCHAIN):
+		attrs := attributes(req[0])
+		for _, c := range k.chains {
+			if _, ok := attrs[unix.NFTA_CHAIN_NAME]; ok && attrs.str(unix.NFTA_CHAIN_NAME) != c.Name {
+				continue
+			}
+			replies = append(replies, message(unix.NFT_MSG_NEWCHAIN, c.Table.Family, []netlink.Attribute{
+				{Type: unix.NFTA_CHAIN_TABLE, Data: []byte(c.Table.Name + "\x00")},
+				{Type: unix.NFTA_CHAIN_NAME, Data: []byte(c.Name + "\x00")},
+			}))
+		}
+	case msgType(unix.NFT_MSG_GETRULE):
+		attrs := attributes(req[0])
+		for _, r := range k.rules {
+			if r.table != attrs.str(unix.NFTA_RULE_TABLE) || r.chain != attrs.str(unix.NFTA_RULE_CHAIN) {
+				continue
+			}
+			replies = append(replies, message(unix.NFT_MSG_NEWRULE, nftables.TableFamily(req[0].Data[0]), []netlink.Attribute{
+				{Type: unix.NFTA_RULE_TABLE, Data: []byte(r.table + "\x00")},
+				{Type: unix.NFTA_RULE_CHAIN, Data: []byte(r.chain + "\x00")},
+				{Type: unix.NFTA_RULE_HANDLE, Data: binary.BigEndian.AppendUint64(nil, r.handle)},
+				{Type: unix.NFTA_RULE_USERDATA, Data: userdata.AppendString(nil, userdata.TypeComment, r.comment)},
+			}))
+		}
+	}
+
+	for i := range replies {
+		replies[i].Header.Sequence = req[0].Header.Sequence
+	}
+	if req[0].Header.Flags&netlink.Dump == 0 {
+		return replies, nil
+	}
+	for i := range replies {
+		replies[i].Header.Flags |= netlink.Multi
+	}
+	return append(replies, netlink.Message{Header: netlink.Header{
+		Type: netlink.Done, Flags: netlink.Multi, Sequence: req[0].Header.Sequence,
+	}}), nil
+}
+
+// batch applies and records the messages of a batch, with the random table ID and owner comments normalized.
+func (k *kernel) batch(msgs []netlink.Message) error {
+	random := regexp.MustCompile(`_[0-9a-f]{8}$`)
+	var summary []string
+	for _, m := range msgs {
+		attrs := attributes(m)
+		table := random.ReplaceAllString(attrs.str(unix.NFTA_TABLE_NAME), "_ID")
+		switch m.Header.Type {
+		case msgType(unix.NFT_MSG_NEWTABLE):
+			summary = append(summary, "add table "+table)
+		case msgType(unix.NFT_MSG_DELTABLE):
+			summary = append(summary, "delete table "+table)
+		case msgType(unix.NFT_MSG_NEWCHAIN):
+			summary = append(summary, "add chain "+table+" "+attrs.str(unix.NFTA_CHAIN_NAME))
+		case msgType(unix.NFT_MSG_NEWRULE):
+			comment, _ := userdata.GetString(attrs[unix.NFTA_RULE_USERDATA], userdata.TypeComment)
+			k.handle++
+			k.rules = append(k.rules, kernelRule{attrs.str(unix.NFTA_RULE_TABLE), attrs.str(unix.NFTA_RULE_CHAIN), k.handle, comment})
+			op := "insert"
+			if m.Header.Flags&unix.NLM_F_APPEND != 0 {
+				op = "add"
+			}
+			if _, _, ok := parseOwner(comment); ok {
+				comment = "OWNER"
+			}
+			summary = append(summary, fmt.Sprintf("%s rule %s %s comment %q", op, table, attrs.str(unix.NFTA_RULE_CHAIN), comment))
+		case msgType(unix.NFT_MSG_DELRULE):
+			handle := binary.BigEndian.Uint64(attrs[unix.NFTA_RULE_HANDLE])
+			k.rules = slices.DeleteFunc(k.rules, func(r kernelRule) bool { return r.handle == handle })
+			summary = append(summary, fmt.Sprintf("delete rule %s %s handle %d", table, attrs.str(unix.NFTA_RULE_CHAIN), handle))
+		default:
+			summary = append(summary, fmt.Sprintf("unexpected message %#x", m.Header.Type))
+		}
+	}
+
+	k.batches = append(k.batches, summary)
+	if k.fail[len(k.batches)-1] {
+		return unix.EINVAL
+	}
+	return nil
+}
+
+// msgType returns the netlink header type of the nftables message typ.
+func msgType(typ uint16) netlink.HeaderType {
+	return netlink.HeaderType(unix.NFNL_SUBSYS_NFTABLES<<8 | typ)
+}
+
+// message returns the nftables message typ of family with attrs.
+func message(typ uint16, family nftables.TableFamily, attrs []netlink.Attribute) netlink.Message {
+	return netlink.Message{
+		Header: netlink.Header{Type: msgType(typ)},
+		Data:   append([]byte{byte(family), unix.NFNETLINK_V0, 0, 0}, nltest.MustMarshalAttributes(attrs)...),
+	}
+}
+
+// attributes returns the top-level attributes of the nftables message m.
+func attributes(m netlink.Message) nlAttrs {
+	attrs := make(nlAttrs)
+	ad, err := netlink.NewAttributeDecoder(m.Data[4:])
+	if err != nil {
+		return attrs
+	}
+	for ad.Next() {
+		attrs[ad.Type()] = ad.Bytes()
+	}
+	return attrs
+}
+
+// nlAttrs holds netlink attributes by type.
+type nlAttrs map[uint16][]byte
+
+// str returns the string attribute typ, without its terminator.
+func (a nlAttrs) str(typ uint16) string {
+	return strings.TrimSuffix(string(a[typ]), "\x00")
+}
+
+func TestAddDNSQueue(t *testing.T) {
+	k := &kernel{}
+	k.use(t)
+
+	in, err := AddDNSQueue(context.Background(), &Options{IPMode: IPv4AndIPv6, Iface: testIface, SpoofMode: Hybrid, Scope: Local})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rules := in.String(); !strings.HasPrefix(rules, "table inet dnsspoof_hybrid_local_") {
+		t.Errorf("String() = %q, want the installed table", rules)
+	}
+	if err := in.Remove(); err != nil {
+		t.Fatal(err)
+	}
+	if err := in.Remove(); err != nil {
+		t.Fatalf("second Remove() error = %v", err)
+	}
+
+	want := [][]string{
+		{
+			"add table dnsspoof_hybrid_local_ID",
+			"add chain dnsspoof_hybrid_local_ID dnsspoof_output",
+			"add chain dnsspoof_hybrid_local_ID dnsspoof_input",
+			`add rule dnsspoof_hybrid_local_ID dnsspoof_output comment "OWNER"`,
+			`add rule dnsspoof_hybrid_local_ID dnsspoof_input comment "OWNER"`,
+		},
+		{"delete table dnsspoof_hybrid_local_ID"},
+	}
+	if !reflect.DeepEqual(k.batches, want) {
+		t.Errorf("batches =\n%q\nwant\n%q", k.batches, want)
+	}
+}
+
+func TestAddDNSQueueRollback(t *testing.T) {
+	t.Run("table", func(t *testing.T) {
+		k := &kernel{fail: map[int]bool{0: true}}
+		k.use(t)
+
+		_, err := AddDNSQueue(context.Background(), &Options{IPMode: IPv4Only, Iface: testIface, SpoofMode: Passive, Scope: Remote})
+		if !errors.Is(err, ErrFlush) {
+			t.Fatalf("AddDNSQueue() error = %v, want %v", err, ErrFlush)
+		}
+		want := [][]string{
+			{
+				"add table dnsspoof_passive_remote_ID",
+				"add chain dnsspoof_passive_remote_ID dnsspoof_forward",
+				`add rule dnsspoof_passive_remote_ID dnsspoof_forward comment "OWNER"`,
+			},
+			{"delete table dnsspoof_passive_remote_ID"},
+		}
+		if !reflect.DeepEqual(k.batches, want) {
+			t.Errorf("batches =\n%q\nwant\n%q", k.batches, want)
+		}
+	})
+
+	t.Run("attached", func(t *testing.T) {
+		filter := &nftables.Table{Name: "filter", Family: nftables.TableFamilyINet}
+		k := &kernel{
+			chains: []*nftables.Chain{{Name: "prerouting", Table: filter}},
+			rules:  []kernelRule{{table: "filter", chain: "prerouting", handle: 1, comment: "added by hand"}},
+			handle: 1,
+			fail:   map[int]bool{0: true},
+		}
+		k.use(t)
+
+		_, err := AddDNSQueue(context.Background(), &Options{
+			IPMode: IPv4AndIPv6, Iface: testIface, SpoofMode: Hybrid, Scope: Local, Hook: HookPrerouting,
+			Table: "inet filter", Chain: "prerouting",
+		})
+		if !errors.Is(err, ErrFlush) {
+			t.Fatalf("AddDNSQueue() error = %v, want %v", err, ErrFlush)
+		}
+		want := [][]string{
+			{
+				`insert rule filter prerouting comment "OWNER"`,
+				`insert rule filter prerouting comment "OWNER"`,
+			},
+			{
+				"delete rule filter prerouting handle 2",
+				"delete rule filter prerouting handle 3",
+			},
+		}
+		if !reflect.DeepEqual(k.batches, want) {
+			t.Errorf("batches =\n%q\nwant\n%q", k.batches, want)
+		}
+		if len(k.rules) != 1 || k.rules[0].handle != 1 {
+			t.Errorf("rules left = %v, want only the rule added by hand", k.rules)
+		}
+	})
+}
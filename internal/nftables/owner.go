@@ -0,0 +1,67 @@
+package nftables
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// OwnerComment returns the rule comment tagging a rule, and its table, as owned by the current process.
+//
+// id tells apart the rules of several engines running in the same process.
+func OwnerComment(id string) string {
+	pid := os.Getpid()
+	start, _ := procStart(pid)
+	return fmt.Sprintf("%s pid=%d start=%d id=%s", ownerTag, pid, start, id)
+}
+
+// Owner returns the PID recorded by OwnerComment and whether that process is gone, ok is false for other comments.
+func Owner(comment string) (pid int, stale bool, ok bool) {
+	pid, start, ok := parseOwner(comment)
+	if !ok {
+		return 0, false, false
+	}
+	return pid, !alive(pid, start), true
+}
+
+// parseOwner returns the PID and start time recorded by OwnerComment, ok is false for other comments.
+func parseOwner(comment string) (pid int, start uint64, ok bool) {
+	rest, ok := strings.CutPrefix(comment, ownerTag+" ")
+	if !ok {
+		return 0, 0, false
+	}
+	if _, err := fmt.Sscanf(rest, "pid=%d start=%d", &pid, &start); err != nil {
+		return 0, 0, false
+	}
+	return pid, start, true
+}
+
+// procStart returns the start time of process pid in clock ticks since boot, from /proc/<pid>/stat.
+func procStart(pid int) (uint64, error) {
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return 0, err
+	}
+
+	// The command name may contain spaces and parentheses, fields are counted from its closing parenthesis.
+	i := strings.LastIndexByte(string(stat), ')')
+	if i < 0 {
+		return 0, ErrParseProcStat
+	}
+	fields := strings.Fields(string(stat[i+1:]))
+	if len(fields) <= procStatStartField {
+		return 0, ErrParseProcStat
+	}
+	return strconv.ParseUint(fields[procStatStartField], 10, 64)
+}
+
+// alive reports whether process pid still runs and, when start is known, is the same process
+// rather than a later one that reused the PID.
+func alive(pid int, start uint64) bool {
+	got, err := procStart(pid)
+	if err != nil {
+		return false
+	}
+	return start == 0 || got == start
+}
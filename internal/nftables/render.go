@@ -0,0 +1,152 @@
+package nftables
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"github.com/google/nftables"
+	"github.com/google/nftables/expr"
+	"github.com/google/nftables/userdata"
+	"golang.org/x/sys/unix"
+)
+
+// Render returns, in nft syntax, the ruleset AddDNSQueue would install for opts, without touching the kernel.
+//
+// The table name and the rule comment end with a random ID that differs on every call.
+//
+// Returns an error if an invalid parameter is provided.
+func Render(ctx context.Context, opts *Options) (string, error) {
+	rs, err := buildRuleset(ctx, opts)
+	if err != nil {
+		return "", err
+	}
+
+	return rs.render(), nil
+}
+
+// render returns the ruleset in nft syntax.
+func (rs *ruleset) render() string {
+	var b strings.Builder
+	if rs.attached {
+		for _, r := range rs.rules {
+			fmt.Fprintf(&b, "insert rule %s %s %s %s\n",
+				familyName(rs.table.Family), rs.table.Name, r.Chain.Name, renderRule(r, rs.iface))
+		}
+		return b.String()
+	}
+
+	fmt.Fprintf(&b, "table %s %s {\n", familyName(rs.table.Family), rs.table.Name)
+	for i, c := range rs.chains {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "\tchain %s {\n", c.Name)
+		fmt.Fprintf(&b, "\t\ttype %s hook %s priority %d; policy %s;\n",
+			c.Type, hookName(c.Hooknum), *c.Priority, policyName(c.Policy))
+		for _, r := range rs.rules {
+			if r.Chain == c {
+				fmt.Fprintf(&b, "\t\t%s\n", renderRule(r, rs.iface))
+			}
+		}
+		b.WriteString("\t}\n")
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// renderRule renders the expressions built by dnsRule, ifaceName being the interface its index refers to.
+func renderRule(r *nftables.Rule, ifaceName string) string {
+	var parts []string
+	l4proto := "udp"
+	for i := 0; i < len(r.Exprs); i++ {
+		switch e := r.Exprs[i].(type) {
+		case *expr.Meta:
+			i++
+			data := r.Exprs[i].(*expr.Cmp).Data
+			switch e.Key {
+			case expr.MetaKeyNFPROTO:
+				parts = append(parts, "meta nfproto "+nfprotoName(data[0]))
+			case expr.MetaKeyIIF:
+				parts = append(parts, fmt.Sprintf("iif %q", ifaceName))
+			case expr.MetaKeyOIF:
+				parts = append(parts, fmt.Sprintf("oif %q", ifaceName))
+			case expr.MetaKeyMARK:
+				parts = append(parts, fmt.Sprintf("meta mark != 0x%08x", binary.NativeEndian.Uint32(data)))
+			case expr.MetaKeyL4PROTO:
+				l4proto = l4protoName(data[0])
+				parts = append(parts, "meta l4proto "+l4proto)
+			}
+		case *expr.Payload:
+			i++
+			port := binary.BigEndian.Uint16(r.Exprs[i].(*expr.Cmp).Data)
+			field := "sport"
+			if e.Offset == udpDestPortOffset {
+				field = "dport"
+			}
+			parts = append(parts, fmt.Sprintf("%s %s %d", l4proto, field, port))
+		case *expr.Counter:
+			parts = append(parts, "counter")
+		case *expr.Queue:
+			parts = append(parts, renderQueue(e))
+		case *expr.Immediate:
+			// The redirect port, loaded for the following expr.Redir.
+			i++
+			parts = append(parts, fmt.Sprintf("redirect to :%d", binary.BigEndian.Uint16(e.Data)))
+		}
+	}
+
+	if comment, ok := userdata.GetString(r.UserData, userdata.TypeComment); ok {
+		parts = append(parts, fmt.Sprintf("comment %q", comment))
+	}
+	return strings.Join(parts, " ")
+}
+
+func renderQueue(q *expr.Queue) string {
+	var flags []string
+	if q.Flag&expr.QueueFlagBypass != 0 {
+		flags = append(flags, "bypass")
+	}
+	if q.Flag&expr.QueueFlagFanout != 0 {
+		flags = append(flags, "fanout")
+	}
+
+	s := "queue"
+	if len(flags) > 0 {
+		s += " flags " + strings.Join(flags, ",")
+	}
+	if q.Total > 1 {
+		return fmt.Sprintf("%s to %d-%d", s, q.Num, q.Num+q.Total-1)
+	}
+	return fmt.Sprintf("%s to %d", s, q.Num)
+}
+
+func nfprotoName(p byte) string {
+	switch p {
+	case unix.NFPROTO_IPV4:
+		return "ipv4"
+	case unix.NFPROTO_IPV6:
+		return "ipv6"
+	default:
+		return fmt.Sprint(p)
+	}
+}
+
+func l4protoName(p byte) string {
+	switch p {
+	case unix.IPPROTO_UDP:
+		return "udp"
+	case unix.IPPROTO_TCP:
+		return "tcp"
+	default:
+		return fmt.Sprint(p)
+	}
+}
+
+func policyName(p *nftables.ChainPolicy) string {
+	if p != nil && *p == nftables.ChainPolicyDrop {
+		return "drop"
+	}
+	return "accept"
+}
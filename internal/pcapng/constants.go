@@ -0,0 +1,29 @@
+package pcapng
+
+import (
+	"os"
+	"sync"
+)
+
+const (
+	blockSectionHeader    = 0x0a0d0d0a
+	blockInterface        = 0x00000001
+	blockEnhancedPacket   = 0x00000006
+	byteOrderMagic        = 0x1a2b3c4d
+	linkTypeRaw           = 101 // packets start at the IP header
+	snapLen               = 0xffff
+	optEndOfOpt           = 0
+	optComment            = 1
+	optIfTsResol          = 9
+	tsResolNanoseconds    = 9
+	sectionLengthUnknown  = 0xffffffffffffffff
+	blockHeaderLen        = 8 // type and total length
+	blockTrailerLen       = 4 // total length
+	enhancedPacketHeadLen = 20
+)
+
+// Writer writes packets with per-packet comments into a pcapng file.
+type Writer struct {
+	mu   sync.Mutex
+	file *os.File
+}
@@ -0,0 +1,9 @@
+package pcapng
+
+import "errors"
+
+var (
+	ErrCreateFile  = errors.New("failed to create pcapng file")
+	ErrWriteHeader = errors.New("failed to write pcapng header")
+	ErrWritePacket = errors.New("failed to write pcapng packet")
+)
@@ -0,0 +1,143 @@
+package pcapng
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/Onyz107/dnsspoofer"
+)
+
+// Create creates the pcapng file at path with a single raw IP interface.
+func Create(path string) (*Writer, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, errors.Join(ErrCreateFile, err)
+	}
+
+	w := &Writer{file: f}
+	if err := w.writeHeader(); err != nil {
+		f.Close()
+		return nil, errors.Join(ErrWriteHeader, err)
+	}
+	return w, nil
+}
+
+// option encodes a pcapng option, padded to 32 bits.
+func option(code uint16, value []byte) []byte {
+	b := binary.LittleEndian.AppendUint16(nil, code)
+	b = binary.LittleEndian.AppendUint16(b, uint16(len(value)))
+	b = append(b, value...)
+	return pad(b)
+}
+
+func pad(b []byte) []byte {
+	for len(b)%4 != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+// writeBlock writes a block of type typ around body.
+//
+// Blocks go straight to the file in a single write, so the capture can be followed while it grows
+// and holds every packet written if the process dies.
+func (w *Writer) writeBlock(typ uint32, body []byte) error {
+	total := uint32(blockHeaderLen + len(body) + blockTrailerLen)
+
+	b := binary.LittleEndian.AppendUint32(nil, typ)
+	b = binary.LittleEndian.AppendUint32(b, total)
+	b = append(b, body...)
+	b = binary.LittleEndian.AppendUint32(b, total)
+
+	_, err := w.file.Write(b)
+	return err
+}
+
+// writeHeader writes the section header and the raw IP interface description.
+func (w *Writer) writeHeader() error {
+	shb := binary.LittleEndian.AppendUint32(nil, byteOrderMagic)
+	shb = binary.LittleEndian.AppendUint16(shb, 1) // major version
+	shb = binary.LittleEndian.AppendUint16(shb, 0) // minor version
+	shb = binary.LittleEndian.AppendUint64(shb, sectionLengthUnknown)
+	shb = append(shb, option(optEndOfOpt, nil)...)
+	if err := w.writeBlock(blockSectionHeader, shb); err != nil {
+		return err
+	}
+
+	idb := binary.LittleEndian.AppendUint16(nil, linkTypeRaw)
+	idb = binary.LittleEndian.AppendUint16(idb, 0) // reserved
+	idb = binary.LittleEndian.AppendUint32(idb, snapLen)
+	idb = append(idb, option(optIfTsResol, []byte{tsResolNanoseconds})...)
+	idb = append(idb, option(optEndOfOpt, nil)...)
+	return w.writeBlock(blockInterface, idb)
+}
+
+// WritePacket writes data, captured at ts, with comment attached.
+func (w *Writer) WritePacket(ts time.Time, data []byte, comment string) error {
+	nanos := uint64(ts.UnixNano())
+
+	epb := make([]byte, 0, enhancedPacketHeadLen+len(data)+len(comment)+16)
+	epb = binary.LittleEndian.AppendUint32(epb, 0) // interface id
+	epb = binary.LittleEndian.AppendUint32(epb, uint32(nanos>>32))
+	epb = binary.LittleEndian.AppendUint32(epb, uint32(nanos))
+	epb = binary.LittleEndian.AppendUint32(epb, uint32(len(data))) // captured length
+	epb = binary.LittleEndian.AppendUint32(epb, uint32(len(data))) // original length
+	epb = pad(append(epb, data...))
+	if comment != "" {
+		epb = append(epb, option(optComment, []byte(comment))...)
+	}
+	epb = append(epb, option(optEndOfOpt, nil)...)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.writeBlock(blockEnhancedPacket, epb); err != nil {
+		return errors.Join(ErrWritePacket, err)
+	}
+	return nil
+}
+
+// WriteEvent writes the packets of ev if it is a final event, see dnsspoofer.EventType.Final:
+// the original queued packet and, for spoofed ones, the forged packet, each commented with the verdict.
+func (w *Writer) WriteEvent(ev dnsspoofer.Event) error {
+	if !ev.Type.Final() {
+		return nil
+	}
+	if len(ev.Packet) == 0 {
+		return nil
+	}
+
+	comment := fmt.Sprintf("original: %s, verdict %s", ev.Type.String(), ev.Verdict)
+	if ev.Type == dnsspoofer.EventSpoof && ev.IsRequest {
+		comment += ", answered by the next forged packet"
+	} else if ev.Type == dnsspoofer.EventSpoof {
+		comment += ", replaced by the next forged packet"
+	}
+	if ev.Err != nil {
+		comment += ", error: " + ev.Err.Error()
+	}
+	if err := w.WritePacket(ev.Time, ev.Packet, comment); err != nil {
+		return err
+	}
+
+	if len(ev.ForgedPacket) > 0 {
+		comment := fmt.Sprintf("forged: verdict %s, rules %v", ev.Verdict, ev.Rules)
+		if ev.IsRequest {
+			// Replies forged from requests skip NFQUEUE, they are sent through a raw socket.
+			comment = fmt.Sprintf("forged: injected, rules %v", ev.Rules)
+		}
+		if err := w.WritePacket(ev.Time, ev.ForgedPacket, comment); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the file.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file.Close()
+}
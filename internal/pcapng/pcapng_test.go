@@ -0,0 +1,56 @@
+package pcapng
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// blockTypes returns the types of the blocks in b.
+func blockTypes(t *testing.T, b []byte) []uint32 {
+	t.Helper()
+	var types []uint32
+	for len(b) > 0 {
+		if len(b) < blockHeaderLen {
+			t.Fatalf("%d trailing bytes", len(b))
+		}
+		total := binary.LittleEndian.Uint32(b[4:8])
+		if int(total) > len(b) {
+			t.Fatalf("block of %d bytes with %d left", total, len(b))
+		}
+		types = append(types, binary.LittleEndian.Uint32(b[:4]))
+		b = b[total:]
+	}
+	return types
+}
+
+func TestWritePacketReadable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.pcapng")
+	w, err := Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	if err := w.WritePacket(time.Now(), make([]byte, 60), "spoofed"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The packet is on disk before Close.
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	types := blockTypes(t, b)
+	want := []uint32{blockSectionHeader, blockInterface, blockEnhancedPacket}
+	if len(types) != len(want) {
+		t.Fatalf("blocks = %#x, want %#x", types, want)
+	}
+	for i := range want {
+		if types[i] != want[i] {
+			t.Errorf("block %d = %#x, want %#x", i, types[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package proxy
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// maxMessageLen is the largest DNS message, bounded by the TCP length prefix.
+	maxMessageLen = 0xffff
+	// exchangeTimeout bounds an upstream exchange when the context has no deadline.
+	exchangeTimeout = 5 * time.Second
+	// tcpIdleTimeout closes client TCP connections idle for that long.
+	tcpIdleTimeout = 10 * time.Second
+)
+
+// Handler answers the DNS message req sent by client, a nil answer sends nothing back.
+type Handler func(ctx context.Context, client net.Addr, req []byte) []byte
+
+// Server is a DNS server listening on UDP and TCP.
+type Server struct {
+	udp     []net.PacketConn
+	tcp     []net.Listener
+	handler Handler
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// Forwarder resolves DNS messages through an upstream server, caching the answers.
+type Forwarder struct {
+	upstream string
+	mark     int
+	cache    *cache
+}
+
+// cache holds upstream responses until their lowest TTL expires.
+type cache struct {
+	mu      sync.Mutex
+	size    int
+	entries map[cacheKey]cacheEntry
+}
+
+type cacheKey struct {
+	name   string
+	qtype  uint16
+	qclass uint16
+	// do is the EDNS DNSSEC OK bit, the answer only carries signatures when set
+	do bool
+}
+
+type cacheEntry struct {
+	msg     []byte
+	stored  time.Time
+	expires time.Time
+}
@@ -0,0 +1,12 @@
+package proxy
+
+import "errors"
+
+var (
+	ErrListenUDP       = errors.New("failed to listen on UDP")
+	ErrListenTCP       = errors.New("failed to listen on TCP")
+	ErrInvalidUpstream = errors.New("upstream must be an IP address with an optional port")
+	ErrDialUpstream    = errors.New("failed to connect to the upstream server")
+	ErrExchange        = errors.New("failed to exchange with the upstream server")
+	ErrInvalidResponse = errors.New("invalid upstream response")
+)
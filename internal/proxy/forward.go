@@ -0,0 +1,199 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Onyz107/dnsspoofer/internal/dns"
+	"github.com/google/gopacket/layers"
+	"golang.org/x/sys/unix"
+)
+
+// NewForwarder creates a Forwarder to upstream, an IP with an optional port defaulting to 53.
+//
+// A hostname is refused: resolving it would send DNS requests the proxy rules redirect back to the proxy.
+// The upstream queries carry the packet mark when it is not 0, and up to cacheSize answers are cached,
+// none if it is not positive.
+func NewForwarder(upstream string, mark, cacheSize int) (*Forwarder, error) {
+	host, port, err := net.SplitHostPort(upstream)
+	if err != nil {
+		host, port = strings.Trim(upstream, "[]"), "53"
+	}
+	if net.ParseIP(host) == nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidUpstream, upstream)
+	}
+	f := &Forwarder{upstream: net.JoinHostPort(host, port), mark: mark}
+	if cacheSize > 0 {
+		f.cache = &cache{size: cacheSize, entries: make(map[cacheKey]cacheEntry)}
+	}
+	return f, nil
+}
+
+// Upstream returns the address of the upstream server.
+func (f *Forwarder) Upstream() string {
+	return f.upstream
+}
+
+// Exchange returns the upstream response to the DNS message req, from the cache if it holds one.
+//
+// Truncated UDP responses are fetched again over TCP.
+func (f *Forwarder) Exchange(ctx context.Context, req []byte) ([]byte, error) {
+	now := time.Now()
+	key, cacheable := keyOf(req)
+	cacheable = cacheable && f.cache != nil
+	if cacheable {
+		if res, ok := f.cache.get(key, now); ok {
+			copy(res, req[:2]) // transaction ID
+			return res, nil
+		}
+	}
+
+	res, err := f.exchange(ctx, "udp", req)
+	if err == nil && dns.Truncated(res) {
+		res, err = f.exchange(ctx, "tcp", req)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if cacheable {
+		f.cache.put(key, res, now)
+	}
+	return res, nil
+}
+
+func (f *Forwarder) exchange(ctx context.Context, network string, req []byte) ([]byte, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, exchangeTimeout)
+		defer cancel()
+	}
+
+	dialer := net.Dialer{Control: f.control}
+	conn, err := dialer.DialContext(ctx, network, f.upstream)
+	if err != nil {
+		return nil, errors.Join(ErrDialUpstream, err)
+	}
+	defer conn.Close()
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+
+	if network == "tcp" {
+		if err := writeMsg(conn, req); err != nil {
+			return nil, errors.Join(ErrExchange, err)
+		}
+		res, err := readMsg(conn)
+		if err != nil {
+			return nil, errors.Join(ErrExchange, err)
+		}
+		if len(res) < 2 || res[0] != req[0] || res[1] != req[1] {
+			return nil, ErrInvalidResponse
+		}
+		return res, nil
+	}
+
+	if _, err := conn.Write(req); err != nil {
+		return nil, errors.Join(ErrExchange, err)
+	}
+	buf := make([]byte, maxMessageLen)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, errors.Join(ErrExchange, err)
+		}
+		// Late answers to earlier queries from the same port are skipped.
+		if n >= 2 && buf[0] == req[0] && buf[1] == req[1] {
+			return slices.Clone(buf[:n]), nil
+		}
+	}
+}
+
+// control marks the upstream sockets so the redirect rules leave them alone.
+func (f *Forwarder) control(network, address string, c syscall.RawConn) error {
+	if f.mark == 0 {
+		return nil
+	}
+	var err error
+	if cerr := c.Control(func(fd uintptr) {
+		err = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_MARK, f.mark)
+	}); cerr != nil {
+		return cerr
+	}
+	return err
+}
+
+// keyOf returns the cache key of the request req, false if it cannot be cached.
+func keyOf(req []byte) (cacheKey, bool) {
+	d, err := dns.ParseMessage(req)
+	if err != nil || len(d.Questions) != 1 {
+		return cacheKey{}, false
+	}
+	q := d.Questions[0]
+	key := cacheKey{name: strings.ToLower(string(q.Name)), qtype: uint16(q.Type), qclass: uint16(q.Class)}
+	for _, rr := range d.Additionals {
+		if rr.Type == layers.DNSTypeOPT {
+			key.do = rr.TTL&0x8000 != 0
+		}
+	}
+	return key, true
+}
+
+// get returns a copy of the response cached for key with its TTLs aged, false if there is none.
+func (c *cache) get(key cacheKey, now time.Time) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if !now.Before(entry.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	res := slices.Clone(entry.msg)
+	if err := dns.AgeTTLs(res, uint32(now.Sub(entry.stored)/time.Second)); err != nil {
+		return nil, false
+	}
+	return res, true
+}
+
+// put caches the successful or NXDOMAIN response res until its lowest TTL expires.
+func (c *cache) put(key cacheKey, res []byte, now time.Time) {
+	if len(res) < 4 {
+		return
+	}
+	if rcode := layers.DNSResponseCode(res[3] & 0x0f); rcode != layers.DNSResponseCodeNoErr && rcode != layers.DNSResponseCodeNXDomain {
+		return
+	}
+	ttl, ok := dns.MinTTL(res)
+	if !ok || ttl == 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= c.size {
+		for k, e := range c.entries {
+			if !now.Before(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+	}
+	if len(c.entries) >= c.size {
+		for k := range c.entries {
+			delete(c.entries, k)
+			break
+		}
+	}
+	c.entries[key] = cacheEntry{
+		msg:     slices.Clone(res),
+		stored:  now,
+		expires: now.Add(time.Duration(ttl) * time.Second),
+	}
+}
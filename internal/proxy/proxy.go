@@ -0,0 +1,164 @@
+package proxy
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"slices"
+	"time"
+
+	"github.com/Onyz107/dnsspoofer/internal/dns"
+)
+
+// Listen starts a DNS server answering with handler on the UDP and TCP addresses addrs, until ctx is done or
+// Close is called.
+//
+// UDP answers larger than the client accepts are truncated so it retries over TCP.
+func Listen(ctx context.Context, addrs []string, handler Handler) (*Server, error) {
+	s := &Server{handler: handler}
+	for _, addr := range addrs {
+		udp, err := net.ListenPacket("udp", addr)
+		if err != nil {
+			s.closeListeners()
+			return nil, errors.Join(ErrListenUDP, err)
+		}
+		s.udp = append(s.udp, udp)
+		// The same port as UDP, even when addr asks for any port.
+		tcp, err := net.Listen("tcp", udp.LocalAddr().String())
+		if err != nil {
+			s.closeListeners()
+			return nil, errors.Join(ErrListenTCP, err)
+		}
+		s.tcp = append(s.tcp, tcp)
+	}
+
+	s.ctx, s.cancel = context.WithCancel(ctx)
+	context.AfterFunc(s.ctx, s.closeListeners)
+
+	s.wg.Add(len(s.udp) + len(s.tcp))
+	for _, udp := range s.udp {
+		go s.serveUDP(udp)
+	}
+	for _, tcp := range s.tcp {
+		go s.serveTCP(tcp)
+	}
+	return s, nil
+}
+
+// Addrs returns the UDP addresses s listens on, the TCP ones being the same.
+func (s *Server) Addrs() []net.Addr {
+	addrs := make([]net.Addr, 0, len(s.udp))
+	for _, udp := range s.udp {
+		addrs = append(addrs, udp.LocalAddr())
+	}
+	return addrs
+}
+
+// Close stops the server and waits for the pending answers.
+func (s *Server) Close() error {
+	s.cancel()
+	s.wg.Wait()
+	return nil
+}
+
+func (s *Server) closeListeners() {
+	for _, udp := range s.udp {
+		udp.Close()
+	}
+	for _, tcp := range s.tcp {
+		tcp.Close()
+	}
+}
+
+func (s *Server) serveUDP(udp net.PacketConn) {
+	defer s.wg.Done()
+	buf := make([]byte, maxMessageLen)
+	for {
+		n, client, err := udp.ReadFrom(buf)
+		if err != nil {
+			if s.ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+
+		req := slices.Clone(buf[:n])
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			res := s.handler(s.ctx, client, req)
+			if res == nil {
+				return
+			}
+			if len(res) > dns.UDPSize(req) {
+				if truncated, err := dns.Truncate(res); err == nil {
+					res = truncated
+				}
+			}
+			udp.WriteTo(res, client)
+		}()
+	}
+}
+
+func (s *Server) serveTCP(tcp net.Listener) {
+	defer s.wg.Done()
+	for {
+		conn, err := tcp.Accept()
+		if err != nil {
+			if s.ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.serveConn(conn)
+		}()
+	}
+}
+
+// serveConn answers the queries of a client TCP connection, one after the other, until it idles.
+func (s *Server) serveConn(conn net.Conn) {
+	defer conn.Close()
+	stop := context.AfterFunc(s.ctx, func() { conn.Close() })
+	defer stop()
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(tcpIdleTimeout))
+		req, err := readMsg(conn)
+		if err != nil {
+			return
+		}
+		res := s.handler(s.ctx, conn.RemoteAddr(), req)
+		if res == nil {
+			continue
+		}
+		conn.SetWriteDeadline(time.Now().Add(tcpIdleTimeout))
+		if err := writeMsg(conn, res); err != nil {
+			return
+		}
+	}
+}
+
+// readMsg reads a DNS message prefixed with its length, as sent over TCP.
+func readMsg(r io.Reader) ([]byte, error) {
+	var length [2]byte
+	if _, err := io.ReadFull(r, length[:]); err != nil {
+		return nil, err
+	}
+	msg := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// writeMsg writes msg prefixed with its length, as sent over TCP.
+func writeMsg(w io.Writer, msg []byte) error {
+	_, err := w.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(msg))), msg...))
+	return err
+}
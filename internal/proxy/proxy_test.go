@@ -0,0 +1,308 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// bigName gets a truncated answer over UDP and bigAnswers records over TCP from the stub upstream.
+const (
+	bigName    = "big.example.com"
+	bigAnswers = 64
+)
+
+// stub is an upstream DNS server on loopback counting the queries it answers over UDP and TCP.
+type stub struct {
+	addr     string
+	udpCount atomic.Int32
+	tcpCount atomic.Int32
+}
+
+func newStub(t *testing.T) *stub {
+	t.Helper()
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { udp.Close() })
+	tcp, err := net.Listen("tcp", udp.LocalAddr().String())
+	if err != nil {
+		t.Skipf("TCP port of the stub upstream taken: %v", err)
+	}
+	t.Cleanup(func() { tcp.Close() })
+
+	s := &stub{addr: udp.LocalAddr().String()}
+	go func() {
+		buf := make([]byte, maxMessageLen)
+		for {
+			n, client, err := udp.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			s.udpCount.Add(1)
+			udp.WriteTo(s.answer(t, buf[:n], true), client)
+		}
+	}()
+	go func() {
+		for {
+			conn, err := tcp.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				req, err := readMsg(conn)
+				if err != nil {
+					return
+				}
+				s.tcpCount.Add(1)
+				writeMsg(conn, s.answer(t, req, false))
+			}()
+		}
+	}()
+	return s
+}
+
+// answer returns the response of the stub to req, truncated if udp and req asks for bigName.
+func (s *stub) answer(t *testing.T, req []byte, udp bool) []byte {
+	var d layers.DNS
+	if err := d.DecodeFromBytes(req, gopacket.NilDecodeFeedback); err != nil {
+		t.Error(err)
+		return nil
+	}
+	d.QR, d.RA = true, true
+	d.Additionals = nil
+	q := d.Questions[0]
+	switch {
+	case string(q.Name) == bigName && udp:
+		d.TC = true
+	case string(q.Name) == bigName:
+		for i := range bigAnswers {
+			d.Answers = append(d.Answers, layers.DNSResourceRecord{
+				Name: q.Name, Type: layers.DNSTypeA, Class: layers.DNSClassIN, TTL: 300, IP: net.IPv4(10, 0, 1, byte(i)),
+			})
+		}
+	default:
+		d.Answers = []layers.DNSResourceRecord{{
+			Name: q.Name, Type: layers.DNSTypeA, Class: layers.DNSClassIN, TTL: 300, IP: net.IPv4(10, 0, 0, 1),
+		}}
+	}
+	return serialize(t, &d)
+}
+
+func serialize(t *testing.T, d *layers.DNS) []byte {
+	buf := gopacket.NewSerializeBuffer()
+	if err := d.SerializeTo(buf, gopacket.SerializeOptions{FixLengths: true}); err != nil {
+		t.Error(err)
+		return nil
+	}
+	return buf.Bytes()
+}
+
+// query returns an A query for name with the transaction ID id.
+func query(t *testing.T, id uint16, name string) []byte {
+	t.Helper()
+	return serialize(t, &layers.DNS{
+		ID:        id,
+		RD:        true,
+		Questions: []layers.DNSQuestion{{Name: []byte(name), Type: layers.DNSTypeA, Class: layers.DNSClassIN}},
+	})
+}
+
+func parse(t *testing.T, msg []byte) *layers.DNS {
+	t.Helper()
+	var d layers.DNS
+	if err := d.DecodeFromBytes(msg, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	return &d
+}
+
+func TestNewForwarder(t *testing.T) {
+	tests := []struct {
+		upstream string
+		want     string
+		err      error
+	}{
+		{"1.1.1.1", "1.1.1.1:53", nil},
+		{"1.1.1.1:5353", "1.1.1.1:5353", nil},
+		{"2606:4700:4700::1111", "[2606:4700:4700::1111]:53", nil},
+		{"[2606:4700:4700::1111]", "[2606:4700:4700::1111]:53", nil},
+		{"[::1]:5353", "[::1]:5353", nil},
+		{"dns.google", "", ErrInvalidUpstream},
+		{"dns.google:53", "", ErrInvalidUpstream},
+	}
+	for _, tt := range tests {
+		t.Run(tt.upstream, func(t *testing.T) {
+			f, err := NewForwarder(tt.upstream, 0, 0)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("NewForwarder() error = %v, want %v", err, tt.err)
+			}
+			if err == nil && f.Upstream() != tt.want {
+				t.Errorf("Upstream() = %s, want %s", f.Upstream(), tt.want)
+			}
+		})
+	}
+}
+
+func TestExchange(t *testing.T) {
+	up := newStub(t)
+	f, err := NewForwarder(up.addr, 0, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	res, err := f.Exchange(ctx, query(t, 1, "example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := parse(t, res); d.ID != 1 || len(d.Answers) != 1 || !d.Answers[0].IP.Equal(net.IPv4(10, 0, 0, 1)) {
+		t.Errorf("answer = id %d %v", d.ID, d.Answers)
+	}
+
+	// Served from the cache with the ID of the new request.
+	res, err = f.Exchange(ctx, query(t, 2, "EXAMPLE.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := parse(t, res); d.ID != 2 || len(d.Answers) != 1 {
+		t.Errorf("cached answer = id %d %v", d.ID, d.Answers)
+	}
+	if n := up.udpCount.Load(); n != 1 {
+		t.Errorf("upstream UDP queries = %d, want 1 with the second answered from the cache", n)
+	}
+
+	// Truncated over UDP, fetched again over TCP.
+	res, err = f.Exchange(ctx, query(t, 3, bigName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := parse(t, res); d.TC || len(d.Answers) != bigAnswers {
+		t.Errorf("big answer = tc %v, %d records, want %d", d.TC, len(d.Answers), bigAnswers)
+	}
+	if n := up.tcpCount.Load(); n != 1 {
+		t.Errorf("upstream TCP queries = %d, want 1", n)
+	}
+}
+
+func TestExchangeUnreachable(t *testing.T) {
+	// Nothing listens on the port of a closed socket.
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := conn.LocalAddr().String()
+	conn.Close()
+
+	f, err := NewForwarder(addr, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := f.Exchange(ctx, query(t, 1, "example.com")); !errors.Is(err, ErrExchange) {
+		t.Errorf("Exchange() error = %v, want %v", err, ErrExchange)
+	}
+}
+
+func TestServer(t *testing.T) {
+	up := newStub(t)
+	f, err := NewForwarder(up.addr, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := func(ctx context.Context, client net.Addr, req []byte) []byte {
+		if parse(t, req).Questions[0].Name[0] == 'd' { // dropped.example.com
+			return nil
+		}
+		res, err := f.Exchange(ctx, req)
+		if err != nil {
+			t.Error(err)
+			return nil
+		}
+		return res
+	}
+
+	srv, err := Listen(context.Background(), []string{"127.0.0.1:0"}, handler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	addr := srv.Addrs()[0].String()
+
+	udp, err := net.Dial("udp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer udp.Close()
+	udp.SetDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, maxMessageLen)
+
+	udp.Write(query(t, 1, "example.com"))
+	n, err := udp.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := parse(t, buf[:n]); d.ID != 1 || len(d.Answers) != 1 {
+		t.Errorf("UDP answer = id %d %v", d.ID, d.Answers)
+	}
+
+	// Larger than the 512 bytes of a client without EDNS: truncated so it retries over TCP.
+	udp.Write(query(t, 2, bigName))
+	n, err = udp.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := parse(t, buf[:n]); !d.TC || len(d.Answers) != 0 || n > 512 {
+		t.Errorf("UDP big answer = tc %v, %d records, %d bytes", d.TC, len(d.Answers), n)
+	}
+
+	tcp, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tcp.Close()
+	tcp.SetDeadline(time.Now().Add(2 * time.Second))
+	// A dropped query gets no answer, the next one on the connection still does.
+	if err := writeMsg(tcp, query(t, 3, "dropped.example.com")); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeMsg(tcp, query(t, 4, bigName)); err != nil {
+		t.Fatal(err)
+	}
+	res, err := readMsg(tcp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := parse(t, res); d.ID != 4 || d.TC || len(d.Answers) != bigAnswers {
+		t.Errorf("TCP answer = id %d tc %v, %d records", d.ID, d.TC, len(d.Answers))
+	}
+}
+
+func TestListenReleasesOnError(t *testing.T) {
+	taken, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taken.Close()
+	addr := taken.Addr().String()
+
+	if _, err := Listen(context.Background(), []string{addr}, nil); !errors.Is(err, ErrListenTCP) {
+		t.Fatalf("Listen() on a taken TCP port error = %v, want %v", err, ErrListenTCP)
+	}
+	// The UDP socket of the failed Listen was closed.
+	udp, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		t.Errorf("UDP port still held after a failed Listen: %v", err)
+	} else {
+		udp.Close()
+	}
+}
@@ -0,0 +1,44 @@
+package querylog
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Onyz107/dnsspoofer"
+)
+
+const (
+	// DefaultMaxSize is the default size in bytes a query log grows to before being rotated.
+	DefaultMaxSize = 100 << 20
+	// DefaultMaxBackups is the default number of rotated query logs kept.
+	DefaultMaxBackups = 5
+)
+
+// Writer writes one JSON line per DNS transaction to a size-rotated file.
+type Writer struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// Entry is one line of the query log.
+type Entry struct {
+	Time      time.Time           `json:"time"`
+	Client    string              `json:"client,omitempty"`
+	Server    string              `json:"server,omitempty"`
+	Name      string              `json:"qname,omitempty"`
+	QType     string              `json:"qtype,omitempty"`
+	IsRequest bool                `json:"request"`
+	Original  []dnsspoofer.Record `json:"original,omitempty"`
+	Spoofed   []dnsspoofer.Record `json:"spoofed,omitempty"`
+	Rules     []string            `json:"rules,omitempty"`
+	Action    string              `json:"action"`
+	Verdict   string              `json:"verdict,omitempty"`
+	LatencyMS float64             `json:"latency_ms"`
+	Error     string              `json:"error,omitempty"`
+}
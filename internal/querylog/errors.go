@@ -0,0 +1,9 @@
+package querylog
+
+import "errors"
+
+var (
+	ErrOpenLog   = errors.New("failed to open query log")
+	ErrRotateLog = errors.New("failed to rotate query log")
+	ErrWriteLog  = errors.New("failed to write query log")
+)
@@ -0,0 +1,129 @@
+package querylog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/Onyz107/dnsspoofer"
+)
+
+// Open opens or creates the query log at path.
+//
+// Once the file would grow beyond maxSize bytes it is renamed to path.1, path.1 to path.2 and so on,
+// keeping at most maxBackups old files. Zero values use DefaultMaxSize and DefaultMaxBackups.
+func Open(path string, maxSize int64, maxBackups int) (*Writer, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSize
+	}
+	if maxBackups <= 0 {
+		maxBackups = DefaultMaxBackups
+	}
+
+	w := &Writer{
+		path:       path,
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *Writer) open() error {
+	f, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return errors.Join(ErrOpenLog, err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return errors.Join(ErrOpenLog, err)
+	}
+	w.file = f
+	w.size = info.Size()
+	return nil
+}
+
+// rotate shifts the backups, moves the current file to path.1 and opens a fresh one.
+//
+// If the file cannot be moved, it is opened again so that later writes keep appending to it.
+func (w *Writer) rotate() error {
+	err := w.file.Close()
+	if err == nil {
+		for i := w.maxBackups - 1; i >= 1; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", w.path, i), fmt.Sprintf("%s.%d", w.path, i+1))
+		}
+		err = os.Rename(w.path, w.path+".1")
+	}
+	if err != nil {
+		return errors.Join(ErrRotateLog, err, w.open())
+	}
+
+	return w.open()
+}
+
+// Write appends entry as one JSON line, rotating the file first if needed.
+func (w *Writer) Write(entry *Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return errors.Join(ErrWriteLog, err)
+	}
+	line = append(line, '\n')
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// A failed rotation is reported, the entry is still written to the current file.
+	var rotateErr error
+	if w.size > 0 && w.size+int64(len(line)) > w.maxSize {
+		rotateErr = w.rotate()
+	}
+
+	n, err := w.file.Write(line)
+	w.size += int64(n)
+	if err != nil {
+		return errors.Join(rotateErr, ErrWriteLog, err)
+	}
+	return rotateErr
+}
+
+// WriteEvent logs ev if it is a final event, see dnsspoofer.EventType.Final, other events are ignored.
+func (w *Writer) WriteEvent(ev dnsspoofer.Event) error {
+	if !ev.Type.Final() {
+		return nil
+	}
+
+	entry := &Entry{
+		Time:      ev.Time,
+		Name:      ev.Name,
+		QType:     ev.QType,
+		IsRequest: ev.IsRequest,
+		Original:  ev.Original,
+		Spoofed:   ev.Forged,
+		Rules:     ev.Rules,
+		Action:    ev.Type.String(),
+		Verdict:   ev.Verdict,
+		LatencyMS: float64(ev.Latency.Microseconds()) / 1000,
+	}
+	if ev.Client != nil {
+		entry.Client = ev.Client.String()
+	}
+	if ev.Server != nil {
+		entry.Server = ev.Server.String()
+	}
+	if ev.Err != nil {
+		entry.Error = ev.Err.Error()
+	}
+
+	return w.Write(entry)
+}
+
+// Close closes the current file.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file.Close()
+}
@@ -0,0 +1,126 @@
+package querylog
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Onyz107/dnsspoofer"
+)
+
+// entries returns the names logged in the file at path.
+func entries(t *testing.T, path string) []string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var names []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var e Entry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		names = append(names, e.Name)
+	}
+	return names
+}
+
+// entry returns the i-th entry, of the name n<letter i>.example.com, all of the same length.
+func entry(i int) *Entry {
+	return &Entry{Name: "n" + string(rune('a'+i)) + ".example.com", Action: "spoof"}
+}
+
+func TestRotate(t *testing.T) {
+	line, _ := json.Marshal(entry(0))
+	lineLen := int64(len(line)) + 1
+
+	path := filepath.Join(t.TempDir(), "queries.jsonl")
+	w, err := Open(path, 2*lineLen, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	// Two lines per file, the oldest two files beyond the backups are gone.
+	for i := range 7 {
+		if err := w.Write(entry(i)); err != nil {
+			t.Fatalf("Write(%d) error = %v", i, err)
+		}
+	}
+
+	want := map[string]string{
+		path:        "ng.example.com",
+		path + ".1": "ne.example.com nf.example.com",
+		path + ".2": "nc.example.com nd.example.com",
+	}
+	for file, names := range want {
+		if got := strings.Join(entries(t, file), " "); got != names {
+			t.Errorf("%s holds %q, want %q", filepath.Base(file), got, names)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("backup beyond the limit: %v", err)
+	}
+}
+
+func TestRotateReopen(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "queries.jsonl")
+	w, err := Open(path, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if err := w.Write(entry(0)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The file cannot be moved over a non-empty directory.
+	if err := os.MkdirAll(filepath.Join(path+".1", "taken"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(entry(1)); !errors.Is(err, ErrRotateLog) {
+		t.Fatalf("Write() error = %v, want %v", err, ErrRotateLog)
+	}
+	if err := os.RemoveAll(path + ".1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Later writes rotate again and the entries written meanwhile were kept.
+	if err := w.Write(entry(2)); err != nil {
+		t.Fatalf("Write() after a failed rotation error = %v", err)
+	}
+	if got := strings.Join(entries(t, path+".1"), " "); got != "na.example.com nb.example.com" {
+		t.Errorf("rotated file holds %q", got)
+	}
+	if got := strings.Join(entries(t, path), " "); got != "nc.example.com" {
+		t.Errorf("current file holds %q", got)
+	}
+}
+
+func TestWriteEvent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queries.jsonl")
+	w, err := Open(path, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	// Only the events ending the handling of a packet are logged.
+	for _, typ := range []dnsspoofer.EventType{dnsspoofer.EventQuery, dnsspoofer.EventMatch, dnsspoofer.EventSpoof, dnsspoofer.EventDrop} {
+		if err := w.WriteEvent(dnsspoofer.Event{Type: typ, Name: typ.String()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := strings.Join(entries(t, path), " "); got != "spoof drop" {
+		t.Errorf("logged %q, want the spoof and the drop", got)
+	}
+}
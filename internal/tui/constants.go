@@ -0,0 +1,60 @@
+package tui
+
+import (
+	"time"
+
+	"github.com/Onyz107/dnsspoofer"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	// refresh is how often the statistics are polled from the engine.
+	refresh = 500 * time.Millisecond
+	// recentRows is the number of queries kept in the rolling list.
+	recentRows = 15
+	// topRows is the number of rows of the top lists.
+	topRows = 8
+	// backlog is the number of events buffered for the dashboard before new ones are dropped.
+	backlog = 1024
+	// columnWidth is the width of the top lists.
+	columnWidth = 30
+	// maxNames is the number of names counted for the top list, the least queried one is forgotten
+	// to make room for a new one.
+	maxNames = 1024
+	// rulesWidth is the width the lines of the firewall panel are truncated to.
+	rulesWidth = 4 * (columnWidth + 4)
+)
+
+var (
+	titleStyle  = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("53")).Background(lipgloss.Color("219")).Padding(0, 1)
+	headerStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("219"))
+	spoofStyle  = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("205"))
+	dropStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("208"))
+	errorStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
+	dimStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("245"))
+	okStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("42"))
+	panelStyle  = lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).BorderForeground(lipgloss.Color("53")).Padding(0, 1)
+	helpStyle   = dimStyle.Italic(true)
+)
+
+// Dashboard is a live terminal view of an engine.
+type Dashboard struct {
+	engine *dnsspoofer.Engine
+	events chan dnsspoofer.Event
+}
+
+// model is the bubbletea state of the dashboard.
+type model struct {
+	dashboard *Dashboard
+
+	stats  dnsspoofer.Stats
+	recent []dnsspoofer.Event
+	names  map[string]uint64
+	rules  string
+}
+
+// eventMsg delivers an engine event to the model.
+type eventMsg dnsspoofer.Event
+
+// tickMsg triggers a statistics refresh.
+type tickMsg time.Time
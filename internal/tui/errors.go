@@ -0,0 +1,5 @@
+package tui
+
+import "errors"
+
+var ErrRunDashboard = errors.New("failed to run terminal dashboard")
@@ -0,0 +1,253 @@
+package tui
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Onyz107/dnsspoofer"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// New creates a dashboard for engine, feed it with Event.
+func New(engine *dnsspoofer.Engine) *Dashboard {
+	return &Dashboard{
+		engine: engine,
+		events: make(chan dnsspoofer.Event, backlog),
+	}
+}
+
+// Event hands ev to the dashboard if it is a final event, see dnsspoofer.EventType.Final.
+// Use it as (part of) EngineOptions.OnEvent.
+//
+// It never blocks: events are dropped while the dashboard is behind.
+func (d *Dashboard) Event(ev dnsspoofer.Event) {
+	if !ev.Type.Final() {
+		return
+	}
+
+	select {
+	case d.events <- ev:
+	default:
+	}
+}
+
+// Run shows the dashboard until ctx is done or the user quits with q or ctrl+c.
+func (d *Dashboard) Run(ctx context.Context) error {
+	m := &model{
+		dashboard: d,
+		stats:     d.engine.Stats(),
+		names:     make(map[string]uint64),
+		rules:     d.engine.InstalledRules(),
+	}
+
+	p := tea.NewProgram(m, tea.WithContext(ctx), tea.WithAltScreen())
+	if _, err := p.Run(); err != nil && !errors.Is(err, tea.ErrProgramKilled) {
+		return errors.Join(ErrRunDashboard, err)
+	}
+	return nil
+}
+
+func (d *Dashboard) waitEvent() tea.Msg {
+	return eventMsg(<-d.events)
+}
+
+func tick() tea.Cmd {
+	return tea.Tick(refresh, func(t time.Time) tea.Msg {
+		return tickMsg(t)
+	})
+}
+
+func (m *model) Init() tea.Cmd {
+	return tea.Batch(m.dashboard.waitEvent, tick())
+}
+
+func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "q", "ctrl+c", "esc":
+			return m, tea.Quit
+		}
+
+	case eventMsg:
+		ev := dnsspoofer.Event(msg)
+		m.recent = append(m.recent, ev)
+		if len(m.recent) > recentRows {
+			m.recent = m.recent[len(m.recent)-recentRows:]
+		}
+		if ev.Name != "" {
+			m.countName(ev.Name)
+		}
+		return m, m.dashboard.waitEvent
+
+	case tickMsg:
+		m.stats = m.dashboard.engine.Stats()
+		m.rules = m.dashboard.engine.InstalledRules()
+		return m, tick()
+	}
+
+	return m, nil
+}
+
+func (m *model) View() string {
+	var b strings.Builder
+
+	b.WriteString(titleStyle.Render("DNSspoofer"))
+	b.WriteString("  ")
+	b.WriteString(m.summary())
+	b.WriteString("\n")
+
+	b.WriteString(panelStyle.Render(m.recentView()))
+	b.WriteString("\n")
+
+	b.WriteString(lipgloss.JoinHorizontal(lipgloss.Top,
+		panelStyle.Render(top("Clients", m.stats.Clients)),
+		panelStyle.Render(top("Names", m.names)),
+		panelStyle.Render(top("Rule hits", m.stats.Rules)),
+		panelStyle.Render(m.errorsView()),
+	))
+	b.WriteString("\n")
+	b.WriteString(panelStyle.Render(m.rulesView()))
+	b.WriteString("\n")
+	b.WriteString(helpStyle.Render("q: quit"))
+
+	return b.String()
+}
+
+// summary renders whether the firewall rules are installed and the packet counters.
+func (m *model) summary() string {
+	s := m.stats
+
+	nft := errorStyle.Render("rules: not installed")
+	if s.RulesInstalled {
+		nft = okStyle.Render("rules: installed")
+	}
+
+	errRate := 0.0
+	if s.Packets > 0 {
+		errRate = float64(s.Failed+s.QueueFull) / float64(s.Packets) * 100
+	}
+
+	return fmt.Sprintf("%s  kernel %d  packets %d  %s  skipped %d  dropped %d  %s  backlog %d",
+		nft, s.KernelPackets, s.Packets,
+		spoofStyle.Render(fmt.Sprintf("spoofed %d", s.Spoofed)),
+		s.Skipped, s.Dropped,
+		errorStyle.Render(fmt.Sprintf("errors %d (%.1f%%)", s.Failed+s.QueueFull, errRate)),
+		s.Backlog,
+	)
+}
+
+// countName counts a query for name, forgetting the least queried name once maxNames are counted.
+func (m *model) countName(name string) {
+	if _, ok := m.names[name]; !ok && len(m.names) >= maxNames {
+		least := ""
+		for n, c := range m.names {
+			if least == "" || c < m.names[least] {
+				least = n
+			}
+		}
+		delete(m.names, least)
+	}
+	m.names[name]++
+}
+
+// recentView renders the rolling list of queries, spoofed ones highlighted.
+func (m *model) recentView() string {
+	var b strings.Builder
+	b.WriteString(headerStyle.Render("Recent queries"))
+
+	for _, ev := range m.recent {
+		client := "-"
+		if ev.Client != nil {
+			client = ev.Client.String()
+		}
+
+		line := fmt.Sprintf("%s  %-15s  %-5s  %-40s  %-6s",
+			ev.Time.Format(time.TimeOnly), client, ev.QType, ev.Name, ev.Type.String())
+
+		switch ev.Type {
+		case dnsspoofer.EventSpoof:
+			var forged []string
+			for _, r := range ev.Forged {
+				forged = append(forged, r.Data)
+			}
+			line = spoofStyle.Render(line + "  -> " + strings.Join(forged, ", "))
+		case dnsspoofer.EventDrop:
+			line = dropStyle.Render(line)
+		case dnsspoofer.EventError:
+			line = errorStyle.Render(line + "  " + ev.Err.Error())
+		default:
+			line = dimStyle.Render(line)
+		}
+
+		b.WriteString("\n")
+		b.WriteString(line)
+	}
+	for range recentRows - len(m.recent) {
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+// rulesView renders the installed queueing rules and what the kernel queued through them.
+func (m *model) rulesView() string {
+	var b strings.Builder
+	b.WriteString(headerStyle.Render("Firewall rules"))
+	fmt.Fprintf(&b, "  queued %d packets, %d bytes", m.stats.KernelPackets, m.stats.KernelBytes)
+
+	if m.rules == "" {
+		b.WriteString("\n")
+		b.WriteString(dimStyle.Render("none installed"))
+		return b.String()
+	}
+	for line := range strings.Lines(m.rules) {
+		line = strings.ReplaceAll(strings.TrimSuffix(line, "\n"), "\t", "  ")
+		b.WriteString("\n")
+		b.WriteString(dimStyle.Render(ansi.Truncate(line, rulesWidth, "…")))
+	}
+	return b.String()
+}
+
+// errorsView renders the failures per error.
+func (m *model) errorsView() string {
+	counts := make(map[string]uint64, len(m.stats.Errors)+1)
+	for err, n := range m.stats.Errors {
+		counts[err.Error()] = n
+	}
+	if m.stats.QueueFull > 0 {
+		counts["backlog full"] = m.stats.QueueFull
+	}
+	return top("Errors", counts)
+}
+
+// top renders the topRows highest counts of counts under title.
+func top(title string, counts map[string]uint64) string {
+	keys := slices.Collect(maps.Keys(counts))
+	slices.SortFunc(keys, func(a, b string) int {
+		if c := cmp.Compare(counts[b], counts[a]); c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	})
+
+	var b strings.Builder
+	b.WriteString(headerStyle.Render(title))
+	for i := range topRows {
+		b.WriteString("\n")
+		if i >= len(keys) {
+			continue
+		}
+		key := ansi.Truncate(keys[i], columnWidth-8, "…")
+		fmt.Fprintf(&b, "%s%s %6d", key, strings.Repeat(" ", columnWidth-8-ansi.StringWidth(key)), counts[keys[i]])
+	}
+	return b.String()
+}
@@ -0,0 +1,43 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+func TestCountNameBounded(t *testing.T) {
+	m := &model{names: make(map[string]uint64)}
+	m.countName("popular.example.")
+	m.countName("popular.example.")
+	for i := range 2 * maxNames {
+		m.countName(fmt.Sprintf("host%d.example.", i))
+	}
+
+	if len(m.names) != maxNames {
+		t.Errorf("%d names counted, want %d", len(m.names), maxNames)
+	}
+	if m.names["popular.example."] != 2 {
+		t.Errorf("popular.example. counted %d times, want 2", m.names["popular.example."])
+	}
+}
+
+func TestTopWidth(t *testing.T) {
+	counts := map[string]uint64{
+		"short.example.":                      3,
+		strings.Repeat("ü", 40) + ".example.": 2,
+		strings.Repeat("日", 40) + ".example.": 1,
+	}
+
+	lines := strings.Split(top("Names", counts), "\n")[1:]
+	for _, line := range lines[:len(counts)] {
+		if w := ansi.StringWidth(line); w != columnWidth-1 {
+			t.Errorf("%q is %d cells wide, want %d", line, w, columnWidth-1)
+		}
+		if !strings.HasPrefix(line, "short") && !strings.Contains(line, "…") {
+			t.Errorf("%q is not truncated", line)
+		}
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/Onyz107/dnsspoofer"
 	"github.com/Onyz107/dnsspoofer/internal/logger"
 )
 
@@ -18,11 +19,14 @@ import (
 type Entry struct {
 	IP      net.IP
 	Pattern string // stored lowercased
+	Line    int    // line number in the hosts file
 }
 
 // Hosts holds parsed entries.
 type Hosts struct {
 	Entries []Entry
+	// Name is the file the entries were loaded from, empty when parsed from a reader
+	Name string
 }
 
 // LoadFile loads hosts from a file path.
@@ -32,7 +36,12 @@ func LoadFile(ctx context.Context, filename string) (*Hosts, error) {
 		return nil, err
 	}
 	defer f.Close()
-	return Parse(ctx, f)
+	h, err := Parse(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	h.Name = filename
+	return h, nil
 }
 
 // Parse parses hosts content from an io.Reader.
@@ -75,7 +84,7 @@ func Parse(ctx context.Context, r io.Reader) (*Hosts, error) {
 				return nil, fmt.Errorf("%w: line %d", ErrEmptyHostname, lineno)
 			}
 			log.Debug("loaded entry", "ip", ip, "pattern", pattern)
-			h.Entries = append(h.Entries, Entry{IP: ip, Pattern: pattern})
+			h.Entries = append(h.Entries, Entry{IP: ip, Pattern: pattern, Line: lineno})
 		}
 	}
 	if err := sc.Err(); err != nil {
@@ -101,6 +110,31 @@ func (h *Hosts) Lookup(hostname string) []net.IP {
 	return out
 }
 
+// Rule returns the file:line location of e, used as the rule name.
+func (h *Hosts) Rule(e Entry) string {
+	name := h.Name
+	if name == "" {
+		name = "hosts"
+	}
+	return fmt.Sprintf("%s:%d", name, e.Line)
+}
+
+// Rules returns one rule per entry, named after its file:line location.
+func (h *Hosts) Rules() []dnsspoofer.Rule {
+	if h == nil {
+		return nil
+	}
+	rules := make([]dnsspoofer.Rule, 0, len(h.Entries))
+	for _, e := range h.Entries {
+		rules = append(rules, dnsspoofer.Rule{
+			Name:    h.Rule(e),
+			Pattern: e.Pattern,
+			IPs:     []net.IP{e.IP},
+		})
+	}
+	return rules
+}
+
 func (h *Hosts) Map() map[*regexp.Regexp][]net.IP {
 	out := make(map[*regexp.Regexp][]net.IP)
 	if h == nil {
@@ -112,15 +146,7 @@ func (h *Hosts) Map() map[*regexp.Regexp][]net.IP {
 	for _, e := range h.Entries {
 		rx, ok := regexCache[e.Pattern]
 		if !ok {
-			rx = regexp.MustCompile(
-				"^" +
-					strings.ReplaceAll(
-						regexp.QuoteMeta(e.Pattern),
-						`\*`,
-						".*",
-					) +
-					"$",
-			)
+			rx = compile(e.Pattern)
 			regexCache[e.Pattern] = rx
 		}
 		out[rx] = append(out[rx], e.IP)
@@ -128,3 +154,16 @@ func (h *Hosts) Map() map[*regexp.Regexp][]net.IP {
 
 	return out
 }
+
+// compile turns a hostname pattern into an anchored regexp where only * is a wildcard.
+func compile(pattern string) *regexp.Regexp {
+	return regexp.MustCompile(
+		"^" +
+			strings.ReplaceAll(
+				regexp.QuoteMeta(pattern),
+				`\*`,
+				".*",
+			) +
+			"$",
+	)
+}
@@ -0,0 +1,77 @@
+package dnsspoofer
+
+import "context"
+
+// Decide answers q with the IPs of every pattern matching its name, or passes it if none does.
+func (h Hosts) Decide(ctx context.Context, q *Query) Decision {
+	var d Decision
+	for re, ipaddrs := range h {
+		if re.MatchString(q.Name) {
+			d.Rules = append(d.Rules, re.String())
+			d.IPs = append(d.IPs, ipaddrs...)
+		}
+	}
+	if len(d.IPs) > 0 {
+		d.Action = Answer
+	}
+	return d
+}
+
+// Decide calls f(ctx, q).
+func (f DeciderFunc) Decide(ctx context.Context, q *Query) Decision {
+	return f(ctx, q)
+}
+
+func (t EventType) String() string {
+	switch t {
+	case EventQuery:
+		return "query"
+	case EventMatch:
+		return "match"
+	case EventSpoof:
+		return "spoof"
+	case EventAccept:
+		return "accept"
+	case EventError:
+		return "error"
+	case EventDrop:
+		return "drop"
+	default:
+		return "unknown"
+	}
+}
+
+// Final reports whether t ends the handling of a packet: spoof, accept, drop or error.
+// Every queued packet gets exactly one final event.
+func (t EventType) Final() bool {
+	switch t {
+	case EventSpoof, EventAccept, EventDrop, EventError:
+		return true
+	default:
+		return false
+	}
+}
+
+func (a Action) String() string {
+	switch a {
+	case Pass:
+		return "pass"
+	case Drop:
+		return "drop"
+	case Answer:
+		return "answer"
+	default:
+		return "unknown"
+	}
+}
+
+func (b Backend) String() string {
+	switch b {
+	case NFTables:
+		return "nftables"
+	case IPTables:
+		return "iptables"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,111 @@
+package dnsspoofer
+
+import (
+	"context"
+	"math/rand/v2"
+	"net"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Middleware wraps a Decider with extra behaviour, like net/http handler middlewares.
+type Middleware func(next Decider) Decider
+
+// Chain wraps d with mws, the first middleware being the outermost one.
+func Chain(d Decider, mws ...Middleware) Decider {
+	for _, mw := range slices.Backward(mws) {
+		d = mw(d)
+	}
+	return d
+}
+
+// Logging logs every query and the decision taken for it.
+//
+// The engine always installs it as the outermost middleware with EngineOptions.Log.
+func Logging(log Logger) Middleware {
+	return func(next Decider) Decider {
+		return DeciderFunc(func(ctx context.Context, q *Query) Decision {
+			d := next.Decide(ctx, q)
+			log.Info("decided packet",
+				"client", q.Client.String(),
+				"name", q.Name,
+				"qtype", q.QType,
+				"action", d.Action.String(),
+				"rules", d.Rules,
+			)
+			return d
+		})
+	}
+}
+
+// Clients only lets queries from clients inside nets reach the next Decider, others are passed.
+func Clients(nets ...*net.IPNet) Middleware {
+	return func(next Decider) Decider {
+		return DeciderFunc(func(ctx context.Context, q *Query) Decision {
+			for _, n := range nets {
+				if n.Contains(q.Client) {
+					return next.Decide(ctx, q)
+				}
+			}
+			return Decision{Action: Pass}
+		})
+	}
+}
+
+// RateLimit lets at most n queries per client and per window reach the next Decider,
+// the excess is passed untouched and reported under the "ratelimit" rule.
+func RateLimit(n int, window time.Duration) Middleware {
+	var mu sync.Mutex
+	counts := make(map[string]int)
+	reset := time.Now().Add(window)
+
+	return func(next Decider) Decider {
+		return DeciderFunc(func(ctx context.Context, q *Query) Decision {
+			client := q.Client.String()
+
+			mu.Lock()
+			if now := time.Now(); now.After(reset) {
+				clear(counts)
+				reset = now.Add(window)
+			}
+			counts[client]++
+			limited := counts[client] > n
+			mu.Unlock()
+
+			if limited {
+				return Decision{Action: Pass, Rules: []string{"ratelimit"}}
+			}
+			return next.Decide(ctx, q)
+		})
+	}
+}
+
+// TTL sets the TTL of forged answers to ttl seconds.
+func TTL(ttl uint32) Middleware {
+	return func(next Decider) Decider {
+		return DeciderFunc(func(ctx context.Context, q *Query) Decision {
+			d := next.Decide(ctx, q)
+			if d.Action == Answer {
+				d.TTL = ttl
+			}
+			return d
+		})
+	}
+}
+
+// Shuffle randomizes the order of the answered IPs, so clients spread over them.
+func Shuffle() Middleware {
+	return func(next Decider) Decider {
+		return DeciderFunc(func(ctx context.Context, q *Query) Decision {
+			d := next.Decide(ctx, q)
+			if d.Action == Answer {
+				d.IPs = slices.Clone(d.IPs)
+				rand.Shuffle(len(d.IPs), func(i, j int) {
+					d.IPs[i], d.IPs[j] = d.IPs[j], d.IPs[i]
+				})
+			}
+			return d
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package dnsspoofer
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+// answerAll answers every query with 10.0.0.1 to 10.0.0.4 under the "all" rule.
+var answerAll = DeciderFunc(func(ctx context.Context, q *Query) Decision {
+	return Decision{
+		Action: Answer,
+		IPs:    []net.IP{net.IPv4(10, 0, 0, 1), net.IPv4(10, 0, 0, 2), net.IPv4(10, 0, 0, 3), net.IPv4(10, 0, 0, 4)},
+		Rules:  []string{"all"},
+	}
+})
+
+// decide returns the decision of d for a query of example.com from client.
+func decide(d Decider, client string) Decision {
+	return d.Decide(context.Background(), &Query{Client: net.ParseIP(client), Name: "example.com", QType: "A", IsRequest: true})
+}
+
+func TestChain(t *testing.T) {
+	var calls []string
+	record := func(name string) Middleware {
+		return func(next Decider) Decider {
+			return DeciderFunc(func(ctx context.Context, q *Query) Decision {
+				calls = append(calls, name)
+				d := next.Decide(ctx, q)
+				calls = append(calls, name+" done")
+				return d
+			})
+		}
+	}
+	d := Chain(DeciderFunc(func(ctx context.Context, q *Query) Decision {
+		calls = append(calls, "decider")
+		return Decision{Action: Drop}
+	}), record("outer"), record("inner"))
+
+	if got := decide(d, "10.0.0.2"); got.Action != Drop {
+		t.Errorf("action = %s, want %s", got.Action.String(), Drop.String())
+	}
+	want := []string{"outer", "inner", "decider", "inner done", "outer done"}
+	if !slices.Equal(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+
+	if got := Chain(answerAll); got.Decide(context.Background(), &Query{}).Action != Answer {
+		t.Error("Chain() without middlewares changed the decision")
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	const window = 100 * time.Millisecond
+	d := Chain(answerAll, RateLimit(2, window))
+
+	for i := range 2 {
+		if got := decide(d, "10.0.0.2"); got.Action != Answer {
+			t.Fatalf("query %d action = %s, want %s", i, got.Action.String(), Answer.String())
+		}
+	}
+	got := decide(d, "10.0.0.2")
+	if got.Action != Pass || !slices.Equal(got.Rules, []string{"ratelimit"}) {
+		t.Errorf("query over the limit = %s %v, want %s [ratelimit]", got.Action.String(), got.Rules, Pass.String())
+	}
+	// Clients are counted apart.
+	if got := decide(d, "10.0.0.3"); got.Action != Answer {
+		t.Errorf("other client action = %s, want %s", got.Action.String(), Answer.String())
+	}
+
+	time.Sleep(window + 20*time.Millisecond)
+	if got := decide(d, "10.0.0.2"); got.Action != Answer {
+		t.Errorf("action once the window is over = %s, want %s", got.Action.String(), Answer.String())
+	}
+}
+
+func TestClients(t *testing.T) {
+	_, lan, _ := net.ParseCIDR("10.0.0.0/8")
+	_, ula, _ := net.ParseCIDR("fd00::/8")
+	d := Chain(answerAll, Clients(lan, ula))
+
+	tests := []struct {
+		client string
+		want   Action
+	}{
+		{"10.1.2.3", Answer},
+		{"fd00::2", Answer},
+		{"192.168.1.2", Pass},
+		{"2001:db8::2", Pass},
+	}
+	for _, tt := range tests {
+		got := decide(d, tt.client)
+		if got.Action != tt.want {
+			t.Errorf("client %s action = %s, want %s", tt.client, got.Action.String(), tt.want.String())
+		}
+		if tt.want == Pass && (got.Rules != nil || got.IPs != nil) {
+			t.Errorf("client %s passed with %v", tt.client, got)
+		}
+	}
+}
+
+func TestTTL(t *testing.T) {
+	tests := []struct {
+		name string
+		next Decision
+		want uint32
+	}{
+		{"answer", Decision{Action: Answer, IPs: []net.IP{net.IPv4(10, 0, 0, 1)}, TTL: 60}, 5},
+		{"pass", Decision{Action: Pass}, 0},
+		{"drop", Decision{Action: Drop, TTL: 60}, 60},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := Chain(DeciderFunc(func(ctx context.Context, q *Query) Decision { return tt.next }), TTL(5))
+			got := decide(d, "10.0.0.2")
+			if got.TTL != tt.want || got.Action != tt.next.Action {
+				t.Errorf("decision = %s TTL %d, want %s TTL %d", got.Action.String(), got.TTL, tt.next.Action.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestShuffle(t *testing.T) {
+	ips := answerAll.Decide(context.Background(), &Query{}).IPs
+	want := slices.Clone(ips)
+	d := Chain(DeciderFunc(func(ctx context.Context, q *Query) Decision {
+		return Decision{Action: Answer, IPs: ips, Rules: []string{"all"}}
+	}), Shuffle())
+
+	sorted := func(ips []net.IP) []string {
+		s := make([]string, 0, len(ips))
+		for _, ip := range ips {
+			s = append(s, ip.String())
+		}
+		slices.Sort(s)
+		return s
+	}
+	orders := make(map[string]bool)
+	for range 50 {
+		got := decide(d, "10.0.0.2")
+		if !slices.Equal(sorted(got.IPs), sorted(want)) || !slices.Equal(got.Rules, []string{"all"}) {
+			t.Fatalf("shuffled decision = %v, want the IPs %v", got, want)
+		}
+		orders[got.IPs[0].String()+got.IPs[1].String()] = true
+	}
+	if len(orders) < 2 {
+		t.Error("50 shuffles kept the same order")
+	}
+	// The IPs of the next Decider, e.g. those of a rule, are left in their order.
+	if !reflect.DeepEqual(ips, want) {
+		t.Errorf("next Decider IPs reordered to %v", ips)
+	}
+
+	pass := Chain(DeciderFunc(func(ctx context.Context, q *Query) Decision { return Decision{Action: Pass} }), Shuffle())
+	if got := decide(pass, "10.0.0.2"); got.Action != Pass || got.IPs != nil {
+		t.Errorf("shuffled pass = %v", got)
+	}
+}
@@ -0,0 +1,236 @@
+package dnsspoofer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Onyz107/dnsspoofer/internal/dns"
+	"github.com/Onyz107/dnsspoofer/internal/nftables"
+	"github.com/Onyz107/dnsspoofer/internal/proxy"
+	gonfqueue "github.com/florianl/go-nfqueue/v2"
+	"github.com/google/gopacket/layers"
+)
+
+// listenProxy starts the resolver DNS requests are redirected to in Proxy SpoofMode.
+//
+// It outlives the engine context so that requests redirected until the rules are removed are still answered.
+func (e *Engine) listenProxy() (*proxy.Server, error) {
+	if e.opts.Upstream == "" {
+		return nil, ErrMissingUpstream
+	}
+	forwarder, err := proxy.NewForwarder(e.opts.Upstream, nftables.ProxyMark, e.opts.CacheSize)
+	if err != nil {
+		return nil, errors.Join(ErrListenProxy, err)
+	}
+	e.forwarder = forwarder
+	if host, _, err := net.SplitHostPort(e.forwarder.Upstream()); err == nil {
+		e.upstream = net.ParseIP(host)
+	}
+
+	addrs, err := e.proxyAddrs()
+	if err != nil {
+		return nil, errors.Join(ErrListenProxy, err)
+	}
+	srv, err := proxy.Listen(context.WithoutCancel(e.ctx), addrs, e.resolve)
+	if err != nil {
+		return nil, errors.Join(ErrListenProxy, err)
+	}
+	e.opts.Log.Debug("started DNS proxy", "addrs", addrs, "upstream", e.forwarder.Upstream(), "cache", e.opts.CacheSize)
+	return srv, nil
+}
+
+// proxyAddrs returns the addresses the redirection rewrites the destination of DNS requests to,
+// the only ones the proxy listens on: loopback for the requests of this machine,
+// the addresses of Iface for the requests entering it.
+func (e *Engine) proxyAddrs() ([]string, error) {
+	remote := e.opts.Scope == Remote || e.opts.Hook == HookPrerouting
+	var ifaceAddrs []net.Addr
+	var err error
+	if remote {
+		if e.opts.Iface == nil {
+			return nil, nftables.ErrMissingIface
+		}
+		ifaceAddrs, err = e.opts.Iface.Addrs()
+	} else {
+		ifaceAddrs, err = net.InterfaceAddrs()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	port := strconv.Itoa(int(e.opts.ProxyPort))
+	var addrs []string
+	for _, a := range ifaceAddrs {
+		ipNet, ok := a.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		ip := ipNet.IP
+		if !remote && !ip.Equal(net.IPv4(127, 0, 0, 1)) && !ip.Equal(net.IPv6loopback) {
+			continue
+		}
+		if v4 := ip.To4() != nil; (v4 && e.opts.IPMode == IPv6Only) || (!v4 && e.opts.IPMode == IPv4Only) {
+			continue
+		}
+		host := ip.String()
+		if ip.IsLinkLocalUnicast() {
+			host += "%" + e.opts.Iface.Name
+		}
+		addrs = append(addrs, net.JoinHostPort(host, port))
+	}
+	if len(addrs) == 0 {
+		return nil, ErrNoProxyAddr
+	}
+	return addrs, nil
+}
+
+// resolve answers the DNS request msg of client as the Decider says: from the matching rules,
+// or forwarded upstream when passed. Dropped requests get no answer.
+func (e *Engine) resolve(ctx context.Context, client net.Addr, msg []byte) []byte {
+	start := time.Now()
+	e.stats.packets.Add(1)
+
+	req, err := dns.ParseMessage(msg)
+	if err == nil && req.QR {
+		err = dns.ErrInvalidDNSRequest
+	}
+	if err != nil {
+		e.stats.parseFail(dns.ParseErrorKind(err))
+		e.opts.Log.Error(ErrParsePacket.Error(), "err", err)
+		e.emit(Event{
+			Type:    EventError,
+			Latency: time.Since(start),
+			Err:     errors.Join(ErrParsePacket, err),
+			Verdict: verdictName(gonfqueue.NfDrop),
+		})
+		return nil
+	}
+
+	q := &Query{
+		Client:    addrIP(client),
+		Server:    e.upstream,
+		Name:      strings.ToLower(strings.TrimSuffix(string(req.Questions[0].Name), ".")),
+		QType:     req.Questions[0].Type.String(),
+		IsRequest: true,
+	}
+	e.opts.Log.Info("proxied request", "client", q.Client, "name", q.Name, "type", q.QType)
+	e.stats.parsed(q.QType, q.Client.String())
+	ev := Event{
+		Type:      EventQuery,
+		Client:    q.Client,
+		Server:    q.Server,
+		Name:      q.Name,
+		QType:     q.QType,
+		IsRequest: true,
+	}
+	e.emit(ev)
+
+	var d Decision
+	if !e.paused.Load() {
+		d = e.decider.Decide(ctx, q)
+	}
+	for _, rule := range d.Rules {
+		e.stats.rule(rule)
+	}
+	ev.Rules = d.Rules
+
+	switch d.Action {
+	case Pass:
+		res, err := e.forwarder.Exchange(ctx, msg)
+		if err != nil {
+			return e.proxyFail(req, ev, start, ErrForward, err)
+		}
+		if e.opts.StripDNSSEC {
+			if stripped, err := dns.StripDNSSEC(res); err == nil {
+				res = stripped
+			}
+		}
+		e.stats.skipped.Add(1)
+
+		if upstream, err := dns.ParseMessage(res); err == nil {
+			ev.Original = dns.NewRecords(upstream.Answers)
+		}
+		ev.Type = EventAccept
+		ev.Latency = time.Since(start)
+		ev.Verdict = verdictName(gonfqueue.NfAccept)
+		e.emit(ev)
+		return res
+
+	case Drop:
+		e.stats.matched.Add(1)
+		e.stats.dropped.Add(1)
+		ev.Type = EventMatch
+		e.emit(ev)
+
+		ev.Type = EventDrop
+		ev.Latency = time.Since(start)
+		ev.Verdict = verdictName(gonfqueue.NfDrop)
+		e.emit(ev)
+		return nil
+
+	case Answer:
+		e.stats.matched.Add(1)
+		ev.Type = EventMatch
+		e.emit(ev)
+
+	default:
+		return e.proxyFail(req, ev, start, ErrInvalidAction, fmt.Errorf("action %d", d.Action))
+	}
+
+	forged, err := dns.Answer(req, layers.DNSResponseCode(d.RCode), d.TTL, d.IPs...)
+	if err != nil {
+		return e.proxyFail(req, ev, start, ErrSpoofPacket, err)
+	}
+	res, err := dns.SerializeMessage(forged)
+	if err != nil {
+		return e.proxyFail(req, ev, start, ErrSerializePkt, err)
+	}
+	latency := time.Since(start)
+	e.stats.spoof(latency)
+
+	ev.Type = EventSpoof
+	ev.Forged = dns.NewRecords(forged.Answers)
+	ev.Latency = latency
+	ev.Verdict = verdictName(gonfqueue.NfAccept)
+	e.emit(ev)
+	return res
+}
+
+// proxyFail answers req with SERVFAIL after a failure with the sentinel error kind.
+func (e *Engine) proxyFail(req *layers.DNS, ev Event, start time.Time, kind, err error) []byte {
+	e.stats.fail(kind)
+	e.opts.Log.Error(kind.Error(), "err", err)
+
+	ev.Type = EventError
+	ev.Latency = time.Since(start)
+	ev.Err = errors.Join(kind, err)
+	ev.Verdict = verdictName(gonfqueue.NfAccept)
+	e.emit(ev)
+
+	servfail, err := dns.Answer(req, layers.DNSResponseCodeServFail, 0)
+	if err != nil {
+		return nil
+	}
+	res, err := dns.SerializeMessage(servfail)
+	if err != nil {
+		return nil
+	}
+	return res
+}
+
+// addrIP returns the IP of a UDP or TCP address.
+func addrIP(addr net.Addr) net.IP {
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		return a.IP
+	case *net.TCPAddr:
+		return a.IP
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,139 @@
+package dnsspoofer
+
+import (
+	"context"
+	"errors"
+	"net"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Rule answers names matching Pattern with IPs.
+type Rule struct {
+	// Name identifies the rule in decisions, stats and events, several rules may share it (e.g. "hosts.txt:3")
+	Name string `json:"name"`
+	// Pattern is a hostname pattern where * matches any sequence of characters
+	Pattern string `json:"pattern"`
+	// IPs are the addresses matching names are answered with
+	IPs []net.IP `json:"ips"`
+}
+
+// RuleSet is a Decider over rules that can be changed while the engine runs.
+type RuleSet struct {
+	mu    sync.RWMutex
+	rules []compiledRule
+}
+
+type compiledRule struct {
+	Rule
+	re *regexp.Regexp
+}
+
+// NewRuleSet creates a rule set holding rules.
+func NewRuleSet(rules ...Rule) (*RuleSet, error) {
+	s := new(RuleSet)
+	if err := s.Add(rules...); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// compilePattern turns a hostname pattern into an anchored regexp where only * is a wildcard.
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile("^" + strings.ReplaceAll(regexp.QuoteMeta(strings.ToLower(pattern)), `\*`, ".*") + "$")
+}
+
+// compileRule validates r and compiles its pattern.
+func compileRule(r Rule) (compiledRule, error) {
+	if r.Name == "" {
+		return compiledRule{}, ErrMissingRuleName
+	}
+	if strings.TrimSpace(r.Pattern) == "" {
+		return compiledRule{}, ErrMissingRulePattern
+	}
+	if len(r.IPs) == 0 {
+		return compiledRule{}, ErrMissingRuleIPs
+	}
+	re, err := compilePattern(strings.TrimSpace(r.Pattern))
+	if err != nil {
+		return compiledRule{}, errors.Join(ErrInvalidRulePattern, err)
+	}
+	return compiledRule{Rule: r, re: re}, nil
+}
+
+// Add appends rules to the set, either all of them or none.
+//
+// Returns an error if a rule has no name, pattern or IPs.
+func (s *RuleSet) Add(rules ...Rule) error {
+	compiled := make([]compiledRule, 0, len(rules))
+	for _, r := range rules {
+		c, err := compileRule(r)
+		if err != nil {
+			return err
+		}
+		compiled = append(compiled, c)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rules = append(s.rules, compiled...)
+	return nil
+}
+
+// Remove removes every rule named name and returns how many were removed.
+func (s *RuleSet) Remove(name string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := len(s.rules)
+	s.rules = slices.DeleteFunc(s.rules, func(r compiledRule) bool {
+		return r.Name == name
+	})
+	return n - len(s.rules)
+}
+
+// Replace atomically replaces every rule of the set with rules.
+func (s *RuleSet) Replace(rules ...Rule) error {
+	next, err := NewRuleSet(rules...)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rules = next.rules
+	return nil
+}
+
+// List returns the rules of the set, in the order they were added.
+func (s *RuleSet) List() []Rule {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rules := make([]Rule, 0, len(s.rules))
+	for _, r := range s.rules {
+		rules = append(rules, r.Rule)
+	}
+	return rules
+}
+
+// Decide answers q with the IPs of every rule matching its name, or passes it if none does.
+// The decision rules are the names of the matching rules.
+func (s *RuleSet) Decide(ctx context.Context, q *Query) Decision {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var d Decision
+	for _, r := range s.rules {
+		if r.re.MatchString(q.Name) {
+			if !slices.Contains(d.Rules, r.Name) {
+				d.Rules = append(d.Rules, r.Name)
+			}
+			d.IPs = append(d.IPs, r.IPs...)
+		}
+	}
+	if len(d.IPs) > 0 {
+		d.Action = Answer
+	}
+	return d
+}
@@ -0,0 +1,152 @@
+package dnsspoofer
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestRuleSetDecide(t *testing.T) {
+	s, err := NewRuleSet(
+		Rule{Name: "wildcard", Pattern: "*.example.com", IPs: []net.IP{net.IPv4(10, 0, 0, 1)}},
+		Rule{Name: "exact", Pattern: "Example.org", IPs: []net.IP{net.IPv4(10, 0, 0, 2)}},
+		Rule{Name: "exact", Pattern: "www.example.com", IPs: []net.IP{net.ParseIP("fd00::2")}},
+		Rule{Name: "dot", Pattern: "a.b", IPs: []net.IP{net.IPv4(10, 0, 0, 3)}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		rules []string
+		ips   int
+	}{
+		{"www.example.com", []string{"wildcard", "exact"}, 2},
+		{"a.b.example.com", []string{"wildcard"}, 1},
+		{"example.com", nil, 0},
+		{"example.org", []string{"exact"}, 1},
+		{"www.example.org", nil, 0},
+		// Only * is a wildcard, the dot is matched literally.
+		{"a.b", []string{"dot"}, 1},
+		{"axb", nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := s.Decide(context.Background(), &Query{Name: tt.name})
+			want := Pass
+			if tt.ips > 0 {
+				want = Answer
+			}
+			if d.Action != want || !slices.Equal(d.Rules, tt.rules) || len(d.IPs) != tt.ips {
+				t.Errorf("decision = %s %v %v, want %s %v with %d IPs", d.Action.String(), d.Rules, d.IPs, want.String(), tt.rules, tt.ips)
+			}
+		})
+	}
+}
+
+func TestRuleSetAdd(t *testing.T) {
+	ip := []net.IP{net.IPv4(10, 0, 0, 1)}
+	tests := []struct {
+		name string
+		rule Rule
+		err  error
+	}{
+		{"valid", Rule{Name: "a", Pattern: "example.com", IPs: ip}, nil},
+		{"no name", Rule{Pattern: "example.com", IPs: ip}, ErrMissingRuleName},
+		{"no pattern", Rule{Name: "a", Pattern: " ", IPs: ip}, ErrMissingRulePattern},
+		{"no IPs", Rule{Name: "a", Pattern: "example.com"}, ErrMissingRuleIPs},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := NewRuleSet(Rule{Name: "first", Pattern: "first.example.com", IPs: ip})
+			// Either every rule is added or none.
+			err := s.Add(Rule{Name: "b", Pattern: "b.example.com", IPs: ip}, tt.rule)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Add() error = %v, want %v", err, tt.err)
+			}
+			want := 3
+			if tt.err != nil {
+				want = 1
+			}
+			if n := len(s.List()); n != want {
+				t.Errorf("rules = %d, want %d", n, want)
+			}
+		})
+	}
+}
+
+func TestRuleSetRemoveReplace(t *testing.T) {
+	ip := []net.IP{net.IPv4(10, 0, 0, 1)}
+	s, err := NewRuleSet(
+		Rule{Name: "hosts:1", Pattern: "a.example.com", IPs: ip},
+		Rule{Name: "api", Pattern: "b.example.com", IPs: ip},
+		Rule{Name: "api", Pattern: "c.example.com", IPs: ip},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := s.Remove("api"); n != 2 {
+		t.Errorf("Remove() = %d, want 2", n)
+	}
+	if n := s.Remove("api"); n != 0 {
+		t.Errorf("second Remove() = %d, want 0", n)
+	}
+	if rules := s.List(); len(rules) != 1 || rules[0].Name != "hosts:1" {
+		t.Errorf("rules after Remove() = %v", rules)
+	}
+
+	// An invalid replacement leaves the set untouched.
+	if err := s.Replace(Rule{Name: "x", Pattern: "x.example.com", IPs: ip}, Rule{Name: "y"}); !errors.Is(err, ErrMissingRulePattern) {
+		t.Errorf("Replace() error = %v, want %v", err, ErrMissingRulePattern)
+	}
+	if rules := s.List(); len(rules) != 1 || rules[0].Name != "hosts:1" {
+		t.Errorf("rules after a failed Replace() = %v", rules)
+	}
+
+	if err := s.Replace(Rule{Name: "x", Pattern: "x.example.com", IPs: ip}); err != nil {
+		t.Fatal(err)
+	}
+	if d := s.Decide(context.Background(), &Query{Name: "a.example.com"}); d.Action != Pass {
+		t.Errorf("replaced rule still answers: %v", d)
+	}
+	if d := s.Decide(context.Background(), &Query{Name: "x.example.com"}); d.Action != Answer {
+		t.Errorf("new rule does not answer: %v", d)
+	}
+}
+
+// TestRuleSetConcurrent changes the set while deciding, as the control and gRPC APIs do
+// while the workers run, run it with -race.
+func TestRuleSetConcurrent(t *testing.T) {
+	ip := []net.IP{net.IPv4(10, 0, 0, 1)}
+	s, err := NewRuleSet(Rule{Name: "a", Pattern: "*.example.com", IPs: ip})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Go(func() {
+			for range 500 {
+				s.Decide(context.Background(), &Query{Name: "www.example.com"})
+				s.List()
+			}
+		})
+	}
+	wg.Go(func() {
+		for range 500 {
+			s.Add(Rule{Name: "b", Pattern: "b.example.com", IPs: ip})
+			s.Remove("b")
+			s.Replace(Rule{Name: "a", Pattern: "*.example.com", IPs: ip})
+		}
+	})
+	wg.Wait()
+
+	if rules := s.List(); len(rules) != 1 || rules[0].Name != "a" {
+		t.Errorf("rules = %v, want only a", rules)
+	}
+}
@@ -0,0 +1,174 @@
+package dnsspoofer
+
+import (
+	"maps"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Onyz107/dnsspoofer/internal/nfqueue"
+	"github.com/Onyz107/dnsspoofer/internal/nftables"
+)
+
+// stats holds the live engine counters, safe for concurrent use by the workers.
+type stats struct {
+	packets   atomic.Uint64
+	matched   atomic.Uint64
+	spoofed   atomic.Uint64
+	skipped   atomic.Uint64
+	dropped   atomic.Uint64
+	failed    atomic.Uint64
+	queueFull atomic.Uint64
+	installed atomic.Bool
+
+	// kernelPackets and kernelBytes are the last counter read from rules
+	kernelPackets atomic.Uint64
+	kernelBytes   atomic.Uint64
+
+	mu          sync.Mutex
+	records     map[string]uint64
+	rules       map[string]uint64
+	clients     map[string]uint64
+	errors      map[error]uint64
+	parseErrors map[error]uint64
+	latency     Histogram
+	backlogs    []<-chan nfqueue.Packet
+	queueRules  nftables.Installed
+}
+
+func newStats() *stats {
+	return &stats{
+		records: make(map[string]uint64),
+		rules:   make(map[string]uint64),
+		clients: make(map[string]uint64),
+		errors:  make(map[error]uint64),
+
+		parseErrors: make(map[error]uint64),
+		latency:     Histogram{Buckets: make(map[time.Duration]uint64)},
+	}
+}
+
+// parsed counts a parsed packet for its record type and client.
+func (s *stats) parsed(record, client string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[record]++
+	s.clients[client]++
+}
+
+// rule counts a hosts pattern match.
+func (s *stats) rule(pattern string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rules[pattern]++
+}
+
+// fail counts a packet that failed with the sentinel error err.
+func (s *stats) fail(err error) {
+	s.failed.Add(1)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errors[err]++
+}
+
+// parseFail counts a packet that failed to parse because of cause.
+func (s *stats) parseFail(cause error) {
+	s.fail(ErrParsePacket)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.parseErrors[cause]++
+}
+
+// spoof counts a spoofed packet that took d to handle.
+func (s *stats) spoof(d time.Duration) {
+	s.spoofed.Add(1)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency.Count++
+	s.latency.Sum += d
+	for _, bound := range LatencyBuckets {
+		if d <= bound {
+			s.latency.Buckets[bound]++
+		}
+	}
+}
+
+// setBacklogs sets the packet channels whose length is reported as the backlog.
+func (s *stats) setBacklogs(backlogs []<-chan nfqueue.Packet) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.backlogs = backlogs
+}
+
+// setQueueRules sets the installed queueing rules whose counter is reported, nil once they are removed.
+func (s *stats) setQueueRules(rules nftables.Installed) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queueRules = rules
+}
+
+// queueRulesString renders the installed queueing rules, "" when there are none.
+func (s *stats) queueRulesString() string {
+	s.mu.Lock()
+	rules := s.queueRules
+	s.mu.Unlock()
+	if rules == nil {
+		return ""
+	}
+	return rules.String()
+}
+
+// readKernel refreshes the kernel counters from the installed queueing rules, if any.
+//
+// The rules are read outside of the lock, so that the workers never wait on the kernel.
+func (s *stats) readKernel() error {
+	s.mu.Lock()
+	rules := s.queueRules
+	s.mu.Unlock()
+	if rules == nil {
+		return nil
+	}
+
+	c, err := rules.Counter()
+	if err != nil {
+		return err
+	}
+	s.kernelPackets.Store(c.Packets)
+	s.kernelBytes.Store(c.Bytes)
+	return nil
+}
+
+func (s *stats) snapshot() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	backlog := 0
+	for _, ch := range s.backlogs {
+		backlog += len(ch)
+	}
+	latency := s.latency
+	latency.Buckets = maps.Clone(s.latency.Buckets)
+
+	return Stats{
+		Packets:   s.packets.Load(),
+		Matched:   s.matched.Load(),
+		Spoofed:   s.spoofed.Load(),
+		Skipped:   s.skipped.Load(),
+		Dropped:   s.dropped.Load(),
+		Failed:    s.failed.Load(),
+		QueueFull: s.queueFull.Load(),
+
+		KernelPackets: s.kernelPackets.Load(),
+		KernelBytes:   s.kernelBytes.Load(),
+
+		Records: maps.Clone(s.records),
+		Rules:   maps.Clone(s.rules),
+		Clients: maps.Clone(s.clients),
+		Errors:  maps.Clone(s.errors),
+
+		ParseErrors:    maps.Clone(s.parseErrors),
+		SpoofLatency:   latency,
+		Backlog:        backlog,
+		RulesInstalled: s.installed.Load(),
+	}
+}